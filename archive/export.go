@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestName is the file in every export that lists its contents
+const ManifestName = "manifest.json"
+
+// ExportNote is a note as written to an export archive
+type ExportNote struct {
+	NoteID    uint
+	Title     string
+	Body      string
+	Owner     string
+	Tags      []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ExportFolder describes the folder an archive was made from
+type ExportFolder struct {
+	FolderID uint   `json:"folderId"`
+	Name     string `json:"name"`
+	Owner    string `json:"owner"`
+}
+
+// Manifest lists the notes contained in an export archive
+type Manifest struct {
+	Folder     ExportFolder    `json:"folder"`
+	ExportedBy string          `json:"exportedBy"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Notes      []ManifestEntry `json:"notes"`
+}
+
+// ManifestEntry maps a note to its file in the archive
+type ManifestEntry struct {
+	NoteID uint   `json:"noteId"`
+	Title  string `json:"title"`
+	File   string `json:"file"`
+}
+
+// frontMatter is the YAML header written at the top of each note file
+type frontMatter struct {
+	Title     string    `yaml:"title"`
+	NoteID    uint      `yaml:"noteId,omitempty"`
+	Owner     string    `yaml:"owner,omitempty"`
+	CreatedAt time.Time `yaml:"createdAt"`
+	UpdatedAt time.Time `yaml:"updatedAt"`
+	Tags      []string  `yaml:"tags"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}._ -]+`)
+
+// fileName turns a note title into a file name that is unique in the archive
+func fileName(title string, used map[string]bool) string {
+	base := strings.TrimSpace(unsafeFileChars.ReplaceAllString(title, "-"))
+	base = strings.Trim(base, ".-")
+	if base == "" {
+		base = "untitled"
+	}
+
+	name := base + ".md"
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s-%d.md", base, i)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// WriteFolderZip writes the notes as Markdown files with YAML front matter,
+// followed by a manifest, to a ZIP archive
+func WriteFolderZip(w io.Writer, folder ExportFolder, exportedBy string, notes []ExportNote) error {
+	zw := zip.NewWriter(w)
+
+	manifest := Manifest{
+		Folder:     folder,
+		ExportedBy: exportedBy,
+		ExportedAt: time.Now().UTC(),
+		Notes:      make([]ManifestEntry, 0, len(notes)),
+	}
+
+	used := map[string]bool{strings.ToLower(ManifestName): true}
+	for _, note := range notes {
+		name := fileName(note.Title, used)
+
+		tags := note.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		header, err := yaml.Marshal(frontMatter{
+			Title:     note.Title,
+			NoteID:    note.NoteID,
+			Owner:     note.Owner,
+			CreatedAt: note.CreatedAt.UTC(),
+			UpdatedAt: note.UpdatedAt.UTC(),
+			Tags:      tags,
+		})
+		if err != nil {
+			return err
+		}
+
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: note.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(f, "---\n%s---\n\n%s\n", header, note.Body); err != nil {
+			return err
+		}
+
+		manifest.Notes = append(manifest.Notes, ManifestEntry{
+			NoteID: note.NoteID,
+			Title:  note.Title,
+			File:   name,
+		})
+	}
+
+	f, err := zw.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
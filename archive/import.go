@@ -0,0 +1,298 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seta-namnv-6798/go-apis/markdown"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits on what a single ZIP import may unpack
+const (
+	// MaxEntrySize caps the uncompressed size of a single imported note
+	MaxEntrySize = 5 << 20
+
+	// MaxEntries caps how many entries an archive may list
+	MaxEntries = 10000
+
+	// MaxTotalSize caps the combined uncompressed size of the notes read
+	// from one archive
+	MaxTotalSize = 100 << 20
+)
+
+// Errors that reject a whole archive
+var (
+	ErrTooManyEntries  = fmt.Errorf("archive has more than %d entries", MaxEntries)
+	ErrArchiveTooLarge = fmt.Errorf("archive unpacks to more than %d MB", MaxTotalSize>>20)
+)
+
+// Import item statuses
+const (
+	ItemImported = "imported"
+	ItemSkipped  = "skipped"
+)
+
+// ImportedNote is a note read from an archive, ready to be stored
+type ImportedNote struct {
+	Source    string
+	Title     string
+	Body      string
+	Tags      []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ItemReport describes what happened to one entry of an import
+type ItemReport struct {
+	Source    string `json:"source"`
+	Title     string `json:"title,omitempty"`
+	Status    string `json:"status"`
+	NoteID    uint   `json:"noteId,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func skipped(source, reason string) ItemReport {
+	return ItemReport{Source: source, Status: ItemSkipped, Reason: reason}
+}
+
+// ReadMarkdownZip reads every Markdown file in a ZIP archive. Front matter is
+// optional; without a title the first heading or the file name is used.
+// Entries that cannot be read are reported as skipped. Archives listing
+// more than MaxEntries entries or unpacking to more than MaxTotalSize are
+// rejected.
+func ReadMarkdownZip(data []byte) ([]ImportedNote, []ItemReport, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ZIP archive: %w", err)
+	}
+	if len(zr.File) > MaxEntries {
+		return nil, nil, ErrTooManyEntries
+	}
+
+	// Sizes in the archive's headers can lie, so count what is read
+	budget := int64(MaxTotalSize)
+	var notes []ImportedNote
+	var skips []ItemReport
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) == ManifestName {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(f.Name))
+		if ext != ".md" && ext != ".markdown" {
+			skips = append(skips, skipped(f.Name, "not a Markdown file"))
+			continue
+		}
+
+		content, err := readEntry(f, budget)
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return nil, nil, err
+		}
+		if err != nil {
+			skips = append(skips, skipped(f.Name, err.Error()))
+			continue
+		}
+		budget -= int64(len(content))
+
+		note, err := parseMarkdownNote(f.Name, content)
+		if err != nil {
+			skips = append(skips, skipped(f.Name, err.Error()))
+			continue
+		}
+		if note.UpdatedAt.IsZero() {
+			note.UpdatedAt = f.Modified
+		}
+		notes = append(notes, note)
+	}
+	return notes, skips, nil
+}
+
+// readEntry reads one entry, failing with ErrArchiveTooLarge when it does not
+// fit in what is left of the archive's budget
+func readEntry(f *zip.File, budget int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.New("unreadable entry")
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, min(MaxEntrySize, budget)+1))
+	if err != nil {
+		return nil, errors.New("unreadable entry")
+	}
+	if len(content) > MaxEntrySize {
+		return nil, errors.New("file is too large")
+	}
+	if int64(len(content)) > budget {
+		return nil, ErrArchiveTooLarge
+	}
+	if !utf8.Valid(content) {
+		return nil, errors.New("file is not valid UTF-8")
+	}
+	return content, nil
+}
+
+// importFrontMatter accepts the fields written by exports and common
+// variants used by other tools
+type importFrontMatter struct {
+	Title     string    `yaml:"title"`
+	Tags      []string  `yaml:"tags"`
+	CreatedAt time.Time `yaml:"createdAt"`
+	UpdatedAt time.Time `yaml:"updatedAt"`
+	Created   time.Time `yaml:"created"`
+	Updated   time.Time `yaml:"updated"`
+}
+
+func parseMarkdownNote(name string, content []byte) (ImportedNote, error) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	note := ImportedNote{Source: name}
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		header, body, found := strings.Cut(rest, "\n---")
+		if !found {
+			return note, errors.New("front matter is not closed")
+		}
+		var fm importFrontMatter
+		if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+			return note, errors.New("invalid front matter")
+		}
+		note.Title = fm.Title
+		note.Tags = fm.Tags
+		note.CreatedAt = firstTime(fm.CreatedAt, fm.Created)
+		note.UpdatedAt = firstTime(fm.UpdatedAt, fm.Updated)
+		// Drop the rest of the closing delimiter line
+		if _, after, ok := strings.Cut(body, "\n"); ok {
+			text = after
+		} else {
+			text = ""
+		}
+	}
+
+	note.Body = strings.TrimSpace(text)
+	if note.Title == "" {
+		note.Title = titleFromBody(note.Body)
+	}
+	if note.Title == "" {
+		note.Title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+	return note, nil
+}
+
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// titleFromBody returns the text of the first level-one heading
+func titleFromBody(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if heading, ok := strings.CutPrefix(line, "# "); ok {
+			return strings.TrimSpace(heading)
+		}
+	}
+	return ""
+}
+
+// enexNote mirrors a <note> element of an Evernote export
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+}
+
+// enexTimeLayout is the timestamp format used in ENEX files
+const enexTimeLayout = "20060102T150405Z"
+
+// ReadENEX reads the notes of an Evernote export, converting their ENML
+// content to Markdown. Notes that cannot be decoded are reported as skipped.
+func ReadENEX(r io.Reader) ([]ImportedNote, []ItemReport, error) {
+	dec := xml.NewDecoder(r)
+	// ENEX files reference the Evernote DTD, which is not needed to parse them
+	dec.Strict = false
+
+	var notes []ImportedNote
+	var skips []ItemReport
+	index := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if index == 0 {
+				return nil, nil, fmt.Errorf("invalid ENEX file: %w", err)
+			}
+			skips = append(skips, skipped(fmt.Sprintf("note #%d", index+1), "file is truncated"))
+			break
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		index++
+		source := fmt.Sprintf("note #%d", index)
+
+		var en enexNote
+		if err := dec.DecodeElement(&en, &start); err != nil {
+			skips = append(skips, skipped(source, "malformed note element"))
+			continue
+		}
+		if strings.TrimSpace(en.Title) != "" {
+			source = fmt.Sprintf("%s (%s)", source, strings.TrimSpace(en.Title))
+		}
+
+		note, err := convertENEXNote(source, en)
+		if err != nil {
+			skips = append(skips, skipped(source, err.Error()))
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	if index == 0 {
+		return nil, nil, errors.New("invalid ENEX file: no notes found")
+	}
+	return notes, skips, nil
+}
+
+func convertENEXNote(source string, en enexNote) (ImportedNote, error) {
+	title := strings.TrimSpace(en.Title)
+	if title == "" {
+		return ImportedNote{}, errors.New("note has no title")
+	}
+
+	body, err := markdown.FromHTML(en.Content)
+	if err != nil {
+		return ImportedNote{}, errors.New("note content could not be converted")
+	}
+
+	note := ImportedNote{
+		Source: source,
+		Title:  title,
+		Body:   body,
+		Tags:   en.Tags,
+	}
+	if t, err := time.Parse(enexTimeLayout, strings.TrimSpace(en.Created)); err == nil {
+		note.CreatedAt = t
+	}
+	if t, err := time.Parse(enexTimeLayout, strings.TrimSpace(en.Updated)); err == nil {
+		note.UpdatedAt = t
+	}
+	return note, nil
+}
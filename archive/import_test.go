@@ -0,0 +1,59 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// zipOf builds an archive with the given number of Markdown entries, each
+// holding size bytes
+func zipOf(t *testing.T, entries, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	body := strings.Repeat("a", size)
+	for i := range entries {
+		w, err := zw.Create(fmt.Sprintf("note-%d.md", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadMarkdownZipLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		notes   int
+		skipped int
+		err     error
+	}{
+		{"within the limits", zipOf(t, 3, 10), 3, 0, nil},
+		{"an entry over the entry size", zipOf(t, 1, MaxEntrySize+1), 0, 1, nil},
+		{"at the entry count", zipOf(t, MaxEntries, 1), MaxEntries, 0, nil},
+		{"over the entry count", zipOf(t, MaxEntries+1, 1), 0, 0, ErrTooManyEntries},
+		{"over the total size", zipOf(t, MaxTotalSize/MaxEntrySize+1, MaxEntrySize), 0, 0, ErrArchiveTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, skips, err := ReadMarkdownZip(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(notes) != tt.notes || len(skips) != tt.skipped {
+				t.Fatalf("read %d notes and skipped %d, want %d and %d", len(notes), len(skips), tt.notes, tt.skipped)
+			}
+		})
+	}
+}
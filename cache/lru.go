@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// lruEntry is a value in an LRUStore and the time it expires
+type lruEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// LRUStore keeps values in memory, evicting the least recently used ones
+// once it holds size entries. Each server process has its own.
+type LRUStore struct {
+	entries *lru.Cache[string, lruEntry]
+}
+
+// NewLRUStore creates an in-memory store holding up to size entries
+func NewLRUStore(size int) (*LRUStore, error) {
+	entries, err := lru.New[string, lruEntry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &LRUStore{entries: entries}, nil
+}
+
+// Get returns the value stored under key unless it has expired
+func (s *LRUStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	entry, ok := s.entries.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		s.entries.Remove(key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set stores value under key until ttl passes
+func (s *LRUStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.entries.Add(key, lruEntry{value: value, expires: time.Now().Add(ttl)})
+	return nil
+}
+
+// Delete removes the values stored under keys
+func (s *LRUStore) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		s.entries.Remove(key)
+	}
+	return nil
+}
+
+// DeletePrefix removes the values stored under keys starting with prefix
+func (s *LRUStore) DeletePrefix(ctx context.Context, prefix string) error {
+	for _, key := range s.entries.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			s.entries.Remove(key)
+		}
+	}
+	return nil
+}
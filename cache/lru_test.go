@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUStoreGetSetDelete(t *testing.T) {
+	store, err := NewLRUStore(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, ok, _ := store.Get(ctx, "missing"); ok {
+		t.Fatal("expected a miss for an unknown key")
+	}
+	store.Set(ctx, "a", []byte("1"), time.Minute)
+	store.Set(ctx, "b", []byte("2"), time.Minute)
+	if value, ok, _ := store.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v; want \"1\", true", value, ok)
+	}
+
+	store.Delete(ctx, "a", "b", "missing")
+	for _, key := range []string{"a", "b"} {
+		if _, ok, _ := store.Get(ctx, key); ok {
+			t.Fatalf("expected %s to be deleted", key)
+		}
+	}
+}
+
+func TestLRUStoreExpiresEntries(t *testing.T) {
+	store, _ := NewLRUStore(10)
+	ctx := context.Background()
+
+	store.Set(ctx, "short", []byte("x"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "short"); ok {
+		t.Fatal("expected an expired entry to miss")
+	}
+}
+
+func TestLRUStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store, _ := NewLRUStore(2)
+	ctx := context.Background()
+
+	store.Set(ctx, "a", []byte("1"), time.Minute)
+	store.Set(ctx, "b", []byte("2"), time.Minute)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := store.Get(ctx, key); !ok {
+			t.Fatalf("expected %s to be kept", key)
+		}
+	}
+}
+
+func TestLRUStoreDeletePrefix(t *testing.T) {
+	store, err := NewLRUStore(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"access:1", "access:2", "assets:user:1"} {
+		store.Set(ctx, key, []byte("x"), time.Minute)
+	}
+	store.DeletePrefix(ctx, "access:")
+	for key, want := range map[string]bool{"access:1": false, "access:2": false, "assets:user:1": true} {
+		if _, ok, _ := store.Get(ctx, key); ok != want {
+			t.Fatalf("Get(%s) ok = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counts are the operations a Metered store has seen for one kind of key
+type Counts struct {
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Sets          uint64  `json:"sets"`
+	Invalidations uint64  `json:"invalidations"`
+	Errors        uint64  `json:"errors"`
+	HitRatio      float64 `json:"hitRatio"`
+}
+
+// counters is the live, atomically updated form of Counts
+type counters struct {
+	hits, misses, sets, invalidations, errors atomic.Uint64
+}
+
+// Metered wraps a store and counts hits, misses, sets, invalidations and
+// errors for each kind of key. A key's kind is the part before its first
+// colon, so "assets:user:7" counts towards "assets".
+type Metered struct {
+	store   Store
+	backend string
+	kinds   sync.Map // kind -> *counters
+}
+
+// NewMetered wraps store; backend names it in Stats
+func NewMetered(store Store, backend string) *Metered {
+	return &Metered{store: store, backend: backend}
+}
+
+// Backend names the wrapped store, such as "lru" or "redis"
+func (m *Metered) Backend() string {
+	return m.backend
+}
+
+func (m *Metered) counters(key string) *counters {
+	kind, _, _ := strings.Cut(key, ":")
+	if c, ok := m.kinds.Load(kind); ok {
+		return c.(*counters)
+	}
+	c, _ := m.kinds.LoadOrStore(kind, &counters{})
+	return c.(*counters)
+}
+
+// Get returns the value stored under key, counting a hit or a miss. Store
+// errors count as misses as well as errors.
+func (m *Metered) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c := m.counters(key)
+	value, ok, err := m.store.Get(ctx, key)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return value, ok, err
+}
+
+// Set stores value under key
+func (m *Metered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c := m.counters(key)
+	c.sets.Add(1)
+	err := m.store.Set(ctx, key, value, ttl)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	return err
+}
+
+// Delete removes the values stored under keys, counting an invalidation for
+// each key whether or not a value was stored
+func (m *Metered) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		m.counters(key).invalidations.Add(1)
+	}
+	err := m.store.Delete(ctx, keys...)
+	if err != nil && len(keys) > 0 {
+		m.counters(keys[0]).errors.Add(1)
+	}
+	return err
+}
+
+// DeletePrefix removes the values stored under keys starting with prefix,
+// counting one invalidation for the prefix's kind of key
+func (m *Metered) DeletePrefix(ctx context.Context, prefix string) error {
+	c := m.counters(prefix)
+	c.invalidations.Add(1)
+	err := m.store.DeletePrefix(ctx, prefix)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	return err
+}
+
+// Stats returns the counts for each kind of key seen so far
+func (m *Metered) Stats() map[string]Counts {
+	stats := make(map[string]Counts)
+	m.kinds.Range(func(kind, value interface{}) bool {
+		c := value.(*counters)
+		counts := Counts{
+			Hits:          c.hits.Load(),
+			Misses:        c.misses.Load(),
+			Sets:          c.sets.Load(),
+			Invalidations: c.invalidations.Load(),
+			Errors:        c.errors.Load(),
+		}
+		if lookups := counts.Hits + counts.Misses; lookups > 0 {
+			counts.HitRatio = float64(counts.Hits) / float64(lookups)
+		}
+		stats[kind.(string)] = counts
+		return true
+	})
+	return stats
+}
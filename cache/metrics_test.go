@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMeteredCountsByKind(t *testing.T) {
+	store, _ := NewLRUStore(10)
+	metered := NewMetered(store, "lru")
+	ctx := context.Background()
+
+	metered.Get(ctx, "assets:user:1")
+	metered.Set(ctx, "assets:user:1", []byte("[]"), time.Minute)
+	metered.Get(ctx, "assets:user:1")
+	metered.Get(ctx, "assets:user:1")
+	metered.Delete(ctx, "assets:user:1", "access:1:note:2")
+
+	stats := metered.Stats()
+	assets := stats["assets"]
+	if assets.Hits != 2 || assets.Misses != 1 || assets.Sets != 1 || assets.Invalidations != 1 {
+		t.Fatalf("assets counts = %+v", assets)
+	}
+	if ratio := assets.HitRatio; ratio < 0.66 || ratio > 0.67 {
+		t.Fatalf("assets hit ratio = %v, want 2/3", ratio)
+	}
+	if access := stats["access"]; access.Invalidations != 1 || access.Hits != 0 {
+		t.Fatalf("access counts = %+v", access)
+	}
+	if metered.Backend() != "lru" {
+		t.Fatalf("Backend() = %q", metered.Backend())
+	}
+}
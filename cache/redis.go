@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig holds the connection settings for a Redis server
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Prefix   string // prepended to every key, so several apps can share a server
+}
+
+// RedisStore keeps values in Redis, so every server process shares them
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the server and checks that it answers
+func NewRedisStore(ctx context.Context, cfg RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &RedisStore{client: client, prefix: cfg.Prefix}, nil
+}
+
+// Get returns the value stored under key
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value under key; Redis expires it after ttl
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+// redisDeleteBatch caps the keys sent in one DEL, so invalidating many keys
+// does not block the server on a single huge command
+const redisDeleteBatch = 1000
+
+// Delete removes the values stored under keys, pipelining one DEL per batch
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := s.client.Pipeline()
+	for start := 0; start < len(keys); start += redisDeleteBatch {
+		end := min(start+redisDeleteBatch, len(keys))
+		prefixed := make([]string, 0, end-start)
+		for _, key := range keys[start:end] {
+			prefixed = append(prefixed, s.prefix+key)
+		}
+		pipe.Del(ctx, prefixed...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeletePrefix removes the values stored under keys starting with prefix.
+// It walks the matching keys with SCAN rather than KEYS, so the server keeps
+// answering, and deletes them a batch at a time.
+func (s *RedisStore) DeletePrefix(ctx context.Context, prefix string) error {
+	pattern := globEscaper.Replace(s.prefix+prefix) + "*"
+	iter := s.client.Scan(ctx, 0, pattern, redisDeleteBatch).Iterator()
+	batch := make([]string, 0, redisDeleteBatch)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == redisDeleteBatch {
+			if err := s.client.Del(ctx, batch...).Err(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return s.client.Del(ctx, batch...).Err()
+	}
+	return nil
+}
+
+// globEscaper escapes the characters SCAN patterns treat specially
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Close closes the connection to the server
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
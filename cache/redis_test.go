@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testRedisStore connects to the server at REDIS_ADDR (a local redis-server
+// by default) and skips the test when none answers. Keys are prefixed per
+// test, so the server's other data is left alone.
+func testRedisStore(t *testing.T) *RedisStore {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	store, err := NewRedisStore(ctx, RedisConfig{
+		Addr:   addr,
+		Prefix: fmt.Sprintf("go-apis-test:%s:%d:", t.Name(), time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Skipf("no redis server at %s: %v", addr, err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRedisStoreGetSetDelete(t *testing.T) {
+	store := testRedisStore(t)
+	ctx := context.Background()
+
+	if _, ok, err := store.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v; want a miss", ok, err)
+	}
+	if err := store.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := store.Get(ctx, "a"); !ok || err != nil || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v, %v; want \"1\"", value, ok, err)
+	}
+
+	if err := store.Delete(ctx, "a", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Get(ctx, "a"); ok {
+		t.Fatal("expected a to be deleted")
+	}
+}
+
+func TestRedisStoreExpiresEntries(t *testing.T) {
+	store := testRedisStore(t)
+	ctx := context.Background()
+
+	store.Set(ctx, "short", []byte("x"), 50*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "short"); ok {
+		t.Fatal("expected an expired entry to miss")
+	}
+}
+
+func TestRedisStoreDeletesInBatches(t *testing.T) {
+	store := testRedisStore(t)
+	ctx := context.Background()
+
+	keys := make([]string, 2*redisDeleteBatch+1)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+		if err := store.Set(ctx, keys[i], []byte("v"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete(ctx, keys...); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{keys[0], keys[redisDeleteBatch], keys[len(keys)-1]} {
+		if _, ok, _ := store.Get(ctx, key); ok {
+			t.Fatalf("expected %s to be deleted", key)
+		}
+	}
+}
+
+func TestRedisStoreDeletePrefix(t *testing.T) {
+	store := testRedisStore(t)
+	ctx := context.Background()
+
+	keys := make([]string, redisDeleteBatch+1)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("access:%d", i)
+		if err := store.Set(ctx, keys[i], []byte("v"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Glob characters in the prefix match only themselves
+	store.Set(ctx, "acce*", []byte("v"), time.Minute)
+	store.Set(ctx, "assets:user:1", []byte("v"), time.Minute)
+
+	if err := store.DeletePrefix(ctx, "access:"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{keys[0], keys[len(keys)-1]} {
+		if _, ok, _ := store.Get(ctx, key); ok {
+			t.Fatalf("expected %s to be deleted", key)
+		}
+	}
+	if err := store.DeletePrefix(ctx, "acce*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Get(ctx, "assets:user:1"); !ok {
+		t.Fatal("DeletePrefix removed a key outside the prefix")
+	}
+	if _, ok, _ := store.Get(ctx, "acce*"); ok {
+		t.Fatal("expected acce* to be deleted")
+	}
+}
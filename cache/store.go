@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps short-lived values by key
+type Store interface {
+	// Get returns the value stored under key; ok is false on a miss
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key until ttl passes
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the values stored under keys, if any
+	Delete(ctx context.Context, keys ...string) error
+	// DeletePrefix removes every value stored under a key starting with
+	// prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
@@ -0,0 +1,71 @@
+package collab
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Anchor is a range of a note body that a comment refers to. Offsets are
+// counted in Unicode code points, like operations, and Text is the content
+// of the range when the anchor was last placed.
+type Anchor struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// NewAnchor places an anchor on a range of the body. ok is false if the range
+// is empty or falls outside the body.
+func NewAnchor(body string, start, end int) (anchor Anchor, ok bool) {
+	runes := []rune(body)
+	if start < 0 || end <= start || end > len(runes) {
+		return Anchor{}, false
+	}
+	return Anchor{Start: start, End: end, Text: string(runes[start:end])}, true
+}
+
+// Relocate finds an anchor again after the body was edited. The range is kept
+// if it still holds the anchored text, otherwise the occurrence of the text
+// closest to the old position is used. ok is false when the text is gone.
+func Relocate(body string, anchor Anchor) (relocated Anchor, ok bool) {
+	runes := []rune(body)
+	if anchor.Text == "" {
+		return Anchor{}, false
+	}
+	if anchor.Start >= 0 && anchor.End <= len(runes) && anchor.Start < anchor.End &&
+		string(runes[anchor.Start:anchor.End]) == anchor.Text {
+		return anchor, true
+	}
+
+	length := len([]rune(anchor.Text))
+	best := -1
+	byteOffset, runeOffset := 0, 0
+	for {
+		i := strings.Index(body[byteOffset:], anchor.Text)
+		if i < 0 {
+			break
+		}
+		runeOffset += len([]rune(body[byteOffset : byteOffset+i]))
+		byteOffset += i
+		if best < 0 || distance(runeOffset, anchor.Start) < distance(best, anchor.Start) {
+			best = runeOffset
+		}
+
+		// Step past the first character of the match so overlapping
+		// occurrences are found too
+		_, size := utf8.DecodeRuneInString(body[byteOffset:])
+		byteOffset += size
+		runeOffset++
+	}
+	if best < 0 {
+		return Anchor{}, false
+	}
+	return Anchor{Start: best, End: best + length, Text: anchor.Text}, true
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
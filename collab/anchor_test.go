@@ -0,0 +1,60 @@
+package collab
+
+import "testing"
+
+func TestNewAnchor(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		start, end int
+		want       Anchor
+		wantOK     bool
+	}{
+		{"ascii range", "hello world", 6, 11, Anchor{6, 11, "world"}, true},
+		{"multi-byte range", "héllo wörld", 6, 11, Anchor{6, 11, "wörld"}, true},
+		{"emoji", "ok 👍 done", 3, 4, Anchor{3, 4, "👍"}, true},
+		{"empty range", "hello", 2, 2, Anchor{}, false},
+		{"reversed range", "hello", 3, 1, Anchor{}, false},
+		{"negative start", "hello", -1, 2, Anchor{}, false},
+		{"past the end in code points", "héllo", 0, 6, Anchor{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewAnchor(tt.body, tt.start, tt.end)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NewAnchor() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		anchor Anchor
+		want   Anchor
+		wantOK bool
+	}{
+		{"unchanged", "hello world", Anchor{6, 11, "world"}, Anchor{6, 11, "world"}, true},
+		{"shifted by an insert", "say hello world", Anchor{6, 11, "world"}, Anchor{10, 15, "world"}, true},
+		{"shifted by a delete", "world", Anchor{6, 11, "world"}, Anchor{0, 5, "world"}, true},
+		{"only other text", "ab ab ab ab", Anchor{7, 9, "ba"}, Anchor{}, false},
+		{"closest occurrence wins", "ab cd ab cd ab", Anchor{7, 9, "ab"}, Anchor{6, 8, "ab"}, true},
+		{"ties keep the earlier one", "ab-ab", Anchor{1, 3, "ab"}, Anchor{0, 2, "ab"}, true},
+		{"overlapping matches", "xaaax", Anchor{3, 5, "aa"}, Anchor{2, 4, "aa"}, true},
+		{"multi-byte text before", "héllo wörld wörld", Anchor{11, 16, "wörld"}, Anchor{12, 17, "wörld"}, true},
+		{"multi-byte anchor text", "😀😀 naïve", Anchor{0, 5, "naïve"}, Anchor{3, 8, "naïve"}, true},
+		{"range past the new end", "hi", Anchor{10, 12, "hi"}, Anchor{0, 2, "hi"}, true},
+		{"text gone", "hello", Anchor{0, 5, "world"}, Anchor{}, false},
+		{"empty text", "hello", Anchor{0, 0, ""}, Anchor{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Relocate(tt.body, tt.anchor)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Relocate() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package collab
+
+import (
+	"sync"
+	"time"
+)
+
+// SnapshotInterval is how often a live document is written back to the note
+const SnapshotInterval = 10 * time.Second
+
+// PersistFunc stores a snapshot of a note body
+type PersistFunc func(noteID uint, body string) error
+
+// LoadFunc reads the stored body of a note when a session starts
+type LoadFunc func(noteID uint) (string, error)
+
+// Hub keeps one editing session per note for as long as clients are connected
+type Hub struct {
+	mu       sync.Mutex
+	sessions map[uint]*Session
+	load     LoadFunc
+	persist  PersistFunc
+	interval time.Duration
+}
+
+// NewHub creates a hub that loads and persists note bodies with the given functions
+func NewHub(load LoadFunc, persist PersistFunc) *Hub {
+	return &Hub{
+		sessions: make(map[uint]*Session),
+		load:     load,
+		persist:  persist,
+		interval: SnapshotInterval,
+	}
+}
+
+// Join adds a client to the note's session, starting one if needed
+func (h *Hub) Join(noteID uint, client *Client) (*Session, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	session, ok := h.sessions[noteID]
+	if !ok {
+		body, err := h.load(noteID)
+		if err != nil {
+			return nil, err
+		}
+		session = newSession(noteID, body, h.persist, h.interval)
+		h.sessions[noteID] = session
+	}
+
+	session.join(client)
+	return session, nil
+}
+
+// Leave removes a client and closes the session once the last client is gone
+func (h *Hub) Leave(session *Session, client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if session.leave(client) == 0 && h.sessions[session.NoteID] == session {
+		delete(h.sessions, session.NoteID)
+		session.close()
+	}
+}
+
+// Active reports whether a note currently has a live editing session
+func (h *Hub) Active(noteID uint) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.sessions[noteID]
+	return ok
+}
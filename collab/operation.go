@@ -0,0 +1,208 @@
+package collab
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// ErrOperationMismatch is returned when an operation does not fit the document
+// or the concurrent operation it is transformed against
+var ErrOperationMismatch = errors.New("operation does not match document length")
+
+// Component is a single step of an operation. Exactly one field is set:
+// Retain skips characters, Insert adds text and Delete removes characters.
+// Lengths are counted in Unicode code points.
+type Component struct {
+	Retain int    `json:"retain,omitempty"`
+	Insert string `json:"insert,omitempty"`
+	Delete int    `json:"delete,omitempty"`
+}
+
+// Operation is a sequence of components that must span the whole document
+type Operation []Component
+
+// Valid reports whether every component sets exactly one positive field
+func (op Operation) Valid() bool {
+	for _, c := range op {
+		set := 0
+		if c.Retain != 0 {
+			set++
+		}
+		if c.Insert != "" {
+			set++
+		}
+		if c.Delete != 0 {
+			set++
+		}
+		if set != 1 || c.Retain < 0 || c.Delete < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BaseLength is the length of the document the operation applies to
+func (op Operation) BaseLength() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// Apply runs the operation against a document and returns the result
+func (op Operation) Apply(doc string) (string, error) {
+	runes := []rune(doc)
+	if op.BaseLength() != len(runes) {
+		return "", ErrOperationMismatch
+	}
+
+	out := make([]rune, 0, len(runes))
+	pos := 0
+	for _, c := range op {
+		switch {
+		case c.Retain > 0:
+			out = append(out, runes[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Insert != "":
+			out = append(out, []rune(c.Insert)...)
+		case c.Delete > 0:
+			pos += c.Delete
+		}
+	}
+	return string(out), nil
+}
+
+// Transform takes two operations a and b that were made concurrently on the
+// same document and returns a' and b' such that applying a then b' gives the
+// same result as applying b then a'. Inserts from a win ties, so a should be
+// the operation that the server already accepted.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, ErrOperationMismatch
+	}
+
+	var aPrime, bPrime builder
+	ia, ib := newCursor(a), newCursor(b)
+
+	for !ia.done() || !ib.done() {
+		ca, cb := ia.peek(), ib.peek()
+
+		if ca != nil && ca.Insert != "" {
+			aPrime.insert(ca.Insert)
+			bPrime.retain(utf8.RuneCountInString(ca.Insert))
+			ia.next()
+			continue
+		}
+		if cb != nil && cb.Insert != "" {
+			aPrime.retain(utf8.RuneCountInString(cb.Insert))
+			bPrime.insert(cb.Insert)
+			ib.next()
+			continue
+		}
+		if ca == nil || cb == nil {
+			return nil, nil, ErrOperationMismatch
+		}
+
+		n := min(ia.remaining(), ib.remaining())
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			aPrime.retain(n)
+			bPrime.retain(n)
+		case ca.Delete > 0 && cb.Retain > 0:
+			aPrime.delete(n)
+		case ca.Retain > 0 && cb.Delete > 0:
+			bPrime.delete(n)
+		}
+		// When both sides delete the same span there is nothing left to do
+		ia.consume(n)
+		ib.consume(n)
+	}
+
+	return aPrime.ops, bPrime.ops, nil
+}
+
+// cursor walks an operation, allowing retain and delete components to be
+// consumed partially
+type cursor struct {
+	op     Operation
+	index  int
+	offset int
+}
+
+func newCursor(op Operation) *cursor {
+	return &cursor{op: op}
+}
+
+func (c *cursor) done() bool {
+	return c.index >= len(c.op)
+}
+
+func (c *cursor) peek() *Component {
+	if c.done() {
+		return nil
+	}
+	return &c.op[c.index]
+}
+
+func (c *cursor) next() {
+	c.index++
+	c.offset = 0
+}
+
+func (c *cursor) remaining() int {
+	comp := c.op[c.index]
+	return comp.Retain + comp.Delete - c.offset
+}
+
+func (c *cursor) consume(n int) {
+	c.offset += n
+	if c.remaining() == 0 {
+		c.next()
+	}
+}
+
+// builder assembles an operation, merging adjacent components of the same kind
+type builder struct {
+	ops Operation
+}
+
+func (b *builder) last() *Component {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	return &b.ops[len(b.ops)-1]
+}
+
+func (b *builder) retain(n int) {
+	if n == 0 {
+		return
+	}
+	if last := b.last(); last != nil && last.Retain > 0 {
+		last.Retain += n
+		return
+	}
+	b.ops = append(b.ops, Component{Retain: n})
+}
+
+func (b *builder) insert(s string) {
+	if s == "" {
+		return
+	}
+	if last := b.last(); last != nil && last.Insert != "" {
+		last.Insert += s
+		return
+	}
+	b.ops = append(b.ops, Component{Insert: s})
+}
+
+func (b *builder) delete(n int) {
+	if n == 0 {
+		return
+	}
+	if last := b.last(); last != nil && last.Delete > 0 {
+		last.Delete += n
+		return
+	}
+	b.ops = append(b.ops, Component{Delete: n})
+}
@@ -0,0 +1,186 @@
+package collab
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOperationValid(t *testing.T) {
+	tests := []struct {
+		name string
+		op   Operation
+		want bool
+	}{
+		{"empty", Operation{}, true},
+		{"one field each", Operation{{Retain: 2}, {Insert: "x"}, {Delete: 1}}, true},
+		{"no field", Operation{{}}, false},
+		{"two fields", Operation{{Retain: 1, Insert: "x"}}, false},
+		{"negative retain", Operation{{Retain: -1}}, false},
+		{"negative delete", Operation{{Delete: -2}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.op.Valid(); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		op      Operation
+		want    string
+		wantErr error
+	}{
+		{"retain all", "hello", Operation{{Retain: 5}}, "hello", nil},
+		{"insert at start", "world", Operation{{Insert: "hello "}, {Retain: 5}}, "hello world", nil},
+		{"insert at end", "hello", Operation{{Retain: 5}, {Insert: "!"}}, "hello!", nil},
+		{"delete middle", "hello", Operation{{Retain: 1}, {Delete: 3}, {Retain: 1}}, "ho", nil},
+		{"replace", "cat", Operation{{Delete: 1}, {Insert: "b"}, {Retain: 2}}, "bat", nil},
+		{"empty document", "", Operation{{Insert: "new"}}, "new", nil},
+		{"counts code points", "héllo 👋", Operation{{Retain: 1}, {Delete: 1}, {Insert: "e"}, {Retain: 4}, {Delete: 1}, {Insert: "🌍"}}, "hello 🌍", nil},
+		{"too short", "hello", Operation{{Retain: 4}}, "", ErrOperationMismatch},
+		{"too long", "hello", Operation{{Retain: 3}, {Delete: 3}}, "", ErrOperationMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op.Apply(tt.doc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a, b Operation
+		want string
+	}{
+		{
+			name: "inserts at different places",
+			doc:  "abc",
+			a:    Operation{{Insert: "x"}, {Retain: 3}},
+			b:    Operation{{Retain: 3}, {Insert: "y"}},
+			want: "xabcy",
+		},
+		{
+			name: "inserts at the same place put a first",
+			doc:  "abc",
+			a:    Operation{{Retain: 1}, {Insert: "A"}, {Retain: 2}},
+			b:    Operation{{Retain: 1}, {Insert: "B"}, {Retain: 2}},
+			want: "aABbc",
+		},
+		{
+			name: "insert inside a deleted span",
+			doc:  "abcdef",
+			a:    Operation{{Retain: 1}, {Delete: 4}, {Retain: 1}},
+			b:    Operation{{Retain: 3}, {Insert: "X"}, {Retain: 3}},
+			want: "aXf",
+		},
+		{
+			name: "same span deleted twice",
+			doc:  "abcdef",
+			a:    Operation{{Retain: 2}, {Delete: 2}, {Retain: 2}},
+			b:    Operation{{Retain: 2}, {Delete: 2}, {Retain: 2}},
+			want: "abef",
+		},
+		{
+			name: "overlapping deletes",
+			doc:  "abcdef",
+			a:    Operation{{Retain: 1}, {Delete: 3}, {Retain: 2}},
+			b:    Operation{{Retain: 2}, {Delete: 3}, {Retain: 1}},
+			want: "af",
+		},
+		{
+			name: "delete against retain",
+			doc:  "hello world",
+			a:    Operation{{Delete: 6}, {Retain: 5}},
+			b:    Operation{{Retain: 11}},
+			want: "world",
+		},
+		{
+			name: "replacements on both sides",
+			doc:  "the cat sat",
+			a:    Operation{{Retain: 4}, {Delete: 3}, {Insert: "dog"}, {Retain: 4}},
+			b:    Operation{{Retain: 8}, {Delete: 3}, {Insert: "ran"}},
+			want: "the dog ran",
+		},
+		{
+			name: "code points",
+			doc:  "👋🌍",
+			a:    Operation{{Insert: "é"}, {Retain: 2}},
+			b:    Operation{{Retain: 1}, {Delete: 1}, {Insert: "ü"}},
+			want: "é👋ü",
+		},
+		{
+			name: "empty document",
+			doc:  "",
+			a:    Operation{{Insert: "a"}},
+			b:    Operation{{Insert: "b"}},
+			want: "ab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aPrime, bPrime, err := Transform(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Transform() error = %v", err)
+			}
+
+			// a then b' and b then a' must converge
+			afterA, err := tt.a.Apply(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			viaA, err := bPrime.Apply(afterA)
+			if err != nil {
+				t.Fatalf("b' does not apply after a: %v", err)
+			}
+			afterB, err := tt.b.Apply(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			viaB, err := aPrime.Apply(afterB)
+			if err != nil {
+				t.Fatalf("a' does not apply after b: %v", err)
+			}
+
+			if viaA != tt.want || viaB != tt.want {
+				t.Errorf("a then b' = %q, b then a' = %q, want %q", viaA, viaB, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformMergesComponents(t *testing.T) {
+	a := Operation{{Retain: 2}, {Insert: "x"}, {Retain: 2}}
+	b := Operation{{Retain: 4}}
+	aPrime, bPrime, err := Transform(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Operation{{Retain: 2}, {Insert: "x"}, {Retain: 2}}); !reflect.DeepEqual(aPrime, want) {
+		t.Errorf("a' = %v, want %v", aPrime, want)
+	}
+	if want := (Operation{{Retain: 5}}); !reflect.DeepEqual(bPrime, want) {
+		t.Errorf("b' = %v, want %v", bPrime, want)
+	}
+}
+
+func TestTransformRejectsDifferentBases(t *testing.T) {
+	a := Operation{{Retain: 3}}
+	b := Operation{{Retain: 4}}
+	if _, _, err := Transform(a, b); !errors.Is(err, ErrOperationMismatch) {
+		t.Fatalf("Transform() error = %v, want ErrOperationMismatch", err)
+	}
+}
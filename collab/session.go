@@ -0,0 +1,208 @@
+package collab
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrStaleRevision is returned when a client claims a revision the server has
+// not reached yet
+var ErrStaleRevision = errors.New("revision is ahead of the server document")
+
+// ErrClientDropped is returned when a client that fell behind and was removed
+// from the session keeps submitting operations
+var ErrClientDropped = errors.New("client is no longer part of the session")
+
+// Message is exchanged with editing clients over the WebSocket.
+//
+// Server to client: "init" carries the document and revision on join, "ack"
+// confirms the client's own operation, "op" relays someone else's operation
+// and "error" reports a rejected message.
+// Client to server: "op" submits an operation based on Revision.
+type Message struct {
+	Type      string    `json:"type"`
+	Revision  int       `json:"revision"`
+	Operation Operation `json:"operation,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	UserID    uint      `json:"userId,omitempty"`
+	ReadOnly  bool      `json:"readOnly,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Client is a connected editor. Outgoing messages are queued on Send.
+type Client struct {
+	UserID   uint
+	ReadOnly bool
+	Send     chan Message
+}
+
+// NewClient creates a client with a buffered outgoing queue
+func NewClient(userID uint, readOnly bool) *Client {
+	return &Client{
+		UserID:   userID,
+		ReadOnly: readOnly,
+		Send:     make(chan Message, 64),
+	}
+}
+
+// Session holds the authoritative copy of one note while it is being edited
+type Session struct {
+	NoteID uint
+
+	mu       sync.Mutex
+	doc      string
+	history  []Operation
+	clients  map[*Client]struct{}
+	dirty    bool
+	persist  PersistFunc
+	stopSave chan struct{}
+}
+
+func newSession(noteID uint, body string, persist PersistFunc, interval time.Duration) *Session {
+	s := &Session{
+		NoteID:   noteID,
+		doc:      body,
+		clients:  make(map[*Client]struct{}),
+		persist:  persist,
+		stopSave: make(chan struct{}),
+	}
+	go s.snapshotLoop(interval)
+	return s
+}
+
+// join registers a client and sends it the current document
+func (s *Session) join(client *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[client] = struct{}{}
+	client.Send <- Message{
+		Type:     "init",
+		Revision: len(s.history),
+		Body:     s.doc,
+		ReadOnly: client.ReadOnly,
+	}
+}
+
+// leave removes a client and reports how many remain
+func (s *Session) leave(client *Client) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client]; ok {
+		delete(s.clients, client)
+		close(client.Send)
+	}
+	return len(s.clients)
+}
+
+// Submit transforms an operation made against revision over everything the
+// server has accepted since, applies it, acknowledges the author and relays
+// the transformed operation to every other client
+func (s *Session) Submit(client *Client, revision int, op Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client]; !ok {
+		return ErrClientDropped
+	}
+	if revision < 0 || revision > len(s.history) {
+		return ErrStaleRevision
+	}
+	if !op.Valid() {
+		return ErrOperationMismatch
+	}
+
+	for _, concurrent := range s.history[revision:] {
+		var err error
+		if _, op, err = Transform(concurrent, op); err != nil {
+			return err
+		}
+	}
+
+	doc, err := op.Apply(s.doc)
+	if err != nil {
+		return err
+	}
+	s.doc = doc
+	s.history = append(s.history, op)
+	s.dirty = true
+
+	current := len(s.history)
+	for other := range s.clients {
+		msg := Message{Type: "op", Revision: current, Operation: op, UserID: client.UserID}
+		if other == client {
+			msg = Message{Type: "ack", Revision: current}
+		}
+		select {
+		case other.Send <- msg:
+		default:
+			// A client that cannot keep up would miss operations and
+			// diverge, so it is dropped and has to rejoin
+			delete(s.clients, other)
+			close(other.Send)
+		}
+	}
+	return nil
+}
+
+// Notify queues a message for a single client if it is still connected
+func (s *Session) Notify(client *Client, msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.Send <- msg:
+	default:
+	}
+}
+
+// Snapshot returns the current document and revision
+func (s *Session) Snapshot() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc, len(s.history)
+}
+
+// save persists the document if it changed since the last save
+func (s *Session) save() {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	doc := s.doc
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.persist(s.NoteID, doc); err != nil {
+		log.Printf("collab: failed to persist note %d: %v", s.NoteID, err)
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+}
+
+func (s *Session) snapshotLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-s.stopSave:
+			return
+		}
+	}
+}
+
+// close stops periodic snapshots and writes the final document
+func (s *Session) close() {
+	close(s.stopSave)
+	s.save()
+}
@@ -0,0 +1,16 @@
+package config
+
+import "os"
+
+// JWTSecret returns the secret shared with the user service for signing tokens
+func JWTSecret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// RequireJWTSecret stops the server from starting without JWT_SECRET. There
+// is no default: a secret known from the sources would let anyone sign tokens.
+func RequireJWTSecret() {
+	if os.Getenv("JWT_SECRET") == "" {
+		panic("JWT_SECRET must be set to the secret the user service signs tokens with")
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/seta-namnv-6798/go-apis/cache"
+)
+
+// Cache holds asset listings and access decisions. It stays nil until
+// ConnectCache runs, and callers then go straight to the database.
+var Cache *cache.Metered
+
+// ConnectCache sets up the cache selected by CACHE_STORE: "lru" (the
+// default) keeps up to CACHE_SIZE entries in memory, "redis" uses the server
+// at REDIS_ADDR so every process shares them, and "off" disables caching.
+//
+// An lru cache is only invalidated by changes made through its own process.
+// Run a single instance with it, or use redis (or off) whenever more than one
+// process serves the API, otherwise other instances keep serving revoked
+// access until their entries expire.
+func ConnectCache() {
+	var store cache.Store
+	var err error
+
+	backend := envOr("CACHE_STORE", "lru")
+	switch backend {
+	case "off":
+		return
+	case "redis":
+		db, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+		store, err = cache.NewRedisStore(context.Background(), cache.RedisConfig{
+			Addr:     envOr("REDIS_ADDR", "localhost:6379"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       db,
+			Prefix:   envOr("REDIS_PREFIX", "go-apis:"),
+		})
+	default:
+		backend = "lru"
+		size, convErr := strconv.Atoi(envOr("CACHE_SIZE", "10000"))
+		if convErr != nil {
+			panic(convErr)
+		}
+		store, err = cache.NewLRUStore(size)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	Cache = cache.NewMetered(store, backend)
+}
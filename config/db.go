@@ -34,5 +34,35 @@ func Connect() {
 	db.AutoMigrate(&models.FolderShare{})
 	db.AutoMigrate(&models.NoteShare{})
 
+	// 5. Attachments on notes
+	db.AutoMigrate(&models.Attachment{})
+	db.AutoMigrate(&models.AttachmentThumbnail{})
+
+	// 6. Tags on notes
+	db.AutoMigrate(&models.Tag{})
+	db.AutoMigrate(&models.NoteTag{})
+
+	// 7. Bookmarks and recently viewed assets
+	db.AutoMigrate(&models.Bookmark{})
+	db.AutoMigrate(&models.RecentView{})
+
+	// 8. Comments on notes
+	db.AutoMigrate(&models.Comment{})
+
+	// 9. Mentions and notifications
+	db.AutoMigrate(&models.Mention{})
+	db.AutoMigrate(&models.Notification{})
+
+	// 10. Team invitations
+	db.AutoMigrate(&models.TeamInvitation{})
+
+	// 11. Managers count as members: drop member rows that duplicate a
+	// manager row
+	db.Exec("DELETE FROM team_members WHERE EXISTS (SELECT 1 FROM team_managers " +
+		"WHERE team_managers.team_id = team_members.team_id AND team_managers.user_id = team_members.user_id)")
+
+	// 12. Effective access projection, filled in by the controllers
+	db.AutoMigrate(&models.EffectiveAccess{})
+
 	DB = db
 }
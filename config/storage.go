@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"os"
+
+	"github.com/seta-namnv-6798/go-apis/storage"
+)
+
+// Blobs stores note attachments
+var Blobs storage.BlobStore
+
+// ConnectStorage sets up the blob store selected by BLOB_STORE: "local"
+// (the default) keeps files under BLOB_LOCAL_DIR, "s3" uses an
+// S3-compatible service such as the MinIO container in docker-compose.yml
+func ConnectStorage() {
+	var store storage.BlobStore
+	var err error
+
+	switch os.Getenv("BLOB_STORE") {
+	case "s3":
+		store, err = storage.NewS3Store(context.Background(), storage.S3Config{
+			Endpoint:  envOr("S3_ENDPOINT", "localhost:9000"),
+			AccessKey: envOr("S3_ACCESS_KEY", "minioadmin"),
+			SecretKey: envOr("S3_SECRET_KEY", "minioadmin"),
+			Bucket:    envOr("S3_BUCKET", "attachments"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+		})
+	default:
+		store, err = storage.NewLocalStore(envOr("BLOB_LOCAL_DIR", "data/attachments"))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	Blobs = store
+}
+
+// AttachmentURLSecret returns the key used to sign attachment download URLs
+func AttachmentURLSecret() []byte {
+	if secret := os.Getenv("ATTACHMENT_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return JWTSecret()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// actionError is a failed action with the HTTP status it maps to. Reason,
+// if set, is a machine-readable code for the rule that was broken.
+type actionError struct {
+	Status  int
+	Message string
+	Reason  string
+}
+
+func (e *actionError) Error() string {
+	return e.Message
+}
+
+// failAction returns an error that responds with the given status
+func failAction(status int, message string) error {
+	return &actionError{Status: status, Message: message}
+}
+
+// failRule returns an error for a broken rule, with a machine-readable reason
+func failRule(status int, reason, message string) error {
+	return &actionError{Status: status, Message: message, Reason: reason}
+}
+
+// actionStatus returns the HTTP status for an action error. Errors that did
+// not come from failAction are internal errors.
+func actionStatus(err error) int {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// actionReason returns the machine-readable reason of an action error, if any
+func actionReason(err error) string {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Reason
+	}
+	return ""
+}
+
+// respondActionError writes an action error as a JSON error response
+func respondActionError(c *gin.Context, err error) {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) && actionErr.Reason != "" {
+		c.JSON(actionErr.Status, gin.H{"error": actionErr.Message, "reason": actionErr.Reason})
+		return
+	}
+	c.JSON(actionStatus(err), gin.H{"error": err.Error()})
+}
+
+// shareNoteWith grants or updates a user's share on a note. created is false
+// when an existing share was updated. viaTeamID records the team a new share
+// is granted on behalf of; an updated share keeps the team it had.
+func shareNoteWith(tx *gorm.DB, noteID, userID uint, access string, viaTeamID *uint) (share models.NoteShare, created bool, err error) {
+	var note models.Note
+	if err := tx.First(&note, noteID).Error; err != nil {
+		return share, false, failAction(http.StatusNotFound, "Note not found")
+	}
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return share, false, failAction(http.StatusNotFound, "User not found")
+	}
+
+	if err := tx.Where("note_id = ? AND user_id = ?", noteID, userID).First(&share).Error; err == nil {
+		share.Access = access
+		if err := tx.Save(&share).Error; err != nil {
+			return share, false, failAction(http.StatusInternalServerError, "Failed to update note share")
+		}
+		return share, false, refreshNoteAccess(tx, noteID, userID)
+	}
+
+	share = models.NoteShare{NoteID: noteID, UserID: userID, Access: access, GrantedViaTeamID: viaTeamID}
+	if err := tx.Create(&share).Error; err != nil {
+		return share, false, failAction(http.StatusInternalServerError, "Failed to share note")
+	}
+	return share, true, refreshNoteAccess(tx, noteID, userID)
+}
+
+// revokeNoteShareFrom removes a user's share on a note
+func revokeNoteShareFrom(tx *gorm.DB, noteID, userID uint) error {
+	var share models.NoteShare
+	if err := tx.Where("note_id = ? AND user_id = ?", noteID, userID).First(&share).Error; err != nil {
+		return failAction(http.StatusNotFound, "Note share not found")
+	}
+	if err := tx.Delete(&share).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to revoke note share")
+	}
+	return refreshNoteAccess(tx, noteID, userID)
+}
+
+// shareFolderWith grants or updates a user's share on a folder. created is
+// false when an existing share was updated. viaTeamID records the team a new
+// share is granted on behalf of; an updated share keeps the team it had.
+func shareFolderWith(tx *gorm.DB, folderID, userID uint, access string, viaTeamID *uint) (share models.FolderShare, created bool, err error) {
+	var folder models.Folder
+	if err := tx.First(&folder, folderID).Error; err != nil {
+		return share, false, failAction(http.StatusNotFound, "Folder not found")
+	}
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return share, false, failAction(http.StatusNotFound, "User not found")
+	}
+
+	if err := tx.Where("folder_id = ? AND user_id = ?", folderID, userID).First(&share).Error; err == nil {
+		share.Access = access
+		if err := tx.Save(&share).Error; err != nil {
+			return share, false, failAction(http.StatusInternalServerError, "Failed to update folder share")
+		}
+		return share, false, refreshFolderAccess(tx, folderID, userID)
+	}
+
+	share = models.FolderShare{FolderID: folderID, UserID: userID, Access: access, GrantedViaTeamID: viaTeamID}
+	if err := tx.Create(&share).Error; err != nil {
+		return share, false, failAction(http.StatusInternalServerError, "Failed to share folder")
+	}
+	return share, true, refreshFolderAccess(tx, folderID, userID)
+}
+
+// revokeFolderShareFrom removes a user's share on a folder
+func revokeFolderShareFrom(tx *gorm.DB, folderID, userID uint) error {
+	var share models.FolderShare
+	if err := tx.Where("folder_id = ? AND user_id = ?", folderID, userID).First(&share).Error; err != nil {
+		return failAction(http.StatusNotFound, "Folder share not found")
+	}
+	if err := tx.Delete(&share).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to revoke folder share")
+	}
+	return refreshFolderAccess(tx, folderID, userID)
+}
+
+// moveNoteTo moves a note into another folder. Users who could only read the
+// note through its old folder lose it, and once the move commits their
+// bookmarks, recent views and notifications for it go too.
+func moveNoteTo(tx *gorm.DB, note *models.Note, folderID uint) error {
+	var folder models.Folder
+	if err := tx.First(&folder, folderID).Error; err != nil {
+		return failAction(http.StatusNotFound, "Folder not found")
+	}
+
+	var before []uint
+	if err := tx.Model(&models.EffectiveAccess{}).Distinct("user_id").
+		Where("asset_type = ? AND asset_id = ?", models.AssetTypeNote, note.NoteID).Pluck("user_id", &before).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to move note")
+	}
+	if err := tx.Model(note).Update("folder_id", folderID).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to move note")
+	}
+	note.FolderID = folderID
+	if err := refreshNoteAccess(tx, note.NoteID); err != nil {
+		return err
+	}
+
+	var after []uint
+	if err := tx.Model(&models.EffectiveAccess{}).Distinct("user_id").
+		Where("asset_type = ? AND asset_id = ?", models.AssetTypeNote, note.NoteID).Pluck("user_id", &after).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to move note")
+	}
+	var lost []uint
+	for _, userID := range before {
+		if !slices.Contains(after, userID) {
+			lost = append(lost, userID)
+		}
+	}
+	if len(lost) > 0 {
+		afterCommit(tx, forgetRevokedUsers(lost))
+	}
+	return nil
+}
+
+// deleteNoteRecords deletes everything attached to the given notes: shares,
+// effective access, attachments, tags, comments, mentions, notifications and bookmarks. noteIDs
+// is a list of ids or a subquery selecting them.
+func deleteNoteRecords(tx *gorm.DB, noteIDs interface{}) error {
+	if err := tx.Where("note_id IN (?)", noteIDs).Delete(&models.NoteShare{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete note shares")
+	}
+	if err := forgetAccess(tx, models.AssetTypeNote, noteIDs); err != nil {
+		return err
+	}
+	attachmentIDs := tx.Model(&models.Attachment{}).Select("attachment_id").Where("note_id IN (?)", noteIDs)
+	if err := deleteAttachments(tx, attachmentIDs); err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN (?)", noteIDs).Delete(&models.NoteTag{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete note tags")
+	}
+	if err := tx.Where("note_id IN (?)", noteIDs).Delete(&models.Comment{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete comments")
+	}
+	if err := tx.Where("note_id IN (?)", noteIDs).Delete(&models.Mention{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete mentions")
+	}
+	if err := tx.Where("note_id IN (?)", noteIDs).Delete(&models.Notification{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete notifications")
+	}
+	if err := forgetAssets(tx, models.AssetTypeNote, noteIDs); err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete bookmarks")
+	}
+	return nil
+}
+
+// deleteNoteTree deletes a note and everything attached to it
+func deleteNoteTree(tx *gorm.DB, note models.Note) error {
+	if err := deleteNoteRecords(tx, []uint{note.NoteID}); err != nil {
+		return err
+	}
+	if err := tx.Delete(&note).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete note")
+	}
+	return nil
+}
+
+// deleteFolderTree deletes a folder, its shares and bookmarks, and every
+// note inside it
+func deleteFolderTree(tx *gorm.DB, folder models.Folder) error {
+	noteIDs := tx.Model(&models.Note{}).Select("note_id").Where("folder_id = ?", folder.FolderID)
+	if err := deleteNoteRecords(tx, noteIDs); err != nil {
+		return err
+	}
+	if err := forgetAssets(tx, models.AssetTypeFolder, []uint{folder.FolderID}); err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete bookmarks")
+	}
+	if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.Note{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete notes")
+	}
+	if err := tx.Where("folder_id = ?", folder.FolderID).Delete(&models.FolderShare{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete folder shares")
+	}
+	if err := forgetAccess(tx, models.AssetTypeFolder, []uint{folder.FolderID}); err != nil {
+		return err
+	}
+	if err := tx.Delete(&folder).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete folder")
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 	"github.com/seta-namnv-6798/go-apis/models"
 )
 
@@ -17,107 +18,203 @@ type AssetResponse struct {
 
 type FolderWithAccess struct {
 	models.Folder
-	AccessType string `json:"accessType"` // "owner", "read", "write"
+	AccessType string         `json:"accessType"`        // "owner", "read", "write"
+	Starred    bool           `json:"starred,omitempty"` // the caller's own star and pin
+	Pinned     bool           `json:"pinned,omitempty"`
+	Members    []MemberAccess `json:"members,omitempty"` // only set in team listings
 }
 
 type NoteWithAccess struct {
 	models.Note
-	AccessType string `json:"accessType"` // "owner", "read", "write"
+	AccessType string         `json:"accessType"` // "owner", "read", "write"
+	Tags       []string       `json:"tags"`
+	Starred    bool           `json:"starred,omitempty"` // the caller's own star and pin
+	Pinned     bool           `json:"pinned,omitempty"`
+	Members    []MemberAccess `json:"members,omitempty"` // only set in team listings
 }
 
-// GetTeamAssets retrieves all assets that team members own or can access (Manager-only)
+// MemberAccess is the access one team member holds on an asset
+type MemberAccess struct {
+	UserID uint   `json:"userId"`
+	Access string `json:"access"`
+}
+
+// assetAccessRow is one user's ownership of, or share on, an asset
+type assetAccessRow struct {
+	AssetType string
+	AssetID   uint
+	UserID    uint
+	Access    string
+}
+
+// SubTeamAssets holds the assets of one sub-team in a rolled-up report
+type SubTeamAssets struct {
+	TeamID       uint          `json:"teamId"`
+	TeamName     string        `json:"teamName"`
+	ParentTeamID *uint         `json:"parentTeamId,omitempty"`
+	Assets       AssetResponse `json:"assets"`
+}
+
+// GetTeamAssets retrieves all assets that team members own or can access
+// (Manager-only). ?includeDescendants=true adds the assets of every sub-team,
+// grouped by sub-team. The caller's pinned assets come first in each list.
 func GetTeamAssets(c *gin.Context) {
-	teamIDStr := c.Param("teamId")
-	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+	// Whoever manages the team also manages its sub-teams, so the
+	// descendants need no further check
+	team, ok := findManagedTeam(c)
+	if !ok {
 		return
 	}
 
-	// Check if team exists
-	var team models.Team
-	if err := config.DB.First(&team, teamID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+	tags, err := parseTagFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get all team members
-	var teamMembers []models.TeamMember
-	if err := config.DB.Where("team_id = ?", teamID).Find(&teamMembers).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team members"})
+	assets, err := teamAssets(team.TeamID, tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team assets"})
 		return
 	}
+	caller, _ := middleware.CurrentUser(c)
+	pinnedAssetsFirst(caller.UserID, assets)
+	response := gin.H{
+		"teamId": team.TeamID,
+		"assets": assets,
+	}
 
-	// Extract user IDs
-	var userIDs []uint
-	for _, member := range teamMembers {
-		userIDs = append(userIDs, member.UserID)
+	if c.Query("includeDescendants") == "true" {
+		subTeams := []SubTeamAssets{}
+		for _, sub := range teamDescendants(config.DB, team.TeamID) {
+			subAssets, err := teamAssets(sub.TeamID, tags)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team assets"})
+				return
+			}
+			pinnedAssetsFirst(caller.UserID, subAssets)
+			subTeams = append(subTeams, SubTeamAssets{
+				TeamID:       sub.TeamID,
+				TeamName:     sub.TeamName,
+				ParentTeamID: sub.ParentTeamID,
+				Assets:       subAssets,
+			})
+		}
+		response["subTeams"] = subTeams
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// teamAssets collects the assets that a team's members and managers own or
+// have been shared. Each asset is listed once, with the strongest access any
+// of them holds and the access of each of them.
+func teamAssets(teamID uint, tags tagFilter) (AssetResponse, error) {
+	// Managers count as members of their team
+	userIDs := teamUserIDs(config.DB, teamID)
 	if len(userIDs) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"teamId": teamID,
-			"assets": AssetResponse{
-				Folders: []FolderWithAccess{},
-				Notes:   []NoteWithAccess{},
-			},
-		})
-		return
+		return AssetResponse{Folders: []FolderWithAccess{}, Notes: []NoteWithAccess{}}, nil
 	}
+	return directAssets(userIDs, tags, true)
+}
 
-	var foldersWithAccess []FolderWithAccess
-	var notesWithAccess []NoteWithAccess
+// directAssets collects the assets the users own or have been shared. Each
+// asset is listed once, with the strongest access any of them holds and,
+// with withMembers, the access of each of them.
+func directAssets(userIDs []uint, tags tagFilter, withMembers bool) (AssetResponse, error) {
+	assets := AssetResponse{Folders: []FolderWithAccess{}, Notes: []NoteWithAccess{}}
 
-	// Get folders owned by team members
-	var ownedFolders []models.Folder
-	config.DB.Preload("Owner").Where("owner_id IN ?", userIDs).Find(&ownedFolders)
-	for _, folder := range ownedFolders {
-		foldersWithAccess = append(foldersWithAccess, FolderWithAccess{
-			Folder:     folder,
-			AccessType: "owner",
-		})
+	rows, err := directAccessRows(userIDs, tags)
+	if err != nil {
+		return assets, err
 	}
 
-	// Get folders shared with team members
-	var folderShares []models.FolderShare
-	config.DB.Preload("Folder").Preload("Folder.Owner").Where("user_id IN ?", userIDs).Find(&folderShares)
-	for _, share := range folderShares {
-		foldersWithAccess = append(foldersWithAccess, FolderWithAccess{
-			Folder:     share.Folder,
-			AccessType: share.Access,
-		})
+	// Rows come ordered by asset and user, so each asset's rows are adjacent
+	// and so are a user's rows on it
+	var folderIDs, noteIDs []uint
+	folderAccess := make(map[uint]string)
+	noteAccess := make(map[uint]string)
+	folderMembers := make(map[uint][]MemberAccess)
+	noteMembers := make(map[uint][]MemberAccess)
+	addMember := func(members map[uint][]MemberAccess, row assetAccessRow) {
+		if !withMembers {
+			return
+		}
+		list := members[row.AssetID]
+		if last := len(list) - 1; last >= 0 && list[last].UserID == row.UserID {
+			list[last].Access = strongerAccess(list[last].Access, row.Access)
+			return
+		}
+		members[row.AssetID] = append(list, MemberAccess{UserID: row.UserID, Access: row.Access})
+	}
+	for _, row := range rows {
+		if row.AssetType == models.AssetTypeFolder {
+			if _, seen := folderAccess[row.AssetID]; !seen {
+				folderIDs = append(folderIDs, row.AssetID)
+			}
+			folderAccess[row.AssetID] = strongerAccess(folderAccess[row.AssetID], row.Access)
+			addMember(folderMembers, row)
+			continue
+		}
+		if _, seen := noteAccess[row.AssetID]; !seen {
+			noteIDs = append(noteIDs, row.AssetID)
+		}
+		noteAccess[row.AssetID] = strongerAccess(noteAccess[row.AssetID], row.Access)
+		addMember(noteMembers, row)
 	}
 
-	// Get notes owned by team members
-	var ownedNotes []models.Note
-	config.DB.Preload("Owner").Preload("Folder").Where("owner_id IN ?", userIDs).Find(&ownedNotes)
-	for _, note := range ownedNotes {
-		notesWithAccess = append(notesWithAccess, NoteWithAccess{
-			Note:       note,
-			AccessType: "owner",
-		})
+	if len(folderIDs) > 0 {
+		var folders []models.Folder
+		if err := config.DB.Preload("Owner").Where("folder_id IN ?", folderIDs).Order("folder_id").Find(&folders).Error; err != nil {
+			return assets, err
+		}
+		for _, folder := range folders {
+			assets.Folders = append(assets.Folders, FolderWithAccess{
+				Folder:     folder,
+				AccessType: folderAccess[folder.FolderID],
+				Members:    folderMembers[folder.FolderID],
+			})
+		}
 	}
 
-	// Get notes shared with team members
-	var noteShares []models.NoteShare
-	config.DB.Preload("Note").Preload("Note.Owner").Preload("Note.Folder").Where("user_id IN ?", userIDs).Find(&noteShares)
-	for _, share := range noteShares {
-		notesWithAccess = append(notesWithAccess, NoteWithAccess{
-			Note:       share.Note,
-			AccessType: share.Access,
-		})
+	if len(noteIDs) > 0 {
+		var notes []models.Note
+		if err := config.DB.Preload("Owner").Preload("Folder").Where("note_id IN ?", noteIDs).Order("note_id").Find(&notes).Error; err != nil {
+			return assets, err
+		}
+		for _, note := range notes {
+			assets.Notes = append(assets.Notes, NoteWithAccess{
+				Note:       note,
+				AccessType: noteAccess[note.NoteID],
+				Members:    noteMembers[note.NoteID],
+			})
+		}
+		attachNoteTags(assets.Notes)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"teamId": teamID,
-		"assets": AssetResponse{
-			Folders: foldersWithAccess,
-			Notes:   notesWithAccess,
-		},
-	})
+	return assets, nil
 }
 
-// GetUserAssets retrieves all assets owned by or shared with a user
+// directAccessRows reads, in one query, every folder and note the users own
+// or have been shared from the effective access table, ordered by asset and
+// user
+func directAccessRows(userIDs []uint, tags tagFilter) ([]assetAccessRow, error) {
+	folders := config.DB.Model(&models.EffectiveAccess{}).
+		Select("asset_type, asset_id, user_id, level AS access").
+		Where("user_id IN ? AND asset_type = ? AND source IN ?", userIDs, models.AssetTypeFolder, directSources)
+	notes := tags.apply(config.DB.Model(&models.EffectiveAccess{}).
+		Select("asset_type, asset_id, user_id, level AS access").
+		Where("user_id IN ? AND asset_type = ? AND source IN ?", userIDs, models.AssetTypeNote, directSources), "asset_id")
+
+	var rows []assetAccessRow
+	err := config.DB.Raw("SELECT * FROM (?) AS folder_rows UNION ALL SELECT * FROM (?) AS note_rows "+
+		"ORDER BY asset_type, asset_id, user_id", folders, notes).Scan(&rows).Error
+	return rows, err
+}
+
+// GetUserAssets retrieves all assets owned by or shared with a user. Users
+// can list their own assets, with their pinned assets first; admins can list
+// anyone's.
 func GetUserAssets(c *gin.Context) {
 	userIDStr := c.Param("userId")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -126,6 +223,18 @@ func GetUserAssets(c *gin.Context) {
 		return
 	}
 
+	caller, _ := middleware.CurrentUser(c)
+	if caller.UserID != uint(userID) && caller.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only list your own assets"})
+		return
+	}
+
+	tags, err := parseTagFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Check if user exists
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
@@ -133,55 +242,25 @@ func GetUserAssets(c *gin.Context) {
 		return
 	}
 
-	var foldersWithAccess []FolderWithAccess
-	var notesWithAccess []NoteWithAccess
-
-	// Get folders owned by the user
-	var ownedFolders []models.Folder
-	config.DB.Preload("Owner").Where("owner_id = ?", userID).Find(&ownedFolders)
-	for _, folder := range ownedFolders {
-		foldersWithAccess = append(foldersWithAccess, FolderWithAccess{
-			Folder:     folder,
-			AccessType: "owner",
-		})
-	}
-
-	// Get folders shared with the user
-	var folderShares []models.FolderShare
-	config.DB.Preload("Folder").Preload("Folder.Owner").Where("user_id = ?", userID).Find(&folderShares)
-	for _, share := range folderShares {
-		foldersWithAccess = append(foldersWithAccess, FolderWithAccess{
-			Folder:     share.Folder,
-			AccessType: share.Access,
-		})
+	// The unfiltered listing is cached; tag filters always read the database
+	var assets AssetResponse
+	if tags.active() {
+		assets, err = directAssets([]uint{user.UserID}, tags, false)
+	} else {
+		assets, err = cachedUserAssets(user.UserID)
 	}
-
-	// Get notes owned by the user
-	var ownedNotes []models.Note
-	config.DB.Preload("Owner").Preload("Folder").Where("owner_id = ?", userID).Find(&ownedNotes)
-	for _, note := range ownedNotes {
-		notesWithAccess = append(notesWithAccess, NoteWithAccess{
-			Note:       note,
-			AccessType: "owner",
-		})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user assets"})
+		return
 	}
-
-	// Get notes shared with the user
-	var noteShares []models.NoteShare
-	config.DB.Preload("Note").Preload("Note.Owner").Preload("Note.Folder").Where("user_id = ?", userID).Find(&noteShares)
-	for _, share := range noteShares {
-		notesWithAccess = append(notesWithAccess, NoteWithAccess{
-			Note:       share.Note,
-			AccessType: share.Access,
-		})
+	// Stars and pins are private to the user they belong to
+	if caller.UserID == user.UserID {
+		pinnedAssetsFirst(caller.UserID, assets)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"userId": userID,
 		"user":   user,
-		"assets": AssetResponse{
-			Folders: foldersWithAccess,
-			Notes:   notesWithAccess,
-		},
+		"assets": assets,
 	})
 }
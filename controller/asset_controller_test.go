@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+func TestGetTeamAssetsRequiresAManager(t *testing.T) {
+	setupTestDB(t)
+	parent := createTestTeam(t, "department")
+	squad := models.Team{TeamName: "squad", ParentTeamID: &parent.TeamID}
+	config.DB.Create(&squad)
+	config.DB.Create(&models.TeamMember{UserID: testOwner, TeamID: squad.TeamID})
+	createTestFolder(t, testOwner, "squad docs")
+
+	router := testRouter()
+	router.GET("/teams/:teamId/assets", middleware.RequireAuth(), GetTeamAssets)
+	path := fmt.Sprintf("/teams/%d/assets?includeDescendants=true", parent.TeamID)
+
+	if status, _ := testRequest(t, router, 0, http.MethodGet, path, nil); status != http.StatusUnauthorized {
+		t.Fatalf("anonymous request returned %d, want 401", status)
+	}
+	if status, _ := testRequest(t, router, testReader, http.MethodGet, path, nil); status != http.StatusForbidden {
+		t.Fatalf("non-manager request returned %d, want 403", status)
+	}
+
+	status, response := testRequest(t, router, testManager, http.MethodGet, path, nil)
+	if status != http.StatusOK {
+		t.Fatalf("manager request returned %d: %v", status, response)
+	}
+	subTeams, _ := response["subTeams"].([]interface{})
+	if len(subTeams) != 1 {
+		t.Fatalf("subTeams = %v, want the squad", response["subTeams"])
+	}
+	assets := subTeams[0].(map[string]interface{})["assets"].(map[string]interface{})
+	if folders, _ := assets["folders"].([]interface{}); len(folders) != 1 {
+		t.Fatalf("squad folders = %v, want one", assets["folders"])
+	}
+}
+
+func TestGetUserAssetsPutsPinnedAssetsFirst(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	first := createTestFolder(t, testOwner, "first")
+	second := createTestFolder(t, testOwner, "second")
+	createTestNote(t, first, testOwner, "a")
+	pinnedNote := createTestNote(t, first, testOwner, "b")
+
+	earlier, later := time.Now().Add(-time.Hour), time.Now()
+	config.DB.Create(&models.Bookmark{UserID: testOwner, AssetType: models.AssetTypeFolder, AssetID: second.FolderID, Pinned: true, PinnedAt: &earlier})
+	config.DB.Create(&models.Bookmark{UserID: testOwner, AssetType: models.AssetTypeNote, AssetID: pinnedNote.NoteID, Pinned: true, PinnedAt: &later})
+
+	router := testRouter()
+	router.GET("/users/:userId/assets", GetUserAssets)
+	// Both the first read and the cached listing are ordered
+	for range 2 {
+		status, response := testRequest(t, router, testOwner, http.MethodGet, fmt.Sprintf("/users/%d/assets", testOwner), nil)
+		if status != http.StatusOK {
+			t.Fatalf("listing returned %d: %v", status, response)
+		}
+		assets := response["assets"].(map[string]interface{})
+		folders := assets["folders"].([]interface{})
+		notes := assets["notes"].([]interface{})
+		if folder := folders[0].(map[string]interface{}); folder["folderId"] != float64(second.FolderID) || folder["pinned"] != true {
+			t.Fatalf("first folder = %v, want the pinned one", folder)
+		}
+		if note := notes[0].(map[string]interface{}); note["noteId"] != float64(pinnedNote.NoteID) || note["pinned"] != true {
+			t.Fatalf("first note = %v, want the pinned one", note)
+		}
+	}
+}
+
+func TestGetUserAssetsIsPrivate(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	now := time.Now()
+	config.DB.Create(&models.Bookmark{UserID: testAdmin, AssetType: models.AssetTypeFolder, AssetID: folder.FolderID, Pinned: true, PinnedAt: &now})
+
+	router := testRouter()
+	router.GET("/users/:userId/assets", middleware.RequireAuth(), GetUserAssets)
+	path := fmt.Sprintf("/users/%d/assets", testOwner)
+	if status, _ := testRequest(t, router, 0, http.MethodGet, path, nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous listing returned %d, want 401", status)
+	}
+	if status, _ := testRequest(t, router, testReader, http.MethodGet, path, nil); status != http.StatusForbidden {
+		t.Errorf("listing another user's assets returned %d, want 403", status)
+	}
+
+	status, response := testRequest(t, router, testAdmin, http.MethodGet, path, nil)
+	if status != http.StatusOK {
+		t.Fatalf("admin listing returned %d: %v", status, response)
+	}
+	folders := response["assets"].(map[string]interface{})["folders"].([]interface{})
+	if pinned := folders[0].(map[string]interface{})["pinned"]; pinned == true {
+		t.Fatal("the admin's pins were applied to another user's listing")
+	}
+}
+
+func TestGetTeamAssetsListsSharedAssetsOnce(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testOwner, testReader)
+	folder := createTestFolder(t, testOwner, "docs")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		_, _, err := shareFolderWith(tx, folder.FolderID, testReader, AccessRead, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := testRouter()
+	router.GET("/teams/:teamId/assets", middleware.RequireAuth(), GetTeamAssets)
+	status, response := testRequest(t, router, testManager, http.MethodGet, fmt.Sprintf("/teams/%d/assets", team.TeamID), nil)
+	if status != http.StatusOK {
+		t.Fatalf("listing returned %d: %v", status, response)
+	}
+	folders := response["assets"].(map[string]interface{})["folders"].([]interface{})
+	if len(folders) != 1 {
+		t.Fatalf("folders = %v, want the shared folder once", folders)
+	}
+	listed := folders[0].(map[string]interface{})
+	if listed["accessType"] != AccessOwner {
+		t.Errorf("accessType = %v, want the strongest access", listed["accessType"])
+	}
+	members := map[float64]interface{}{}
+	for _, member := range listed["members"].([]interface{}) {
+		member := member.(map[string]interface{})
+		members[member["userId"].(float64)] = member["access"]
+	}
+	if members[float64(testOwner)] != AccessOwner || members[float64(testReader)] != AccessRead || len(members) != 2 {
+		t.Fatalf("members = %v, want the owner and the reader", members)
+	}
+}
+
+// BenchmarkGetTeamAssets lists the assets of a team of 50 whose members own
+// 500 folders holding 10,000 notes and share 1,000 folders and notes
+func BenchmarkGetTeamAssets(b *testing.B) {
+	setupTestDB(b)
+	var memberIDs []uint
+	for i := range 50 {
+		user := models.User{Username: fmt.Sprintf("member%d", i), Email: fmt.Sprintf("member%d@example.com", i), PasswordHash: "-", Role: models.RoleUser}
+		if err := config.DB.Create(&user).Error; err != nil {
+			b.Fatal(err)
+		}
+		memberIDs = append(memberIDs, user.UserID)
+	}
+	team := createTestTeam(b, "bench", memberIDs...)
+
+	folders := make([]models.Folder, 500)
+	for i := range folders {
+		folders[i] = models.Folder{Name: fmt.Sprintf("folder %d", i), OwnerID: memberIDs[i%len(memberIDs)]}
+	}
+	notes := make([]models.Note, 10000)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&folders, 500).Error; err != nil {
+			return err
+		}
+		for i := range notes {
+			folder := folders[i%len(folders)]
+			notes[i] = models.Note{Title: fmt.Sprintf("note %d", i), Body: "body", FolderID: folder.FolderID, OwnerID: folder.OwnerID}
+		}
+		if err := tx.CreateInBatches(&notes, 500).Error; err != nil {
+			return err
+		}
+		for i := range 500 {
+			reader := memberIDs[(i+1)%len(memberIDs)]
+			if err := tx.Create(&models.FolderShare{FolderID: folders[i].FolderID, UserID: reader, Access: AccessRead}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.NoteShare{NoteID: notes[i*20].NoteID, UserID: reader, Access: AccessWrite}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := RebuildEffectiveAccess(config.DB); err != nil {
+		b.Fatal(err)
+	}
+
+	router := testRouter()
+	router.GET("/teams/:teamId/assets", middleware.RequireAuth(), GetTeamAssets)
+	path := fmt.Sprintf("/teams/%d/assets", team.TeamID)
+	b.ResetTimer()
+	for range b.N {
+		if status, _ := testRequest(b, router, testManager, http.MethodGet, path, nil); status != http.StatusOK {
+			b.Fatalf("listing returned %d", status)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/previews"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxAttachmentSize caps the size of a single uploaded file
+	maxAttachmentSize = 25 << 20
+
+	// downloadURLTTL is how long a signed download URL stays valid
+	downloadURLTTL = 5 * time.Minute
+)
+
+// UploadAttachment stores a file uploaded to a note (requires write access)
+func UploadAttachment(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canWrite)
+	if !ok {
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	// Leave room for the multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file must be uploaded in the file field"})
+		return
+	}
+	if fileHeader.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the %d MB limit", maxAttachmentSize>>20)})
+		return
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer src.Close()
+
+	// Spool to disk while hashing so the content type can be sniffed and
+	// the store receives an exact size
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(src, maxAttachmentSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	if size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the %d MB limit", maxAttachmentSize>>20)})
+		return
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	mtype, err := mimetype.DetectReader(tmp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect content type"})
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	storageKey := fmt.Sprintf("notes/%d/%s", note.NoteID, randomToken())
+	if err := config.Blobs.Put(c.Request.Context(), storageKey, tmp, size, mtype.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	attachment := models.Attachment{
+		NoteID:        note.NoteID,
+		UploaderID:    user.UserID,
+		FileName:      fileHeader.Filename,
+		ContentType:   mtype.String(),
+		Size:          size,
+		Checksum:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:    storageKey,
+		PreviewStatus: previews.StatusNone,
+	}
+	if previews.KindFor(attachment.ContentType) != previews.KindNone {
+		attachment.PreviewStatus = previews.StatusPending
+	}
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		config.Blobs.Delete(c.Request.Context(), storageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	// Thumbnails and previews are produced in the background
+	if attachment.PreviewStatus == previews.StatusPending {
+		previews.Enqueue(attachment.AttachmentID)
+	}
+
+	config.DB.Preload("Uploader").Preload("Thumbnails").First(&attachment, attachment.AttachmentID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Attachment uploaded successfully",
+		"attachment": attachment,
+	})
+}
+
+// ListAttachments lists the files attached to a note
+func ListAttachments(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := config.DB.Preload("Uploader").Preload("Thumbnails").Where("note_id = ?", note.NoteID).Order("attachment_id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"noteId":      note.NoteID,
+		"attachments": attachments,
+	})
+}
+
+// GetAttachmentURL issues a short-lived signed download URL for the caller.
+// ?thumbnail=small|medium|large links to a thumbnail instead of the file.
+func GetAttachmentURL(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	attachment, ok := findNoteAttachment(c, note)
+	if !ok {
+		return
+	}
+
+	variant := c.Query("thumbnail")
+	if variant != "" {
+		var thumbnail models.AttachmentThumbnail
+		if err := config.DB.Where("attachment_id = ? AND size = ?", attachment.AttachmentID, variant).First(&thumbnail).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found", "previewStatus": attachment.PreviewStatus})
+			return
+		}
+	}
+
+	expires := time.Now().Add(downloadURLTTL).Unix()
+	query := url.Values{}
+	query.Set("user", strconv.FormatUint(uint64(user.UserID), 10))
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if variant != "" {
+		query.Set("thumbnail", variant)
+	}
+	query.Set("signature", signDownload(attachment.AttachmentID, user.UserID, variant, expires))
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":       fmt.Sprintf("/attachments/%d/download?%s", attachment.AttachmentID, query.Encode()),
+		"expiresAt": time.Unix(expires, 0).UTC(),
+	})
+}
+
+// DownloadAttachment serves an attachment through a signed URL. The user the
+// URL was issued to must still be able to read the note.
+func DownloadAttachment(c *gin.Context) {
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Query("user"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+		return
+	}
+
+	variant := c.Query("thumbnail")
+	expected := signDownload(uint(attachmentID), uint(userID), variant, expires)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("signature"))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid download link"})
+		return
+	}
+	if time.Now().Unix() > expires {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Download link has expired"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.Preload("Note").First(&attachment, attachmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if !canRead(noteAccessFor(uint(userID), attachment.Note)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this note"})
+		return
+	}
+
+	if variant != "" {
+		serveThumbnail(c, attachment, variant)
+		return
+	}
+
+	reader, err := config.Blobs.Get(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment content not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition":    fmt.Sprintf("attachment; filename=%q", attachment.FileName),
+		"X-Content-Type-Options": "nosniff",
+		"ETag":                   strconv.Quote(attachment.Checksum),
+	})
+}
+
+// serveThumbnail streams one of an attachment's thumbnails
+func serveThumbnail(c *gin.Context, attachment models.Attachment, size string) {
+	var thumbnail models.AttachmentThumbnail
+	if err := config.DB.Where("attachment_id = ? AND size = ?", attachment.AttachmentID, size).First(&thumbnail).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+		return
+	}
+
+	reader, err := config.Blobs.Get(c.Request.Context(), thumbnail.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail content not found"})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, thumbnail.ContentType, reader, map[string]string{
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// DeleteAttachment removes an attachment from a note (requires write access)
+func DeleteAttachment(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canWrite)
+	if !ok {
+		return
+	}
+
+	attachment, ok := findNoteAttachment(c, note)
+	if !ok {
+		return
+	}
+
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		return deleteAttachments(tx, []uint{attachment.AttachmentID})
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// deleteAttachments deletes attachments along with their thumbnails.
+// attachmentIDs is a list of ids or a subquery selecting them. The stored
+// files are removed once the transaction commits.
+func deleteAttachments(tx *gorm.DB, attachmentIDs interface{}) error {
+	// Locking the attachments waits for the preview worker to finish recording
+	// thumbnails, so the ones it adds are found below
+	var keys []string
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Attachment{}).Where("attachment_id IN (?)", attachmentIDs).Pluck("storage_key", &keys).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to load attachments")
+	}
+	var thumbnailKeys []string
+	if err := tx.Model(&models.AttachmentThumbnail{}).Where("attachment_id IN (?)", attachmentIDs).Pluck("storage_key", &thumbnailKeys).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to load thumbnails")
+	}
+	keys = append(keys, thumbnailKeys...)
+
+	if err := tx.Where("attachment_id IN (?)", attachmentIDs).Delete(&models.AttachmentThumbnail{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete thumbnails")
+	}
+	if err := tx.Where("attachment_id IN (?)", attachmentIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to delete attachments")
+	}
+
+	afterCommit(tx, func() {
+		for _, key := range keys {
+			if err := config.Blobs.Delete(context.Background(), key); err != nil {
+				log.Printf("attachments: deleting blob %s: %v", key, err)
+			}
+		}
+	})
+	return nil
+}
+
+// findNoteAttachment loads the attachment named by the attachmentId parameter
+// if it belongs to the note. It writes the error response and returns false
+// otherwise.
+func findNoteAttachment(c *gin.Context, note models.Note) (models.Attachment, bool) {
+	var attachment models.Attachment
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return attachment, false
+	}
+
+	if err := config.DB.Where("note_id = ?", note.NoteID).First(&attachment, attachmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+// signDownload signs an attachment download, or one of its thumbnails when
+// variant is set, for a user until expires
+func signDownload(attachmentID, userID uint, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, config.AttachmentURLSecret())
+	fmt.Fprintf(mac, "%d:%d:%s:%d", attachmentID, userID, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomToken returns a random hex string for storage keys
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
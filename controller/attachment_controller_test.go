@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/storage"
+	"gorm.io/gorm"
+)
+
+// useTestBlobs points config.Blobs at a store in a temporary directory
+func useTestBlobs(t *testing.T) {
+	t.Helper()
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := config.Blobs
+	config.Blobs = store
+	t.Cleanup(func() { config.Blobs = previous })
+}
+
+// createTestAttachment stores an attachment with one thumbnail on a note and
+// returns it with the storage keys of both blobs
+func createTestAttachment(t *testing.T, note models.Note) (models.Attachment, []string) {
+	t.Helper()
+	ctx := context.Background()
+	key := fmt.Sprintf("note-%d-file", note.NoteID)
+	keys := []string{key, key + "-small"}
+	for _, k := range keys {
+		if err := config.Blobs.Put(ctx, k, strings.NewReader("data"), 4, "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attachment := models.Attachment{
+		NoteID: note.NoteID, UploaderID: note.OwnerID, FileName: "file.txt",
+		ContentType: "text/plain", Size: 4, Checksum: "checksum", StorageKey: key,
+	}
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		t.Fatal(err)
+	}
+	thumbnail := models.AttachmentThumbnail{
+		AttachmentID: attachment.AttachmentID, Size: "small", Width: 1, Height: 1,
+		ContentType: "image/png", StorageKey: keys[1],
+	}
+	if err := config.DB.Create(&thumbnail).Error; err != nil {
+		t.Fatal(err)
+	}
+	return attachment, keys
+}
+
+// storedBlobs returns which of the keys are still in config.Blobs
+func storedBlobs(t *testing.T, keys []string) []string {
+	t.Helper()
+	var stored []string
+	for _, key := range keys {
+		r, err := config.Blobs.Get(context.Background(), key)
+		if err != nil {
+			continue
+		}
+		r.Close()
+		stored = append(stored, key)
+	}
+	return stored
+}
+
+func TestDeleteAttachmentRemovesThumbnails(t *testing.T) {
+	setupTestDB(t)
+	useTestBlobs(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	attachment, keys := createTestAttachment(t, note)
+
+	router := testRouter()
+	router.DELETE("/notes/:noteId/attachments/:attachmentId", DeleteAttachment)
+	path := fmt.Sprintf("/notes/%d/attachments/%d", note.NoteID, attachment.AttachmentID)
+	if status, _ := testRequest(t, router, testOwner, http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Fatalf("deleting the attachment returned %d", status)
+	}
+
+	var thumbnails int64
+	config.DB.Model(&models.AttachmentThumbnail{}).Where("attachment_id = ?", attachment.AttachmentID).Count(&thumbnails)
+	if thumbnails != 0 {
+		t.Errorf("%d thumbnail rows left behind", thumbnails)
+	}
+	if stored := storedBlobs(t, keys); len(stored) != 0 {
+		t.Errorf("blobs %v left behind", stored)
+	}
+}
+
+func TestDeletingNotesAndFoldersRemovesAttachmentBlobs(t *testing.T) {
+	setupTestDB(t)
+	useTestBlobs(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	_, noteKeys := createTestAttachment(t, note)
+	other := createTestFolder(t, testOwner, "archive")
+	_, folderKeys := createTestAttachment(t, createTestNote(t, other, testOwner, "old"))
+
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := deleteNoteTree(tx, note); err != nil {
+			return err
+		}
+		if err := deleteFolderTree(tx, other); err != nil {
+			return err
+		}
+		if stored := storedBlobs(t, append(noteKeys, folderKeys...)); len(stored) != 4 {
+			t.Errorf("blobs deleted before the transaction committed, %v left", stored)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedBlobs(t, append(noteKeys, folderKeys...)); len(stored) != 0 {
+		t.Fatalf("blobs %v left behind", stored)
+	}
+}
+
+func TestRolledBackSavepointKeepsAttachmentBlobs(t *testing.T) {
+	setupTestDB(t)
+	useTestBlobs(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	_, keys := createTestAttachment(t, note)
+
+	failed := errors.New("rolled back")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		err := runTransaction(tx, func(inner *gorm.DB) error {
+			if err := deleteNoteTree(inner, note); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("nested runTransaction returned %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attachments int64
+	config.DB.Model(&models.Attachment{}).Where("note_id = ?", note.NoteID).Count(&attachments)
+	if attachments != 1 {
+		t.Errorf("rolled back savepoint left %d attachments", attachments)
+	}
+	if stored := storedBlobs(t, keys); len(stored) != 2 {
+		t.Fatalf("rolled back savepoint deleted blobs, %v left", stored)
+	}
+}
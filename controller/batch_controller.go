@@ -0,0 +1,525 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// Batch operations
+const (
+	BatchCreateFolder      = "createFolder"
+	BatchUpdateFolder      = "updateFolder"
+	BatchDeleteFolder      = "deleteFolder"
+	BatchShareFolder       = "shareFolder"
+	BatchRevokeFolderShare = "revokeFolderShare"
+	BatchCreateNote        = "createNote"
+	BatchUpdateNote        = "updateNote"
+	BatchMoveNote          = "moveNote"
+	BatchDeleteNote        = "deleteNote"
+	BatchShareNote         = "shareNote"
+	BatchRevokeNoteShare   = "revokeNoteShare"
+)
+
+// batchRefPrefix marks a parameter that refers to the id created by an
+// earlier operation, as in "folderId": "$docs"
+const batchRefPrefix = "$"
+
+// batchRefParams are the parameters that may hold a reference. Others, such
+// as titles and names, are taken as given even when they start with "$".
+var batchRefParams = map[string]bool{"folderId": true, "noteId": true, "userId": true}
+
+// BatchRequest represents an ordered list of operations run as one unit
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=100,dive"`
+}
+
+// BatchOperation is one operation of a batch. Ref names the id the operation
+// creates so later operations can use it.
+type BatchOperation struct {
+	Op     string          `json:"op" binding:"required"`
+	Ref    string          `json:"ref"`
+	Params json.RawMessage `json:"params"`
+}
+
+// BatchResult reports what one operation of a committed batch did
+type BatchResult struct {
+	Index  int         `json:"index"`
+	Op     string      `json:"op"`
+	Ref    string      `json:"ref,omitempty"`
+	ID     uint        `json:"id,omitempty"`
+	Result interface{} `json:"result"`
+}
+
+// Parameters of each operation. Ids may be given as numbers or as references
+// to earlier operations.
+type batchFolderParams struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type batchFolderIDParams struct {
+	FolderID uint `json:"folderId" binding:"required"`
+}
+
+type batchUpdateFolderParams struct {
+	FolderID uint   `json:"folderId" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+}
+
+type batchFolderShareParams struct {
+	FolderID uint   `json:"folderId" binding:"required"`
+	UserID   uint   `json:"userId" binding:"required"`
+	Access   string `json:"access" binding:"required,oneof=read write"`
+}
+
+type batchRevokeFolderShareParams struct {
+	FolderID uint `json:"folderId" binding:"required"`
+	UserID   uint `json:"userId" binding:"required"`
+}
+
+type batchCreateNoteParams struct {
+	FolderID uint   `json:"folderId" binding:"required"`
+	Title    string `json:"title" binding:"required"`
+	Body     string `json:"body"`
+	Format   string `json:"format" binding:"omitempty,oneof=markdown html"`
+}
+
+type batchUpdateNoteParams struct {
+	NoteID uint   `json:"noteId" binding:"required"`
+	Title  string `json:"title" binding:"required"`
+	Body   string `json:"body"`
+	Format string `json:"format" binding:"omitempty,oneof=markdown html"`
+}
+
+type batchMoveNoteParams struct {
+	NoteID   uint `json:"noteId" binding:"required"`
+	FolderID uint `json:"folderId" binding:"required"`
+}
+
+type batchNoteIDParams struct {
+	NoteID uint `json:"noteId" binding:"required"`
+}
+
+type batchNoteShareParams struct {
+	NoteID uint   `json:"noteId" binding:"required"`
+	UserID uint   `json:"userId" binding:"required"`
+	Access string `json:"access" binding:"required,oneof=read write"`
+}
+
+type batchRevokeNoteShareParams struct {
+	NoteID uint `json:"noteId" binding:"required"`
+	UserID uint `json:"userId" binding:"required"`
+}
+
+// batchRun carries the state of a batch while its transaction is open
+type batchRun struct {
+	tx     *gorm.DB
+	userID uint
+	actor  *uint
+	refs   map[string]uint
+	// afterCommit holds work that reads committed data, such as mention
+	// notifications, and runs once the batch was committed
+	afterCommit []func()
+}
+
+// batchOutcome is what an operation returns: the id it created or acted on
+// and the object to report
+type batchOutcome struct {
+	id     uint
+	result interface{}
+}
+
+// RunBatch runs an ordered list of operations in one transaction. Later
+// operations can refer to ids created by earlier ones with "$ref". Either
+// every operation is applied and its result returned, or nothing is changed
+// and the first failure is reported.
+func RunBatch(c *gin.Context) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	// Refs must be unique so every reference has one meaning
+	seen := make(map[string]bool)
+	for i, op := range req.Operations {
+		if op.Ref == "" {
+			continue
+		}
+		if seen[op.Ref] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Operation %d reuses ref %q", i, op.Ref)})
+			return
+		}
+		seen[op.Ref] = true
+	}
+
+	var (
+		results   []BatchResult
+		failedAt  int
+		failedOp  string
+		failedErr error
+		run       *batchRun
+	)
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		run = &batchRun{tx: tx, userID: user.UserID, actor: actorOf(c), refs: make(map[string]uint)}
+		results = make([]BatchResult, 0, len(req.Operations))
+		for i, op := range req.Operations {
+			outcome, err := run.apply(op)
+			if err != nil {
+				failedAt, failedOp, failedErr = i, op.Op, err
+				return err
+			}
+			if op.Ref != "" {
+				run.refs[op.Ref] = outcome.id
+			}
+			results = append(results, BatchResult{Index: i, Op: op.Op, Ref: op.Ref, ID: outcome.id, Result: outcome.result})
+		}
+		return nil
+	})
+
+	if failedErr != nil {
+		status := actionStatus(failedErr)
+		if status != http.StatusInternalServerError {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"error": fmt.Sprintf("No changes were made because operation %d (%s) failed: %s", failedAt, failedOp, failedErr.Error()),
+			"index": failedAt,
+			"op":    failedOp,
+			"code":  actionStatus(failedErr),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit batch"})
+		return
+	}
+
+	for _, fn := range run.afterCommit {
+		fn()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Batch completed successfully",
+		"results": results,
+	})
+}
+
+// apply runs one operation inside the batch transaction
+func (r *batchRun) apply(op BatchOperation) (batchOutcome, error) {
+	switch op.Op {
+	case BatchCreateFolder:
+		var p batchFolderParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.createFolder(p)
+	case BatchUpdateFolder:
+		var p batchUpdateFolderParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.updateFolder(p)
+	case BatchDeleteFolder:
+		var p batchFolderIDParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.deleteFolder(p)
+	case BatchShareFolder:
+		var p batchFolderShareParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.shareFolder(p)
+	case BatchRevokeFolderShare:
+		var p batchRevokeFolderShareParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.revokeFolderShare(p)
+	case BatchCreateNote:
+		var p batchCreateNoteParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.createNote(p)
+	case BatchUpdateNote:
+		var p batchUpdateNoteParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.updateNote(p)
+	case BatchMoveNote:
+		var p batchMoveNoteParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.moveNote(p)
+	case BatchDeleteNote:
+		var p batchNoteIDParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.deleteNote(p)
+	case BatchShareNote:
+		var p batchNoteShareParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.shareNote(p)
+	case BatchRevokeNoteShare:
+		var p batchRevokeNoteShareParams
+		if err := r.bind(op.Params, &p); err != nil {
+			return batchOutcome{}, err
+		}
+		return r.revokeNoteShare(p)
+	}
+	return batchOutcome{}, failAction(http.StatusBadRequest, fmt.Sprintf("Unknown operation %q", op.Op))
+}
+
+// bind resolves references in an operation's id parameters, then decodes
+// and validates them
+func (r *batchRun) bind(raw json.RawMessage, params interface{}) error {
+	var values map[string]interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return failAction(http.StatusBadRequest, "Invalid operation parameters")
+		}
+	}
+
+	for key, value := range values {
+		ref, ok := value.(string)
+		if !ok || !batchRefParams[key] || !strings.HasPrefix(ref, batchRefPrefix) {
+			continue
+		}
+		id, found := r.refs[strings.TrimPrefix(ref, batchRefPrefix)]
+		if !found {
+			return failAction(http.StatusBadRequest, fmt.Sprintf("Unknown reference %q", ref))
+		}
+		values[key] = id
+	}
+
+	resolved, err := json.Marshal(values)
+	if err != nil {
+		return failAction(http.StatusBadRequest, "Invalid operation parameters")
+	}
+	if err := json.Unmarshal(resolved, params); err != nil {
+		return failAction(http.StatusBadRequest, "Invalid operation parameters")
+	}
+	if err := binding.Validator.ValidateStruct(params); err != nil {
+		return failAction(http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// writableFolder loads a folder the caller can write to
+func (r *batchRun) writableFolder(folderID uint) (models.Folder, error) {
+	var folder models.Folder
+	if err := r.tx.First(&folder, folderID).Error; err != nil {
+		return folder, failAction(http.StatusNotFound, "Folder not found")
+	}
+	if !canWrite(folderAccessIn(r.tx, r.userID, folder)) {
+		return folder, failAction(http.StatusForbidden, "You do not have write access to this folder")
+	}
+	return folder, nil
+}
+
+// writableNote loads a note the caller can write to
+func (r *batchRun) writableNote(noteID uint) (models.Note, error) {
+	var note models.Note
+	if err := r.tx.First(&note, noteID).Error; err != nil {
+		return note, failAction(http.StatusNotFound, "Note not found")
+	}
+	if !canWrite(noteAccessIn(r.tx, r.userID, note)) {
+		return note, failAction(http.StatusForbidden, "You do not have write access to this note")
+	}
+	return note, nil
+}
+
+func (r *batchRun) createFolder(p batchFolderParams) (batchOutcome, error) {
+	folder := models.Folder{Name: p.Name, OwnerID: r.userID}
+	if err := r.tx.Create(&folder).Error; err != nil {
+		return batchOutcome{}, failAction(http.StatusInternalServerError, "Failed to create folder")
+	}
+	if err := refreshFolderAccess(r.tx, folder.FolderID); err != nil {
+		return batchOutcome{}, err
+	}
+	r.tx.Preload("Owner").First(&folder, folder.FolderID)
+	return batchOutcome{id: folder.FolderID, result: folder}, nil
+}
+
+func (r *batchRun) updateFolder(p batchUpdateFolderParams) (batchOutcome, error) {
+	folder, err := r.writableFolder(p.FolderID)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+	folder.Name = p.Name
+	if err := r.tx.Save(&folder).Error; err != nil {
+		return batchOutcome{}, failAction(http.StatusInternalServerError, "Failed to update folder")
+	}
+	invalidateListings(r.tx, models.AssetTypeFolder, folder.FolderID)
+	r.tx.Preload("Owner").First(&folder, folder.FolderID)
+	return batchOutcome{id: folder.FolderID, result: folder}, nil
+}
+
+func (r *batchRun) deleteFolder(p batchFolderIDParams) (batchOutcome, error) {
+	folder, err := requireFolderOwner(r.tx, r.userID, p.FolderID)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+	if err := deleteFolderTree(r.tx, folder); err != nil {
+		return batchOutcome{}, err
+	}
+	return batchOutcome{id: folder.FolderID, result: gin.H{"deleted": true}}, nil
+}
+
+func (r *batchRun) shareFolder(p batchFolderShareParams) (batchOutcome, error) {
+	if _, err := requireFolderOwner(r.tx, r.userID, p.FolderID); err != nil {
+		return batchOutcome{}, err
+	}
+	share, _, err := shareFolderWith(r.tx, p.FolderID, p.UserID, p.Access, nil)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+	return batchOutcome{id: share.ID, result: share}, nil
+}
+
+func (r *batchRun) revokeFolderShare(p batchRevokeFolderShareParams) (batchOutcome, error) {
+	if _, err := requireFolderOwner(r.tx, r.userID, p.FolderID); err != nil {
+		return batchOutcome{}, err
+	}
+	if err := revokeFolderShareFrom(r.tx, p.FolderID, p.UserID); err != nil {
+		return batchOutcome{}, err
+	}
+	r.afterCommit = append(r.afterCommit, func() { forgetLostAssets(p.UserID) })
+	return batchOutcome{result: gin.H{"revoked": true}}, nil
+}
+
+func (r *batchRun) createNote(p batchCreateNoteParams) (batchOutcome, error) {
+	folder, err := r.writableFolder(p.FolderID)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+
+	// HTML bodies are stored as Markdown
+	body, err := bodyToMarkdown(p.Body, p.Format)
+	if err != nil {
+		return batchOutcome{}, failAction(http.StatusBadRequest, "Failed to convert HTML body")
+	}
+
+	note := models.Note{Title: p.Title, Body: body, FolderID: folder.FolderID, OwnerID: r.userID}
+	if err := r.tx.Create(&note).Error; err != nil {
+		return batchOutcome{}, failAction(http.StatusInternalServerError, "Failed to create note")
+	}
+	if err := refreshNoteAccess(r.tx, note.NoteID); err != nil {
+		return batchOutcome{}, err
+	}
+	r.notifyMentions(note.NoteID)
+	r.tx.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
+	return batchOutcome{id: note.NoteID, result: note}, nil
+}
+
+func (r *batchRun) updateNote(p batchUpdateNoteParams) (batchOutcome, error) {
+	note, err := r.writableNote(p.NoteID)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+
+	// A live editing session owns the body until it ends
+	if collabHub.Active(note.NoteID) {
+		return batchOutcome{}, failAction(http.StatusConflict, "Note is being edited in a live session")
+	}
+
+	// HTML bodies are stored as Markdown
+	body, err := bodyToMarkdown(p.Body, p.Format)
+	if err != nil {
+		return batchOutcome{}, failAction(http.StatusBadRequest, "Failed to convert HTML body")
+	}
+
+	note.Title = p.Title
+	note.Body = body
+	if err := r.tx.Save(&note).Error; err != nil {
+		return batchOutcome{}, failAction(http.StatusInternalServerError, "Failed to update note")
+	}
+	invalidateListings(r.tx, models.AssetTypeNote, note.NoteID)
+	if err := reanchorComments(r.tx, note.NoteID, note.Body); err != nil {
+		return batchOutcome{}, failAction(http.StatusInternalServerError, "Failed to update comments")
+	}
+	r.notifyMentions(note.NoteID)
+	r.tx.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
+	return batchOutcome{id: note.NoteID, result: note}, nil
+}
+
+func (r *batchRun) moveNote(p batchMoveNoteParams) (batchOutcome, error) {
+	note, err := requireNoteOrFolderOwner(r.tx, r.userID, p.NoteID)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+	if _, err := r.writableFolder(p.FolderID); err != nil {
+		return batchOutcome{}, err
+	}
+	if err := moveNoteTo(r.tx, &note, p.FolderID); err != nil {
+		return batchOutcome{}, err
+	}
+	r.tx.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
+	return batchOutcome{id: note.NoteID, result: note}, nil
+}
+
+func (r *batchRun) deleteNote(p batchNoteIDParams) (batchOutcome, error) {
+	var note models.Note
+	if err := r.tx.Preload("Folder").First(&note, p.NoteID).Error; err != nil {
+		return batchOutcome{}, failAction(http.StatusNotFound, "Note not found")
+	}
+	if note.OwnerID != r.userID && note.Folder.OwnerID != r.userID {
+		return batchOutcome{}, failAction(http.StatusForbidden, "Only the note or folder owner can delete this note")
+	}
+	if err := deleteNoteTree(r.tx, note); err != nil {
+		return batchOutcome{}, err
+	}
+	return batchOutcome{id: note.NoteID, result: gin.H{"deleted": true}}, nil
+}
+
+func (r *batchRun) shareNote(p batchNoteShareParams) (batchOutcome, error) {
+	if _, err := requireNoteOwner(r.tx, r.userID, p.NoteID); err != nil {
+		return batchOutcome{}, err
+	}
+	share, _, err := shareNoteWith(r.tx, p.NoteID, p.UserID, p.Access, nil)
+	if err != nil {
+		return batchOutcome{}, err
+	}
+	return batchOutcome{id: share.ID, result: share}, nil
+}
+
+func (r *batchRun) revokeNoteShare(p batchRevokeNoteShareParams) (batchOutcome, error) {
+	if _, err := requireNoteOwner(r.tx, r.userID, p.NoteID); err != nil {
+		return batchOutcome{}, err
+	}
+	if err := revokeNoteShareFrom(r.tx, p.NoteID, p.UserID); err != nil {
+		return batchOutcome{}, err
+	}
+	r.afterCommit = append(r.afterCommit, func() { forgetLostAssets(p.UserID) })
+	return batchOutcome{result: gin.H{"revoked": true}}, nil
+}
+
+// notifyMentions syncs a note's mentions once the batch is committed, so
+// shares made later in the same batch count towards who can read it. A note
+// deleted later in the batch is skipped.
+func (r *batchRun) notifyMentions(noteID uint) {
+	r.afterCommit = append(r.afterCommit, func() {
+		var note models.Note
+		if err := config.DB.First(&note, noteID).Error; err != nil {
+			return
+		}
+		syncMentions(note, models.MentionSourceNote, note.NoteID, r.actor, note.Body)
+	})
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+func TestRunBatchKeepsDollarTitles(t *testing.T) {
+	setupTestDB(t)
+	router := testRouter()
+	router.POST("/batch", middleware.RequireAuth(), RunBatch)
+
+	status, body := testRequest(t, router, testOwner, http.MethodPost, "/batch", gin.H{
+		"operations": []gin.H{
+			{"op": BatchCreateFolder, "ref": "docs", "params": gin.H{"name": "$docs"}},
+			{"op": BatchCreateNote, "ref": "budget", "params": gin.H{"folderId": "$docs", "title": "$5 budget"}},
+			{"op": BatchCreateNote, "params": gin.H{"folderId": "$docs", "title": "$budget", "body": "$docs"}},
+		},
+	})
+	if status != http.StatusCreated && status != http.StatusOK {
+		t.Fatalf("batch failed with %d: %v", status, body)
+	}
+
+	var notes []models.Note
+	config.DB.Order("note_id").Find(&notes)
+	if len(notes) != 2 {
+		t.Fatalf("got %d notes, want 2", len(notes))
+	}
+	want := []struct{ title, body string }{{"$5 budget", ""}, {"$budget", "$docs"}}
+	for i, note := range notes {
+		if note.Title != want[i].title || note.Body != want[i].body {
+			t.Errorf("note %d = %q / %q, want %q / %q", i, note.Title, note.Body, want[i].title, want[i].body)
+		}
+	}
+
+	var folder models.Folder
+	config.DB.First(&folder, notes[0].FolderID)
+	if folder.Name != "$docs" {
+		t.Errorf("folder name = %q, want %q", folder.Name, "$docs")
+	}
+}
+
+func TestRunBatchRejectsUnknownIDReference(t *testing.T) {
+	setupTestDB(t)
+	router := testRouter()
+	router.POST("/batch", middleware.RequireAuth(), RunBatch)
+
+	status, _ := testRequest(t, router, testOwner, http.MethodPost, "/batch", gin.H{
+		"operations": []gin.H{
+			{"op": BatchCreateNote, "params": gin.H{"folderId": "$missing", "title": "Plan"}},
+		},
+	})
+	if status != http.StatusBadRequest && status != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want a rejected batch", status)
+	}
+}
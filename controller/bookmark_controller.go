@@ -0,0 +1,385 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxRecentViews is how many recently viewed assets are kept per user
+	maxRecentViews = 50
+
+	// defaultRecentLimit is how many recent views are listed by default
+	defaultRecentLimit = 20
+)
+
+// Flags a bookmark can carry
+const (
+	bookmarkStar = "star"
+	bookmarkPin  = "pin"
+)
+
+// BookmarkedAsset is a starred or pinned asset with the asset itself loaded
+type BookmarkedAsset struct {
+	models.Bookmark
+	AccessType string         `json:"accessType"`
+	Note       *models.Note   `json:"note,omitempty"`
+	Folder     *models.Folder `json:"folder,omitempty"`
+}
+
+// RecentAsset is a recently viewed asset with the asset itself loaded
+type RecentAsset struct {
+	models.RecentView
+	AccessType string         `json:"accessType"`
+	Note       *models.Note   `json:"note,omitempty"`
+	Folder     *models.Folder `json:"folder,omitempty"`
+}
+
+// StarNote stars a note for the caller
+func StarNote(c *gin.Context) {
+	setBookmark(c, models.AssetTypeNote, "noteId", bookmarkStar, true)
+}
+
+// UnstarNote removes the caller's star from a note
+func UnstarNote(c *gin.Context) {
+	setBookmark(c, models.AssetTypeNote, "noteId", bookmarkStar, false)
+}
+
+// PinNote pins a note to the top of the caller's listings
+func PinNote(c *gin.Context) {
+	setBookmark(c, models.AssetTypeNote, "noteId", bookmarkPin, true)
+}
+
+// UnpinNote unpins a note for the caller
+func UnpinNote(c *gin.Context) {
+	setBookmark(c, models.AssetTypeNote, "noteId", bookmarkPin, false)
+}
+
+// StarFolder stars a folder for the caller
+func StarFolder(c *gin.Context) {
+	setBookmark(c, models.AssetTypeFolder, "folderId", bookmarkStar, true)
+}
+
+// UnstarFolder removes the caller's star from a folder
+func UnstarFolder(c *gin.Context) {
+	setBookmark(c, models.AssetTypeFolder, "folderId", bookmarkStar, false)
+}
+
+// PinFolder pins a folder to the top of the caller's listings
+func PinFolder(c *gin.Context) {
+	setBookmark(c, models.AssetTypeFolder, "folderId", bookmarkPin, true)
+}
+
+// UnpinFolder unpins a folder for the caller
+func UnpinFolder(c *gin.Context) {
+	setBookmark(c, models.AssetTypeFolder, "folderId", bookmarkPin, false)
+}
+
+// setBookmark sets or clears a star or pin on an asset the caller can read
+func setBookmark(c *gin.Context, assetType, param, flag string, on bool) {
+	user, _ := middleware.CurrentUser(c)
+
+	assetID, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + assetType + " ID"})
+		return
+	}
+
+	access, found := assetAccessFor(user.UserID, assetType, uint(assetID))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	if !canRead(access) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this " + assetType})
+		return
+	}
+
+	bookmark := models.Bookmark{UserID: user.UserID, AssetType: assetType, AssetID: uint(assetID)}
+	config.DB.Where(&bookmark).First(&bookmark)
+
+	switch flag {
+	case bookmarkStar:
+		bookmark.Starred = on
+	case bookmarkPin:
+		if on && !bookmark.Pinned {
+			now := time.Now()
+			bookmark.PinnedAt = &now
+		} else if !on {
+			bookmark.PinnedAt = nil
+		}
+		bookmark.Pinned = on
+	}
+
+	// A bookmark with nothing set is not kept
+	if !bookmark.Starred && !bookmark.Pinned {
+		if bookmark.ID != 0 {
+			if err := config.DB.Delete(&bookmark).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Bookmark removed successfully",
+			"bookmark": bookmark,
+		})
+		return
+	}
+
+	if err := config.DB.Save(&bookmark).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Bookmark updated successfully",
+		"bookmark": bookmark,
+	})
+}
+
+// ListBookmarks lists the caller's starred and pinned assets, pinned first.
+// ?type=note|folder restricts the list to one asset type.
+func ListBookmarks(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	query := config.DB.Where("user_id = ?", user.UserID)
+	if assetType := c.Query("type"); assetType != "" {
+		if assetType != models.AssetTypeNote && assetType != models.AssetTypeFolder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be note or folder"})
+			return
+		}
+		query = query.Where("asset_type = ?", assetType)
+	}
+
+	var bookmarks []models.Bookmark
+	if err := query.Order("pinned DESC").Order("pinned_at DESC").Order("updated_at DESC").Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bookmarks"})
+		return
+	}
+
+	assets := make([]BookmarkedAsset, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		note, folder, access := loadUserAsset(user.UserID, bookmark.AssetType, bookmark.AssetID)
+		if !canRead(access) {
+			continue
+		}
+		assets = append(assets, BookmarkedAsset{
+			Bookmark:   bookmark,
+			AccessType: access,
+			Note:       note,
+			Folder:     folder,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bookmarks": assets})
+}
+
+// ListRecentViews lists the assets the caller opened most recently.
+// ?limit= caps the list, up to the number of views kept.
+func ListRecentViews(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	limit := defaultRecentLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(parsed, maxRecentViews)
+	}
+
+	var views []models.RecentView
+	if err := config.DB.Where("user_id = ?", user.UserID).Order("viewed_at DESC").Find(&views).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recently viewed assets"})
+		return
+	}
+
+	assets := make([]RecentAsset, 0, limit)
+	for _, view := range views {
+		if len(assets) == limit {
+			break
+		}
+		note, folder, access := loadUserAsset(user.UserID, view.AssetType, view.AssetID)
+		if !canRead(access) {
+			continue
+		}
+		assets = append(assets, RecentAsset{
+			RecentView: view,
+			AccessType: access,
+			Note:       note,
+			Folder:     folder,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recent": assets})
+}
+
+// loadUserAsset loads a note or folder with the user's access to it. The
+// access is none if the asset no longer exists.
+func loadUserAsset(userID uint, assetType string, assetID uint) (*models.Note, *models.Folder, string) {
+	if assetType == models.AssetTypeFolder {
+		var folder models.Folder
+		if err := config.DB.Preload("Owner").First(&folder, assetID).Error; err != nil {
+			return nil, nil, AccessNone
+		}
+		return nil, &folder, folderAccessFor(userID, folder)
+	}
+
+	var note models.Note
+	if err := config.DB.Preload("Owner").Preload("Folder").First(&note, assetID).Error; err != nil {
+		return nil, nil, AccessNone
+	}
+	return &note, nil, noteAccessFor(userID, note)
+}
+
+// recordView notes that the caller opened an asset. Anonymous readers and
+// callers without read access are not recorded.
+func recordView(c *gin.Context, assetType string, assetID uint, access string) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok || !canRead(access) {
+		return
+	}
+
+	view := models.RecentView{
+		UserID:    user.UserID,
+		AssetType: assetType,
+		AssetID:   assetID,
+		ViewedAt:  time.Now(),
+	}
+	config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "asset_type"}, {Name: "asset_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"viewed_at"}),
+	}).Create(&view)
+
+	// Only the most recent views are kept
+	config.DB.Where("user_id = ? AND id NOT IN (?)", user.UserID,
+		config.DB.Model(&models.RecentView{}).Select("id").Where("user_id = ?", user.UserID).Order("viewed_at DESC").Limit(maxRecentViews),
+	).Delete(&models.RecentView{})
+}
+
+// pinnedFirst orders notes the user pinned ahead of the rest, most recently
+// pinned first, and marks the ones they starred or pinned
+func pinnedFirst(userID uint, notes []NoteWithAccess) {
+	byNote := userBookmarks(userID, models.AssetTypeNote)
+	for i := range notes {
+		bookmark := byNote[notes[i].NoteID]
+		notes[i].Starred = bookmark.Starred
+		notes[i].Pinned = bookmark.Pinned
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		return pinnedBefore(byNote[notes[i].NoteID], byNote[notes[j].NoteID])
+	})
+}
+
+// pinnedFoldersFirst orders folders like pinnedFirst orders notes
+func pinnedFoldersFirst(userID uint, folders []FolderWithAccess) {
+	byFolder := userBookmarks(userID, models.AssetTypeFolder)
+	for i := range folders {
+		bookmark := byFolder[folders[i].FolderID]
+		folders[i].Starred = bookmark.Starred
+		folders[i].Pinned = bookmark.Pinned
+	}
+	sort.SliceStable(folders, func(i, j int) bool {
+		return pinnedBefore(byFolder[folders[i].FolderID], byFolder[folders[j].FolderID])
+	})
+}
+
+// pinnedAssetsFirst orders the folders and notes of a listing by the user's
+// pins
+func pinnedAssetsFirst(userID uint, assets AssetResponse) {
+	pinnedFoldersFirst(userID, assets.Folders)
+	pinnedFirst(userID, assets.Notes)
+}
+
+// userBookmarks returns the user's bookmarks on one type of asset by asset id
+func userBookmarks(userID uint, assetType string) map[uint]models.Bookmark {
+	var bookmarks []models.Bookmark
+	config.DB.Where("user_id = ? AND asset_type = ?", userID, assetType).Find(&bookmarks)
+
+	byAsset := make(map[uint]models.Bookmark, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		byAsset[bookmark.AssetID] = bookmark
+	}
+	return byAsset
+}
+
+// pinnedBefore reports whether an asset bookmarked as a goes ahead of one
+// bookmarked as b: pinned assets come first, most recently pinned first
+func pinnedBefore(a, b models.Bookmark) bool {
+	if a.Pinned != b.Pinned {
+		return a.Pinned
+	}
+	if !a.Pinned || a.PinnedAt == nil || b.PinnedAt == nil {
+		return false
+	}
+	return a.PinnedAt.After(*b.PinnedAt)
+}
+
+// forgetLostAssets removes the user's bookmarks, recent views and
+// notifications on assets they can no longer read. It runs once a revocation
+// has committed, so a failure is logged rather than undoing the revocation.
+func forgetLostAssets(userID uint) {
+	if err := dropLostAssets(userID); err != nil {
+		log.Printf("bookmarks: forgetting assets user %d can no longer read: %v", userID, err)
+	}
+}
+
+// dropLostAssets does the work of forgetLostAssets
+func dropLostAssets(userID uint) error {
+	var bookmarks []models.Bookmark
+	if err := config.DB.Where("user_id = ?", userID).Find(&bookmarks).Error; err != nil {
+		return err
+	}
+	for _, bookmark := range bookmarks {
+		if access, _ := assetAccessFor(userID, bookmark.AssetType, bookmark.AssetID); !canRead(access) {
+			if err := config.DB.Delete(&bookmark).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var views []models.RecentView
+	if err := config.DB.Where("user_id = ?", userID).Find(&views).Error; err != nil {
+		return err
+	}
+	for _, view := range views {
+		if access, _ := assetAccessFor(userID, view.AssetType, view.AssetID); !canRead(access) {
+			if err := config.DB.Delete(&view).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var notifiedNotes []uint
+	if err := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID).Distinct().Pluck("note_id", &notifiedNotes).Error; err != nil {
+		return err
+	}
+	for _, noteID := range notifiedNotes {
+		if access, _ := assetAccessFor(userID, models.AssetTypeNote, noteID); !canRead(access) {
+			if err := config.DB.Where("user_id = ? AND note_id = ?", userID, noteID).Delete(&models.Notification{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// forgetAssets removes every user's bookmarks and recent views on deleted
+// assets, inside the given transaction
+func forgetAssets(tx *gorm.DB, assetType string, assetIDs interface{}) error {
+	if err := tx.Where("asset_type = ? AND asset_id IN (?)", assetType, assetIDs).Delete(&models.Bookmark{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("asset_type = ? AND asset_id IN (?)", assetType, assetIDs).Delete(&models.RecentView{}).Error
+}
@@ -0,0 +1,428 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// Bulk modes
+const (
+	// BulkAtomic applies every item or none of them
+	BulkAtomic = "atomic"
+	// BulkBestEffort applies the items that succeed and reports the rest
+	BulkBestEffort = "best-effort"
+)
+
+// Outcomes of a bulk item
+const (
+	BulkSucceeded  = "succeeded"
+	BulkFailed     = "failed"
+	BulkRolledBack = "rolled_back"
+)
+
+// BulkShareRequest represents a request to share assets with several users
+type BulkShareRequest struct {
+	IDs     []uint `json:"ids" binding:"required,min=1,max=500"`
+	UserIDs []uint `json:"userIds" binding:"required,min=1,max=100"`
+	Access  string `json:"access" binding:"required,oneof=read write"`
+	TeamID  *uint  `json:"teamId"` // share on behalf of a team the users are in
+	Mode    string `json:"mode" binding:"omitempty,oneof=atomic best-effort"`
+}
+
+// BulkRevokeRequest represents a request to revoke shares from several users
+type BulkRevokeRequest struct {
+	IDs     []uint `json:"ids" binding:"required,min=1,max=500"`
+	UserIDs []uint `json:"userIds" binding:"required,min=1,max=100"`
+	Mode    string `json:"mode" binding:"omitempty,oneof=atomic best-effort"`
+}
+
+// BulkMoveRequest represents a request to move notes into a folder
+type BulkMoveRequest struct {
+	IDs      []uint `json:"ids" binding:"required,min=1,max=500"`
+	FolderID uint   `json:"folderId" binding:"required"`
+	Mode     string `json:"mode" binding:"omitempty,oneof=atomic best-effort"`
+}
+
+// BulkDeleteRequest represents a request to delete several assets
+type BulkDeleteRequest struct {
+	IDs  []uint `json:"ids" binding:"required,min=1,max=500"`
+	Mode string `json:"mode" binding:"omitempty,oneof=atomic best-effort"`
+}
+
+// BulkItemResult reports what happened to one item of a bulk call
+type BulkItemResult struct {
+	ID     uint   `json:"id"`
+	UserID uint   `json:"userId,omitempty"`
+	Status string `json:"status"`
+	Code   int    `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkItem is one asset, or one asset and user pair, of a bulk call
+type bulkItem struct {
+	ID     uint
+	UserID uint
+}
+
+// bulkAction is what a bulk call does to each item. check runs first for
+// every item, against committed data and outside the transaction, so access
+// is checked the same way as for single calls. apply then makes the change
+// inside the transaction. afterCommit, if set, runs once changes were
+// committed.
+type bulkAction struct {
+	check       func(item bulkItem) error
+	apply       func(tx *gorm.DB, item bulkItem) error
+	afterCommit func()
+}
+
+// BulkShareNotes shares notes with users. The caller must own each note.
+func BulkShareNotes(c *gin.Context) {
+	var req BulkShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+	if !allowShareTeam(c, req.TeamID, req.UserIDs) {
+		return
+	}
+
+	runBulk(c, req.Mode, pairItems(req.IDs, req.UserIDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireNoteOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			_, _, err := shareNoteWith(tx, item.ID, item.UserID, req.Access, req.TeamID)
+			return err
+		},
+	})
+}
+
+// BulkRevokeNoteShares revokes note shares from users. The caller must own
+// each note.
+func BulkRevokeNoteShares(c *gin.Context) {
+	var req BulkRevokeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	runBulk(c, req.Mode, pairItems(req.IDs, req.UserIDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireNoteOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			return revokeNoteShareFrom(tx, item.ID, item.UserID)
+		},
+		afterCommit: forgetRevokedUsers(req.UserIDs),
+	})
+}
+
+// BulkShareFolders shares folders with users. The caller must own each folder.
+func BulkShareFolders(c *gin.Context) {
+	var req BulkShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+	if !allowShareTeam(c, req.TeamID, req.UserIDs) {
+		return
+	}
+
+	runBulk(c, req.Mode, pairItems(req.IDs, req.UserIDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireFolderOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			_, _, err := shareFolderWith(tx, item.ID, item.UserID, req.Access, req.TeamID)
+			return err
+		},
+	})
+}
+
+// BulkRevokeFolderShares revokes folder shares from users. The caller must
+// own each folder.
+func BulkRevokeFolderShares(c *gin.Context) {
+	var req BulkRevokeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	runBulk(c, req.Mode, pairItems(req.IDs, req.UserIDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireFolderOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			return revokeFolderShareFrom(tx, item.ID, item.UserID)
+		},
+		afterCommit: forgetRevokedUsers(req.UserIDs),
+	})
+}
+
+// BulkMoveNotes moves notes into a folder. The caller must own each note or
+// the folder it is in, and needs write access to the destination folder.
+func BulkMoveNotes(c *gin.Context) {
+	var req BulkMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	var folder models.Folder
+	if err := config.DB.First(&folder, req.FolderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+	if !canWrite(folderAccessFor(user.UserID, folder)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have write access to the destination folder"})
+		return
+	}
+
+	runBulk(c, req.Mode, idItems(req.IDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireNoteOrFolderOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			// Shares and ownership may have changed since the check
+			note, err := requireNoteOrFolderOwner(tx, user.UserID, item.ID)
+			if err != nil {
+				return err
+			}
+			var destination models.Folder
+			if err := tx.First(&destination, req.FolderID).Error; err != nil {
+				return failAction(http.StatusNotFound, "Folder not found")
+			}
+			if !canWrite(folderAccessIn(tx, user.UserID, destination)) {
+				return failAction(http.StatusForbidden, "You do not have write access to the destination folder")
+			}
+			return moveNoteTo(tx, &note, req.FolderID)
+		},
+	})
+}
+
+// BulkDeleteNotes deletes notes. The caller must own each note or the
+// folder it is in.
+func BulkDeleteNotes(c *gin.Context) {
+	var req BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	runBulk(c, req.Mode, idItems(req.IDs), bulkAction{
+		check: func(item bulkItem) error {
+			var note models.Note
+			if err := config.DB.Preload("Folder").First(&note, item.ID).Error; err != nil {
+				return failAction(http.StatusNotFound, "Note not found")
+			}
+			if note.OwnerID != user.UserID && note.Folder.OwnerID != user.UserID {
+				return failAction(http.StatusForbidden, "Only the note or folder owner can delete this note")
+			}
+			return nil
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			var note models.Note
+			if err := tx.First(&note, item.ID).Error; err != nil {
+				return failAction(http.StatusNotFound, "Note not found")
+			}
+			return deleteNoteTree(tx, note)
+		},
+	})
+}
+
+// BulkDeleteFolders deletes folders with their notes. The caller must own
+// each folder.
+func BulkDeleteFolders(c *gin.Context) {
+	var req BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	runBulk(c, req.Mode, idItems(req.IDs), bulkAction{
+		check: func(item bulkItem) error {
+			_, err := requireFolderOwner(config.DB, user.UserID, item.ID)
+			return err
+		},
+		apply: func(tx *gorm.DB, item bulkItem) error {
+			var folder models.Folder
+			if err := tx.First(&folder, item.ID).Error; err != nil {
+				return failAction(http.StatusNotFound, "Folder not found")
+			}
+			return deleteFolderTree(tx, folder)
+		},
+	})
+}
+
+// runBulk runs an action on every item inside one transaction and writes the
+// per-item results. Each item is applied in its own savepoint so a failed
+// item leaves no partial changes. In atomic mode any failure rolls back every
+// item; in best-effort mode the successful items are committed.
+func runBulk(c *gin.Context, mode string, items []bulkItem, action bulkAction) {
+	if mode == "" {
+		mode = BulkAtomic
+	}
+
+	results := make([]BulkItemResult, len(items))
+	failed := 0
+	fail := func(i int, err error) {
+		results[i].Status = BulkFailed
+		results[i].Code = actionStatus(err)
+		results[i].Error = err.Error()
+		failed++
+	}
+
+	for i, item := range items {
+		results[i] = BulkItemResult{ID: item.ID, UserID: item.UserID, Status: BulkSucceeded}
+		if action.check != nil {
+			if err := action.check(item); err != nil {
+				fail(i, err)
+			}
+		}
+	}
+
+	// An atomic call with failed checks has nothing left to do
+	err := errBulkRolledBack
+	if mode == BulkBestEffort || failed == 0 {
+		err = runTransaction(config.DB, func(tx *gorm.DB) error {
+			for i, item := range items {
+				if results[i].Status == BulkFailed {
+					continue
+				}
+
+				// A nested transaction is a savepoint, and drops the work
+				// the item left for after the commit if it is rolled back
+				err := runTransaction(tx, func(tx *gorm.DB) error {
+					return action.apply(tx, item)
+				})
+				if err != nil {
+					fail(i, err)
+				}
+			}
+
+			if mode == BulkAtomic && failed > 0 {
+				return errBulkRolledBack
+			}
+			return nil
+		})
+	}
+
+	if err == errBulkRolledBack {
+		for i := range results {
+			if results[i].Status == BulkSucceeded {
+				results[i].Status = BulkRolledBack
+			}
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   fmt.Sprintf("No changes were made because %d of %d items failed", failed, len(items)),
+			"mode":    mode,
+			"results": results,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply bulk operation"})
+		return
+	}
+
+	if action.afterCommit != nil {
+		action.afterCommit()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Bulk operation completed",
+		"mode":      mode,
+		"succeeded": len(items) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// errBulkRolledBack aborts the transaction of an atomic bulk call
+var errBulkRolledBack = failAction(http.StatusUnprocessableEntity, "bulk operation rolled back")
+
+// requireNoteOwner loads a note and checks that the user owns it
+func requireNoteOwner(db *gorm.DB, userID, noteID uint) (models.Note, error) {
+	var note models.Note
+	if err := db.First(&note, noteID).Error; err != nil {
+		return note, failAction(http.StatusNotFound, "Note not found")
+	}
+	if note.OwnerID != userID {
+		return note, failAction(http.StatusForbidden, "Only the note owner can manage its shares")
+	}
+	return note, nil
+}
+
+// requireNoteOrFolderOwner loads a note and checks that the user owns it or
+// the folder it is in
+func requireNoteOrFolderOwner(db *gorm.DB, userID, noteID uint) (models.Note, error) {
+	var note models.Note
+	if err := db.Preload("Folder").First(&note, noteID).Error; err != nil {
+		return note, failAction(http.StatusNotFound, "Note not found")
+	}
+	if note.OwnerID != userID && note.Folder.OwnerID != userID {
+		return note, failAction(http.StatusForbidden, "Only the note or folder owner can do this")
+	}
+	return note, nil
+}
+
+// requireFolderOwner loads a folder and checks that the user owns it
+func requireFolderOwner(db *gorm.DB, userID, folderID uint) (models.Folder, error) {
+	var folder models.Folder
+	if err := db.First(&folder, folderID).Error; err != nil {
+		return folder, failAction(http.StatusNotFound, "Folder not found")
+	}
+	if folder.OwnerID != userID {
+		return folder, failAction(http.StatusForbidden, "Only the folder owner can do this")
+	}
+	return folder, nil
+}
+
+// forgetRevokedUsers drops bookmarks, recent views and notifications that
+// users lost access to in a bulk revoke
+func forgetRevokedUsers(userIDs []uint) func() {
+	return func() {
+		for _, userID := range uniqueIDs(userIDs) {
+			forgetLostAssets(userID)
+		}
+	}
+}
+
+// idItems builds one bulk item per id, dropping duplicates
+func idItems(ids []uint) []bulkItem {
+	items := make([]bulkItem, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		items = append(items, bulkItem{ID: id})
+	}
+	return items
+}
+
+// pairItems builds one bulk item per asset and user pair, dropping duplicates
+func pairItems(ids, userIDs []uint) []bulkItem {
+	ids, userIDs = uniqueIDs(ids), uniqueIDs(userIDs)
+	items := make([]bulkItem, 0, len(ids)*len(userIDs))
+	for _, id := range ids {
+		for _, userID := range userIDs {
+			items = append(items, bulkItem{ID: id, UserID: userID})
+		}
+	}
+	return items
+}
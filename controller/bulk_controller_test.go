@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+func TestBulkMoveNotesNeedsAnOwnerAndForgetsLostAccess(t *testing.T) {
+	setupTestDB(t)
+	source := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, source, testOwner, "plan")
+	destination := createTestFolder(t, testOwner, "archive")
+	writerFolder := createTestFolder(t, testReader, "mine")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		if _, _, err := shareFolderWith(tx, source.FolderID, testManager, AccessRead, nil); err != nil {
+			return err
+		}
+		_, _, err := shareNoteWith(tx, note.NoteID, testReader, AccessWrite, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Create(&models.Bookmark{UserID: testManager, AssetType: models.AssetTypeNote, AssetID: note.NoteID, Starred: true})
+
+	router := testRouter()
+	router.POST("/bulk/notes/move", middleware.RequireAuth(), BulkMoveNotes)
+
+	// A writer cannot take the note out of the owner's folder
+	status, response := testRequest(t, router, testReader, http.MethodPost, "/bulk/notes/move",
+		gin.H{"ids": []uint{note.NoteID}, "folderId": writerFolder.FolderID})
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("move by a writer returned %d: %v", status, response)
+	}
+	config.DB.First(&note, note.NoteID)
+	if note.FolderID != source.FolderID {
+		t.Fatal("a writer moved the note")
+	}
+
+	status, response = testRequest(t, router, testOwner, http.MethodPost, "/bulk/notes/move",
+		gin.H{"ids": []uint{note.NoteID}, "folderId": destination.FolderID})
+	if status != http.StatusOK {
+		t.Fatalf("move by the owner returned %d: %v", status, response)
+	}
+	if canRead(noteAccessIn(config.DB, testManager, note)) {
+		t.Fatal("the source folder's reader kept access to the moved note")
+	}
+	var bookmarks int64
+	config.DB.Model(&models.Bookmark{}).Where("user_id = ? AND asset_id = ?", testManager, note.NoteID).Count(&bookmarks)
+	if bookmarks != 0 {
+		t.Fatal("the reader who lost the note kept their bookmark")
+	}
+}
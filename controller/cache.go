@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// cacheTTL bounds how long a cached listing or access decision is served
+// should an invalidation ever be missed
+const cacheTTL = 10 * time.Minute
+
+// assetsCacheKey names a user's cached asset listing
+func assetsCacheKey(userID uint) string {
+	return fmt.Sprintf("assets:user:%d", userID)
+}
+
+// accessCacheKey names a cached access decision
+func accessCacheKey(userID uint, assetType string, assetID uint) string {
+	return fmt.Sprintf("access:%d:%s:%d", userID, assetType, assetID)
+}
+
+// cachedAccess returns the access a user has on an asset, from the cache
+// when it holds the decision
+func cachedAccess(userID uint, assetType string, assetID uint) string {
+	if config.Cache == nil {
+		return projectedAccess(config.DB, userID, assetType, assetID)
+	}
+
+	ctx := context.Background()
+	key := accessCacheKey(userID, assetType, assetID)
+	if value, ok, _ := config.Cache.Get(ctx, key); ok {
+		return string(value)
+	}
+	access := projectedAccess(config.DB, userID, assetType, assetID)
+	config.Cache.Set(ctx, key, []byte(access), cacheTTL)
+	return access
+}
+
+// cachedUserAssets returns a user's asset listing, from the cache when it
+// holds the listing
+func cachedUserAssets(userID uint) (AssetResponse, error) {
+	ctx := context.Background()
+	key := assetsCacheKey(userID)
+	if config.Cache != nil {
+		if value, ok, _ := config.Cache.Get(ctx, key); ok {
+			var assets AssetResponse
+			if err := json.Unmarshal(value, &assets); err == nil {
+				return assets, nil
+			}
+		}
+	}
+
+	assets, err := directAssets([]uint{userID}, tagFilter{}, false)
+	if err != nil {
+		return assets, err
+	}
+	if config.Cache != nil {
+		if encoded, err := json.Marshal(assets); err == nil {
+			config.Cache.Set(ctx, key, encoded, cacheTTL)
+		}
+	}
+	return assets, nil
+}
+
+// invalidateAccess drops the cached access decisions for the given grants
+// and the asset listings of their users once db's transaction commits
+func invalidateAccess(db *gorm.DB, grants []models.EffectiveAccess) {
+	if config.Cache == nil || len(grants) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	keys := []string{}
+	for _, grant := range grants {
+		for _, key := range []string{
+			accessCacheKey(grant.UserID, grant.AssetType, grant.AssetID),
+			assetsCacheKey(grant.UserID),
+		} {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	forgetCached(db, keys)
+}
+
+// invalidateListings drops the cached asset listings that show the given
+// assets once db's transaction commits, after their contents changed. A note
+// listing shows its folder, so a folder's listings include those of the notes
+// inside it.
+func invalidateListings(db *gorm.DB, assetType string, assetIDs ...uint) {
+	if config.Cache == nil || len(assetIDs) == 0 {
+		return
+	}
+
+	listed := db.Where("asset_type = ? AND asset_id IN ?", assetType, assetIDs)
+	if assetType == models.AssetTypeFolder {
+		notes := db.Model(&models.Note{}).Select("note_id").Where("folder_id IN ?", assetIDs)
+		listed = listed.Or("asset_type = ? AND asset_id IN (?)", models.AssetTypeNote, notes)
+	}
+
+	var userIDs []uint
+	db.Model(&models.EffectiveAccess{}).
+		Where("source IN ?", directSources).
+		Where(listed).
+		Distinct().
+		Pluck("user_id", &userIDs)
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = assetsCacheKey(userID)
+	}
+	forgetCached(db, keys)
+}
+
+// invalidateTaggedListings drops the cached asset listings that show notes
+// carrying a tag, before the tag is renamed, merged or deleted
+func invalidateTaggedListings(db *gorm.DB, tagID uint) {
+	if config.Cache == nil {
+		return
+	}
+	var noteIDs []uint
+	db.Model(&models.NoteTag{}).Where("tag_id = ?", tagID).Pluck("note_id", &noteIDs)
+	invalidateListings(db, models.AssetTypeNote, noteIDs...)
+}
+
+// forgetCached deletes keys once the transaction db belongs to has
+// committed, so a read made before then cannot cache the old state for good
+func forgetCached(db *gorm.DB, keys []string) {
+	if config.Cache == nil || len(keys) == 0 {
+		return
+	}
+	afterCommit(db, func() {
+		config.Cache.Delete(context.Background(), keys...)
+	})
+}
+
+// forgetAllAccess drops every cached access decision and asset listing once
+// the transaction db belongs to has committed
+func forgetAllAccess(db *gorm.DB) {
+	if config.Cache == nil {
+		return
+	}
+	afterCommit(db, func() {
+		ctx := context.Background()
+		config.Cache.DeletePrefix(ctx, "access:")
+		config.Cache.DeletePrefix(ctx, "assets:")
+	})
+}
+
+// GetCacheStats reports the cache backend and its hits, misses, sets and
+// invalidations for each kind of key (Admin-only)
+func GetCacheStats(c *gin.Context) {
+	caller, _ := middleware.CurrentUser(c)
+	if caller.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can view cache statistics"})
+		return
+	}
+
+	if config.Cache == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"backend": config.Cache.Backend(),
+		"stats":   config.Cache.Stats(),
+	})
+}
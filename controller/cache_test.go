@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seta-namnv-6798/go-apis/cache"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// useTestCache points config.Cache at a fresh in-memory cache
+func useTestCache(t *testing.T) {
+	t.Helper()
+	store, err := cache.NewLRUStore(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Cache = cache.NewMetered(store, "lru")
+}
+
+func cachedKey(key string) bool {
+	_, ok, _ := config.Cache.Get(context.Background(), key)
+	return ok
+}
+
+func TestForgetCachedWaitsForCommit(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	ctx := context.Background()
+
+	config.Cache.Set(ctx, "assets:user:1", []byte("[]"), time.Minute)
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		forgetCached(tx, []string{"assets:user:1"})
+		if !cachedKey("assets:user:1") {
+			t.Error("key deleted before the transaction committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cachedKey("assets:user:1") {
+		t.Fatal("key still cached after the transaction committed")
+	}
+}
+
+func TestForgetCachedSkipsRolledBackTransactions(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	ctx := context.Background()
+
+	config.Cache.Set(ctx, "assets:user:1", []byte("[]"), time.Minute)
+	failed := errors.New("rolled back")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		forgetCached(tx, []string{"assets:user:1"})
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("runTransaction returned %v", err)
+	}
+	if !cachedKey("assets:user:1") {
+		t.Fatal("rolled back transaction dropped the cached key")
+	}
+}
+
+func TestForgetCachedWaitsForOuterTransaction(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	ctx := context.Background()
+
+	config.Cache.Set(ctx, "assets:user:1", []byte("[]"), time.Minute)
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := runTransaction(tx, func(inner *gorm.DB) error {
+			forgetCached(inner, []string{"assets:user:1"})
+			return nil
+		}); err != nil {
+			return err
+		}
+		if !cachedKey("assets:user:1") {
+			t.Error("key deleted when the nested transaction finished")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cachedKey("assets:user:1") {
+		t.Fatal("key still cached after the outer transaction committed")
+	}
+}
+
+func TestSharingInvalidatesCachedAccessAndListings(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	folder := createTestFolder(t, testOwner, "shared")
+
+	router := testRouter()
+	router.GET("/users/:userId/assets", GetUserAssets)
+	router.POST("/folders/:folderId/share", ShareFolder)
+	router.DELETE("/folders/:folderId/share/:userId", RevokeFolderShare)
+	listed := func() int {
+		t.Helper()
+		status, response := testRequest(t, router, testReader, http.MethodGet, fmt.Sprintf("/users/%d/assets", testReader), nil)
+		if status != http.StatusOK {
+			t.Fatalf("listing assets returned %d: %v", status, response)
+		}
+		assets, _ := response["assets"].(map[string]interface{})
+		folders, _ := assets["folders"].([]interface{})
+		return len(folders)
+	}
+
+	// Cache the reader's lack of access and their empty listing
+	if access := folderAccessFor(testReader, folder); access != "" {
+		t.Fatalf("reader starts with %q access", access)
+	}
+	if n := listed(); n != 0 {
+		t.Fatalf("reader starts with %d folders", n)
+	}
+	if !cachedKey(accessCacheKey(testReader, models.AssetTypeFolder, folder.FolderID)) || !cachedKey(assetsCacheKey(testReader)) {
+		t.Fatal("expected the access decision and listing to be cached")
+	}
+
+	status, response := testRequest(t, router, testOwner, http.MethodPost, fmt.Sprintf("/folders/%d/share", folder.FolderID),
+		map[string]interface{}{"userId": testReader, "access": AccessRead})
+	if status != http.StatusCreated {
+		t.Fatalf("sharing returned %d: %v", status, response)
+	}
+	if access := folderAccessFor(testReader, folder); access != AccessRead {
+		t.Fatalf("after sharing reader has %q access, want read", access)
+	}
+	if n := listed(); n != 1 {
+		t.Fatalf("after sharing reader lists %d folders, want 1", n)
+	}
+
+	status, response = testRequest(t, router, testOwner, http.MethodDelete, fmt.Sprintf("/folders/%d/share/%d", folder.FolderID, testReader), nil)
+	if status != http.StatusOK {
+		t.Fatalf("revoking returned %d: %v", status, response)
+	}
+	if access := folderAccessFor(testReader, folder); access != "" {
+		t.Fatalf("after revoking reader has %q access", access)
+	}
+	if n := listed(); n != 0 {
+		t.Fatalf("after revoking reader lists %d folders, want 0", n)
+	}
+}
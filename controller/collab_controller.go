@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/seta-namnv-6798/go-apis/collab"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/presence"
+)
+
+// collabHub holds the live editing sessions, one per note
+var collabHub = collab.NewHub(loadNoteBody, persistNoteBody)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Cross-origin clients authenticate with a token, not cookies
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func loadNoteBody(noteID uint) (string, error) {
+	var note models.Note
+	if err := config.DB.First(&note, noteID).Error; err != nil {
+		return "", err
+	}
+	return note.Body, nil
+}
+
+func persistNoteBody(noteID uint, body string) error {
+	var note models.Note
+	if err := config.DB.First(&note, noteID).Error; err != nil {
+		return err
+	}
+	if err := config.DB.Model(&note).Update("body", body).Error; err != nil {
+		return err
+	}
+	invalidateListings(config.DB, models.AssetTypeNote, note.NoteID)
+
+	// Session snapshots are not attributed to a single editor
+	syncMentions(note, models.MentionSourceNote, note.NoteID, nil, body)
+	return reanchorComments(config.DB, noteID, body)
+}
+
+// EditNoteSession upgrades to a WebSocket and joins the note's live editing
+// session. Users with write access can submit operations, users with read
+// access follow along read-only. Access is checked again for every
+// operation, and a client that can no longer read the note is dropped.
+func EditNoteSession(c *gin.Context) {
+	noteIDStr := c.Param("noteId")
+	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+
+	var note models.Note
+	if err := config.DB.First(&note, noteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	access := noteAccessFor(user.UserID, note)
+	if !canRead(access) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this note"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	client := collab.NewClient(user.UserID, !canWrite(access))
+	session, err := collabHub.Join(note.NoteID, client)
+	if err != nil {
+		conn.WriteJSON(collab.Message{Type: "error", Error: "Failed to open editing session"})
+		return
+	}
+	defer collabHub.Leave(session, client)
+
+	// Keep the editor on the note's presence roster while connected
+	stopPresence := trackSessionPresence(note.NoteID, user, client)
+	defer stopPresence()
+
+	go func() {
+		for msg := range client.Send {
+			if err := conn.WriteJSON(msg); err != nil {
+				conn.Close()
+				return
+			}
+		}
+		// The session dropped us, so stop reading as well
+		conn.Close()
+	}()
+
+	for {
+		var msg collab.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if msg.Type != "op" {
+			continue
+		}
+		// Shares can be revoked while the client is connected
+		access = noteAccessFor(user.UserID, note)
+		if !canRead(access) {
+			session.Notify(client, collab.Message{Type: "error", Error: "You no longer have access to this note"})
+			return
+		}
+		if client.ReadOnly || !canWrite(access) {
+			session.Notify(client, collab.Message{Type: "error", Error: "You do not have write access to this note"})
+			continue
+		}
+		if err := session.Submit(client, msg.Revision, msg.Operation); err != nil {
+			if err == collab.ErrClientDropped {
+				return
+			}
+			session.Notify(client, collab.Message{Type: "error", Error: err.Error()})
+		}
+	}
+}
+
+// trackSessionPresence heartbeats a WebSocket editor onto the note's roster
+// until the returned function is called
+func trackSessionPresence(noteID uint, user models.User, client *collab.Client) func() {
+	key := presence.Key{AssetType: presence.AssetNote, AssetID: noteID}
+	viewer := presence.Viewer{
+		SessionID: fmt.Sprintf("edit-%p", client),
+		UserID:    user.UserID,
+		Username:  user.Username,
+		State:     presence.StateEditing,
+	}
+	if client.ReadOnly {
+		viewer.State = presence.StateViewing
+	}
+	presenceTracker.Heartbeat(key, viewer)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(presence.TTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				presenceTracker.Heartbeat(key, viewer)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		presenceTracker.Leave(key, viewer.UserID, viewer.SessionID)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/seta-namnv-6798/go-apis/collab"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"gorm.io/gorm"
+)
+
+// joinTestSession opens a live editing session on a note as userID and reads
+// its init message. The session is closed when the test ends.
+func joinTestSession(t *testing.T, server *httptest.Server, userID, noteID uint) *websocket.Conn {
+	t.Helper()
+	url := fmt.Sprintf("ws%s/notes/%d/edit", strings.TrimPrefix(server.URL, "http"), noteID)
+	header := http.Header{"Authorization": {"Bearer " + testToken(t, userID)}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		// The session saves the note once the last client leaves
+		for deadline := time.Now().Add(5 * time.Second); collabHub.Active(noteID); {
+			if time.Now().After(deadline) {
+				t.Error("the editing session did not close")
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	var msg collab.Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Type != "init" {
+		t.Fatalf("joining returned %+v, %v", msg, err)
+	}
+	return conn
+}
+
+// submitTestOp appends text to a note and returns the server's reply
+func submitTestOp(t *testing.T, conn *websocket.Conn, revision int, length int, text string) (collab.Message, error) {
+	t.Helper()
+	op := collab.Operation{{Retain: length}, {Insert: text}}
+	if err := conn.WriteJSON(collab.Message{Type: "op", Revision: revision, Operation: op}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var reply collab.Message
+	err := conn.ReadJSON(&reply)
+	return reply, err
+}
+
+func TestEditNoteSessionRechecksAccessOnSubmit(t *testing.T) {
+	setupTestDB(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		_, _, err := shareNoteWith(tx, note.NoteID, testReader, AccessWrite, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := testRouter()
+	router.GET("/notes/:noteId/edit", middleware.RequireAuth(), EditNoteSession)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	conn := joinTestSession(t, server, testReader, note.NoteID)
+
+	reply, err := submitTestOp(t, conn, 0, len(note.Body), "!")
+	if err != nil || reply.Type != "ack" {
+		t.Fatalf("a writer's operation returned %+v, %v", reply, err)
+	}
+
+	// Lowered to read access, the client stays connected but cannot edit
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		_, _, err := shareNoteWith(tx, note.NoteID, testReader, AccessRead, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err = submitTestOp(t, conn, 1, len(note.Body)+1, "?")
+	if err != nil || reply.Type != "error" {
+		t.Fatalf("a reader's operation returned %+v, %v", reply, err)
+	}
+
+	// Without any access, the client is dropped from the session
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return revokeNoteShareFrom(tx, note.NoteID, testReader)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err = submitTestOp(t, conn, 1, len(note.Body)+1, "?")
+	for err == nil {
+		if reply.Type != "error" {
+			t.Fatalf("an operation without access returned %+v", reply)
+		}
+		err = conn.ReadJSON(&reply)
+	}
+	if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		t.Fatal("the session kept a client without access")
+	}
+}
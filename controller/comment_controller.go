@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/collab"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// Anchor states of a thread
+const (
+	AnchorAttached = "attached"
+	AnchorDetached = "detached"
+)
+
+// Thread filters for listing comments
+const (
+	ThreadsOpen     = "open"
+	ThreadsResolved = "resolved"
+	ThreadsAll      = "all"
+)
+
+// CommentAnchorRequest is the range of the note body a thread refers to, in
+// Unicode code points
+type CommentAnchorRequest struct {
+	Start *int `json:"start" binding:"required,min=0"`
+	End   *int `json:"end" binding:"required,min=0"`
+}
+
+// CreateCommentRequest represents the request for commenting on a note
+type CreateCommentRequest struct {
+	Body     string                `json:"body" binding:"required,max=10000"`
+	ParentID *uint                 `json:"parentId"`
+	Anchor   *CommentAnchorRequest `json:"anchor"`
+}
+
+// UpdateCommentRequest represents the request for editing a comment
+type UpdateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// ListComments lists the threads on a note with their replies.
+// ?status=open|resolved|all filters threads, all by default.
+func ListComments(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Author").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Replies.Author").
+		Where("note_id = ? AND parent_id IS NULL", note.NoteID)
+
+	switch c.DefaultQuery("status", ThreadsAll) {
+	case ThreadsOpen:
+		query = query.Where("resolved_at IS NULL")
+	case ThreadsResolved:
+		query = query.Where("resolved_at IS NOT NULL")
+	case ThreadsAll:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, resolved or all"})
+		return
+	}
+
+	var threads []models.Comment
+	if err := query.Order("created_at").Find(&threads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"noteId":  note.NoteID,
+		"threads": threads,
+	})
+}
+
+// CreateComment starts a thread on a note or replies to one. Anybody who can
+// read the note can comment.
+func CreateComment(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment := models.Comment{
+		NoteID:   note.NoteID,
+		AuthorID: user.UserID,
+		Body:     req.Body,
+	}
+
+	var thread models.Comment
+	if req.ParentID != nil {
+		if req.Anchor != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only the first comment of a thread can be anchored"})
+			return
+		}
+		if err := config.DB.Where("note_id = ?", note.NoteID).First(&thread, *req.ParentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		// Replies to replies join the same thread
+		if thread.ParentID != nil {
+			rootID := *thread.ParentID
+			thread = models.Comment{}
+			if err := config.DB.First(&thread, rootID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+				return
+			}
+		}
+		comment.ParentID = &thread.CommentID
+	}
+
+	if req.Anchor != nil {
+		anchor, valid := collab.NewAnchor(note.Body, *req.Anchor.Start, *req.Anchor.End)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Anchor must be a non-empty range within the note body"})
+			return
+		}
+		setCommentAnchor(&comment, anchor)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		// A new reply reopens a resolved thread
+		if thread.ResolvedAt != nil {
+			return tx.Model(&thread).Updates(map[string]interface{}{"resolved_at": nil, "resolved_by_id": nil}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	// Notify users mentioned in the comment
+	mentioned := syncMentions(note, models.MentionSourceComment, comment.CommentID, &user.UserID, comment.Body)
+
+	config.DB.Preload("Author").First(&comment, comment.CommentID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Comment created successfully",
+		"comment":  comment,
+		"mentions": mentioned,
+	})
+}
+
+// UpdateComment edits a comment (author only)
+func UpdateComment(c *gin.Context) {
+	comment, ok := findOwnComment(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment.Body = req.Body
+	comment.Edited = true
+	if err := config.DB.Save(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	// Notify users newly mentioned in the comment
+	var note models.Note
+	config.DB.First(&note, comment.NoteID)
+	mentioned := syncMentions(note, models.MentionSourceComment, comment.CommentID, &comment.AuthorID, comment.Body)
+
+	config.DB.Preload("Author").First(&comment, comment.CommentID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Comment updated successfully",
+		"comment":  comment,
+		"mentions": mentioned,
+	})
+}
+
+// DeleteComment deletes a comment (author only). Deleting the first comment
+// of a thread deletes the whole thread.
+func DeleteComment(c *gin.Context) {
+	comment, ok := findOwnComment(c)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		commentIDs := []uint{comment.CommentID}
+		if comment.ParentID == nil {
+			var replyIDs []uint
+			if err := tx.Model(&models.Comment{}).Where("parent_id = ?", comment.CommentID).Pluck("comment_id", &replyIDs).Error; err != nil {
+				return err
+			}
+			commentIDs = append(commentIDs, replyIDs...)
+		}
+
+		if err := tx.Where("source_type = ? AND source_id IN ?", models.MentionSourceComment, commentIDs).Delete(&models.Mention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id IN ?", commentIDs).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("comment_id IN ?", commentIDs).Delete(&models.Comment{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// ResolveThread marks a thread as resolved
+func ResolveThread(c *gin.Context) {
+	setThreadResolved(c, true)
+}
+
+// ReopenThread marks a resolved thread as open again
+func ReopenThread(c *gin.Context) {
+	setThreadResolved(c, false)
+}
+
+// setThreadResolved resolves or reopens a thread on a note the caller can read
+func setThreadResolved(c *gin.Context, resolved bool) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	thread, ok := findNoteComment(c, note)
+	if !ok {
+		return
+	}
+	if thread.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only threads can be resolved, use the first comment of the thread"})
+		return
+	}
+
+	updates := map[string]interface{}{"resolved_at": nil, "resolved_by_id": nil}
+	if resolved {
+		updates = map[string]interface{}{"resolved_at": time.Now(), "resolved_by_id": user.UserID}
+	}
+	if err := config.DB.Model(&thread).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update thread"})
+		return
+	}
+
+	config.DB.Preload("Author").First(&thread, thread.CommentID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Thread updated successfully",
+		"comment": thread,
+	})
+}
+
+// findOwnComment loads the comment named by the commentId parameter if the
+// caller can still read the note and wrote the comment. It writes the error
+// response and returns false otherwise.
+func findOwnComment(c *gin.Context) (models.Comment, bool) {
+	note, _, ok := findAccessibleNote(c, canRead)
+	if !ok {
+		return models.Comment{}, false
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	comment, ok := findNoteComment(c, note)
+	if !ok {
+		return comment, false
+	}
+	if comment.AuthorID != user.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change a comment"})
+		return comment, false
+	}
+	return comment, true
+}
+
+// findNoteComment loads the comment named by the commentId parameter if it
+// belongs to the note. It writes the error response and returns false
+// otherwise.
+func findNoteComment(c *gin.Context, note models.Note) (models.Comment, bool) {
+	var comment models.Comment
+	commentID, err := strconv.ParseUint(c.Param("commentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return comment, false
+	}
+
+	if err := config.DB.Where("note_id = ?", note.NoteID).First(&comment, commentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return comment, false
+	}
+	return comment, true
+}
+
+// setCommentAnchor stores an anchor on a thread's first comment
+func setCommentAnchor(comment *models.Comment, anchor collab.Anchor) {
+	comment.AnchorStart = &anchor.Start
+	comment.AnchorEnd = &anchor.End
+	comment.AnchorText = anchor.Text
+	comment.AnchorStatus = AnchorAttached
+}
+
+// reanchorComments moves the anchors of a note's threads after its body
+// changed. Threads whose text was removed keep it but become detached, and
+// are attached again if the text comes back.
+func reanchorComments(db *gorm.DB, noteID uint, body string) error {
+	var threads []models.Comment
+	if err := db.Where("note_id = ? AND parent_id IS NULL AND anchor_text <> ''", noteID).Find(&threads).Error; err != nil {
+		return err
+	}
+
+	for _, thread := range threads {
+		current := collab.Anchor{Start: -1, End: -1, Text: thread.AnchorText}
+		if thread.AnchorStart != nil && thread.AnchorEnd != nil {
+			current.Start, current.End = *thread.AnchorStart, *thread.AnchorEnd
+		}
+
+		updates := map[string]interface{}{
+			"anchor_start":  nil,
+			"anchor_end":    nil,
+			"anchor_status": AnchorDetached,
+		}
+		if anchor, found := collab.Relocate(body, current); found {
+			if anchor == current && thread.AnchorStatus == AnchorAttached {
+				continue
+			}
+			updates = map[string]interface{}{
+				"anchor_start":  anchor.Start,
+				"anchor_end":    anchor.End,
+				"anchor_status": AnchorAttached,
+			}
+		} else if thread.AnchorStatus == AnchorDetached {
+			continue
+		}
+
+		// Moving an anchor is not an edit of the comment
+		if err := db.Model(&thread).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
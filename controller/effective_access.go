@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// directSources are the ways of reaching an asset that list it among a
+// user's own assets; access through a folder applies to the notes inside it
+// without listing them
+var directSources = []string{models.AccessSourceOwner, models.AccessSourceShare, models.AccessSourceTeamShare}
+
+// accessScope selects the effective access rows to recompute. Rows for the
+// listed folders and notes are recomputed, or for every asset when
+// everyAsset is set; userIDs, if set, narrows that to the given users.
+type accessScope struct {
+	everyAsset bool
+	folderIDs  []uint
+	noteIDs    []uint
+	userIDs    []uint
+}
+
+// AccessDrift is a difference between the effective access table and the
+// ownership and shares it is derived from
+type AccessDrift struct {
+	Grant   models.EffectiveAccess `json:"grant"`
+	Problem string                 `json:"problem"` // "missing", "stale" or "level"
+	Stored  string                 `json:"stored,omitempty"`
+}
+
+// projectedAccess returns the strongest access the effective access table
+// gives a user on an asset
+func projectedAccess(db *gorm.DB, userID uint, assetType string, assetID uint) string {
+	var levels []string
+	db.Model(&models.EffectiveAccess{}).
+		Where("user_id = ? AND asset_type = ? AND asset_id = ?", userID, assetType, assetID).
+		Pluck("level", &levels)
+
+	access := AccessNone
+	for _, level := range levels {
+		access = strongerAccess(access, level)
+	}
+	return access
+}
+
+// projectedAccessFor returns the strongest access a user has on each of the
+// given assets. Assets the user cannot reach are left out.
+func projectedAccessFor(db *gorm.DB, userID uint, assetType string, assetIDs []uint) map[uint]string {
+	access := make(map[uint]string, len(assetIDs))
+	if len(assetIDs) == 0 {
+		return access
+	}
+
+	var grants []models.EffectiveAccess
+	db.Where("user_id = ? AND asset_type = ? AND asset_id IN ?", userID, assetType, assetIDs).Find(&grants)
+	for _, grant := range grants {
+		access[grant.AssetID] = strongerAccess(access[grant.AssetID], grant.Level)
+	}
+	return access
+}
+
+// accessibleAssets is a subquery selecting the ids of the assets of one type
+// a user can reach in any way
+func accessibleAssets(db *gorm.DB, userID uint, assetType string) *gorm.DB {
+	return db.Model(&models.EffectiveAccess{}).
+		Select("asset_id").
+		Where("user_id = ? AND asset_type = ?", userID, assetType)
+}
+
+// refreshFolderAccess recomputes the effective access on a folder and the
+// notes inside it, for the given users or for everyone
+func refreshFolderAccess(tx *gorm.DB, folderID uint, userIDs ...uint) error {
+	var noteIDs []uint
+	if err := tx.Model(&models.Note{}).Where("folder_id = ?", folderID).Pluck("note_id", &noteIDs).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to update access")
+	}
+	return refreshAccess(tx, accessScope{folderIDs: []uint{folderID}, noteIDs: noteIDs, userIDs: userIDs})
+}
+
+// refreshNoteAccess recomputes the effective access on a note, for the given
+// users or for everyone
+func refreshNoteAccess(tx *gorm.DB, noteID uint, userIDs ...uint) error {
+	return refreshAccess(tx, accessScope{noteIDs: []uint{noteID}, userIDs: userIDs})
+}
+
+// refreshUserAccess recomputes every effective access row of a user
+func refreshUserAccess(tx *gorm.DB, userID uint) error {
+	return refreshAccess(tx, accessScope{everyAsset: true, userIDs: []uint{userID}})
+}
+
+// forgetAccess removes the effective access rows of deleted assets. assetIDs
+// is a list of ids or a subquery selecting them.
+func forgetAccess(tx *gorm.DB, assetType string, assetIDs interface{}) error {
+	var forgotten []models.EffectiveAccess
+	if err := tx.Where("asset_type = ? AND asset_id IN (?)", assetType, assetIDs).Find(&forgotten).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to update access")
+	}
+	if err := tx.Where("asset_type = ? AND asset_id IN (?)", assetType, assetIDs).Delete(&models.EffectiveAccess{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to update access")
+	}
+	invalidateAccess(tx, forgotten)
+	return nil
+}
+
+// refreshAccess replaces the effective access rows in scope with ones
+// computed from ownership and shares
+func refreshAccess(tx *gorm.DB, scope accessScope) error {
+	grants, err := projectAccess(tx, scope)
+	if err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to update access")
+	}
+
+	inScope := func() *gorm.DB {
+		query := tx.Where("1 = 1")
+		if !scope.everyAsset {
+			query = query.Where("(asset_type = ? AND asset_id IN ?) OR (asset_type = ? AND asset_id IN ?)",
+				models.AssetTypeFolder, scope.folderIDs, models.AssetTypeNote, scope.noteIDs)
+		}
+		if len(scope.userIDs) > 0 {
+			query = query.Where("user_id IN ?", scope.userIDs)
+		}
+		return query
+	}
+
+	// Cached decisions go stale for both the rows replaced and their
+	// replacements. A full rebuild drops the whole cache rather than
+	// loading every row to name its keys.
+	var stale []models.EffectiveAccess
+	if !scope.everyAsset {
+		if err := inScope().Find(&stale).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to update access")
+		}
+	}
+	if err := inScope().Delete(&models.EffectiveAccess{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to update access")
+	}
+
+	if len(grants) > 0 {
+		if err := tx.CreateInBatches(&grants, 500).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to update access")
+		}
+	}
+	if scope.everyAsset {
+		forgetAllAccess(tx)
+		return nil
+	}
+	invalidateAccess(tx, append(stale, grants...))
+	return nil
+}
+
+// projectAccess computes the effective access rows in scope from ownership
+// and shares. A note is reached by owning it, a share on it, owning its
+// folder, which grants write, or a share on its folder.
+func projectAccess(db *gorm.DB, scope accessScope) ([]models.EffectiveAccess, error) {
+	within := func(query *gorm.DB, assetColumn string, assetIDs []uint, userColumn string) *gorm.DB {
+		if !scope.everyAsset {
+			query = query.Where(assetColumn+" IN ?", assetIDs)
+		}
+		if len(scope.userIDs) > 0 {
+			query = query.Where(userColumn+" IN ?", scope.userIDs)
+		}
+		return query
+	}
+	shareSource := "CASE WHEN %s.granted_via_team_id IS NULL THEN 'share' ELSE 'team_share' END AS source"
+
+	var queries []*gorm.DB
+	if scope.everyAsset || len(scope.folderIDs) > 0 {
+		queries = append(queries,
+			within(db.Table("folders").
+				Select("owner_id AS user_id, 'folder' AS asset_type, folder_id AS asset_id, 'owner' AS source, 'owner' AS level").
+				Where("deleted_at IS NULL"),
+				"folder_id", scope.folderIDs, "owner_id"),
+			within(db.Table("folder_shares").
+				Select("folder_shares.user_id, 'folder' AS asset_type, folder_shares.folder_id AS asset_id, "+
+					fmt.Sprintf(shareSource, "folder_shares")+", folder_shares.access AS level").
+				Joins("JOIN folders ON folders.folder_id = folder_shares.folder_id AND folders.deleted_at IS NULL"),
+				"folder_shares.folder_id", scope.folderIDs, "folder_shares.user_id"),
+		)
+	}
+	if scope.everyAsset || len(scope.noteIDs) > 0 {
+		queries = append(queries,
+			within(db.Table("notes").
+				Select("owner_id AS user_id, 'note' AS asset_type, note_id AS asset_id, 'owner' AS source, 'owner' AS level").
+				Where("deleted_at IS NULL"),
+				"note_id", scope.noteIDs, "owner_id"),
+			within(db.Table("note_shares").
+				Select("note_shares.user_id, 'note' AS asset_type, note_shares.note_id AS asset_id, "+
+					fmt.Sprintf(shareSource, "note_shares")+", note_shares.access AS level").
+				Joins("JOIN notes ON notes.note_id = note_shares.note_id AND notes.deleted_at IS NULL"),
+				"note_shares.note_id", scope.noteIDs, "note_shares.user_id"),
+			within(db.Table("notes").
+				Select("folders.owner_id AS user_id, 'note' AS asset_type, notes.note_id AS asset_id, 'folder_owner' AS source, 'write' AS level").
+				Joins("JOIN folders ON folders.folder_id = notes.folder_id AND folders.deleted_at IS NULL").
+				Where("notes.deleted_at IS NULL"),
+				"notes.note_id", scope.noteIDs, "folders.owner_id"),
+			within(db.Table("notes").
+				Select("folder_shares.user_id, 'note' AS asset_type, notes.note_id AS asset_id, 'folder_share' AS source, folder_shares.access AS level").
+				Joins("JOIN folders ON folders.folder_id = notes.folder_id AND folders.deleted_at IS NULL").
+				Joins("JOIN folder_shares ON folder_shares.folder_id = notes.folder_id").
+				Where("notes.deleted_at IS NULL"),
+				"notes.note_id", scope.noteIDs, "folder_shares.user_id"),
+		)
+	}
+
+	// A user reaches an asset once per source, with the strongest access
+	// that source grants
+	type grantKey struct {
+		userID    uint
+		assetType string
+		assetID   uint
+		source    string
+	}
+	index := make(map[grantKey]int)
+	grants := []models.EffectiveAccess{}
+	for _, query := range queries {
+		var rows []models.EffectiveAccess
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			key := grantKey{row.UserID, row.AssetType, row.AssetID, row.Source}
+			if i, seen := index[key]; seen {
+				grants[i].Level = strongerAccess(grants[i].Level, row.Level)
+				continue
+			}
+			index[key] = len(grants)
+			grants = append(grants, row)
+		}
+	}
+	return grants, nil
+}
+
+// RebuildEffectiveAccess regenerates the whole effective access table from
+// ownership and shares and returns the number of rows written
+func RebuildEffectiveAccess(db *gorm.DB) (int64, error) {
+	var count int64
+	err := runTransaction(db, func(tx *gorm.DB) error {
+		if err := refreshAccess(tx, accessScope{everyAsset: true}); err != nil {
+			return err
+		}
+		return tx.Model(&models.EffectiveAccess{}).Count(&count).Error
+	})
+	return count, err
+}
+
+// EnsureEffectiveAccess builds the effective access table when it is empty,
+// as it is the first time the server starts with it
+func EnsureEffectiveAccess(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.EffectiveAccess{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := RebuildEffectiveAccess(db)
+	return err
+}
+
+// VerifyEffectiveAccess compares the effective access table with what
+// ownership and shares grant and returns every difference: rows that are
+// missing, rows that grant access nothing backs, and rows with the wrong
+// level
+func VerifyEffectiveAccess(db *gorm.DB) ([]AccessDrift, error) {
+	expected, err := projectAccess(db, accessScope{everyAsset: true})
+	if err != nil {
+		return nil, err
+	}
+	var stored []models.EffectiveAccess
+	if err := db.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	key := func(grant models.EffectiveAccess) string {
+		return fmt.Sprintf("%d/%s/%d/%s", grant.UserID, grant.AssetType, grant.AssetID, grant.Source)
+	}
+	storedLevels := make(map[string]string, len(stored))
+	for _, grant := range stored {
+		storedLevels[key(grant)] = grant.Level
+	}
+
+	drift := []AccessDrift{}
+	for _, grant := range expected {
+		level, ok := storedLevels[key(grant)]
+		switch {
+		case !ok:
+			drift = append(drift, AccessDrift{Grant: grant, Problem: "missing"})
+		case level != grant.Level:
+			drift = append(drift, AccessDrift{Grant: grant, Problem: "level", Stored: level})
+		}
+		delete(storedLevels, key(grant))
+	}
+	for _, grant := range stored {
+		if _, extra := storedLevels[key(grant)]; extra {
+			drift = append(drift, AccessDrift{Grant: grant, Problem: "stale", Stored: grant.Level})
+		}
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		a, b := drift[i].Grant, drift[j].Grant
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.AssetType != b.AssetType {
+			return a.AssetType < b.AssetType
+		}
+		return a.AssetID < b.AssetID
+	})
+	return drift, nil
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+// requireNoDrift fails the test when the effective access table disagrees
+// with ownership and shares
+func requireNoDrift(t *testing.T, step string) {
+	t.Helper()
+	drift, err := VerifyEffectiveAccess(config.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) > 0 {
+		t.Fatalf("after %s the effective access table drifted: %+v", step, drift)
+	}
+}
+
+func TestEffectiveAccessStaysInSync(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testOwner, testReader)
+	docs := createTestFolder(t, testOwner, "docs")
+	archive := createTestFolder(t, testOwner, "archive")
+	note := createTestNote(t, docs, testOwner, "plan")
+	other := createTestNote(t, docs, testOwner, "notes")
+	requireNoDrift(t, "creating folders and notes")
+
+	router := testRouter()
+	router.Use(middleware.RequireAuth())
+	router.POST("/folders/:folderId/share", ShareFolder)
+	router.POST("/notes/:noteId/share", ShareNote)
+	router.DELETE("/notes/:noteId/share/:userId", RevokeNoteShare)
+	router.POST("/bulk/notes/move", BulkMoveNotes)
+	router.POST("/folders/:folderId/transfer", TransferFolder)
+	router.DELETE("/notes/:noteId", DeleteNote)
+	router.DELETE("/teams/:teamId/members/:memberId", RemoveMemberFromTeam)
+
+	steps := []struct {
+		name   string
+		userID uint
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"sharing a folder", testOwner, http.MethodPost, fmt.Sprintf("/folders/%d/share", docs.FolderID),
+			gin.H{"userId": testReader, "access": AccessRead}},
+		{"sharing a note", testOwner, http.MethodPost, fmt.Sprintf("/notes/%d/share", note.NoteID),
+			gin.H{"userId": testManager, "access": AccessWrite}},
+		{"revoking a note share", testOwner, http.MethodDelete, fmt.Sprintf("/notes/%d/share/%d", note.NoteID, testManager), nil},
+		{"moving a note", testOwner, http.MethodPost, "/bulk/notes/move",
+			gin.H{"ids": []uint{note.NoteID}, "folderId": archive.FolderID}},
+		{"transferring a folder", testOwner, http.MethodPost, fmt.Sprintf("/folders/%d/transfer", archive.FolderID),
+			gin.H{"newOwnerId": testReader, "keepAccess": true}},
+		{"deleting a note", testOwner, http.MethodDelete, fmt.Sprintf("/notes/%d", other.NoteID), nil},
+	}
+	for _, step := range steps {
+		status, response := testRequest(t, router, step.userID, step.method, step.path, step.body)
+		if status >= 300 {
+			t.Fatalf("%s returned %d: %v", step.name, status, response)
+		}
+		requireNoDrift(t, step.name)
+	}
+
+	shareViaTeam(t, docs, testReader, team)
+	path := fmt.Sprintf("/teams/%d/members/%d?revokeShares=true", team.TeamID, testReader)
+	if status, response := testRequest(t, router, testManager, http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Fatalf("removing a team member returned %d: %v", status, response)
+	}
+	requireNoDrift(t, "removing a team member")
+}
+
+func TestRebuildEffectiveAccessRepairsDriftAndClearsTheCache(t *testing.T) {
+	setupTestDB(t)
+	useTestCache(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	createTestNote(t, folder, testOwner, "plan")
+
+	// Lose a grant and invent another
+	config.DB.Where("user_id = ? AND asset_type = ?", testOwner, models.AssetTypeFolder).Delete(&models.EffectiveAccess{})
+	config.DB.Create(&models.EffectiveAccess{UserID: testReader, AssetType: models.AssetTypeFolder, AssetID: folder.FolderID, Source: "share", Level: AccessWrite})
+	drift, err := VerifyEffectiveAccess(config.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := map[string]bool{}
+	for _, d := range drift {
+		problems[d.Problem] = true
+	}
+	if len(drift) != 2 || !problems["missing"] || !problems["stale"] {
+		t.Fatalf("drift = %+v, want one missing and one stale grant", drift)
+	}
+
+	ctx := context.Background()
+	config.Cache.Set(ctx, accessCacheKey(testReader, models.AssetTypeFolder, folder.FolderID), []byte(AccessWrite), time.Minute)
+	config.Cache.Set(ctx, assetsCacheKey(testReader), []byte("{}"), time.Minute)
+	if _, err := RebuildEffectiveAccess(config.DB); err != nil {
+		t.Fatal(err)
+	}
+	requireNoDrift(t, "rebuilding")
+	if cachedKey(accessCacheKey(testReader, models.AssetTypeFolder, folder.FolderID)) || cachedKey(assetsCacheKey(testReader)) {
+		t.Fatal("a full rebuild left cached access and listings behind")
+	}
+}
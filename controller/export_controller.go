@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/archive"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/jobs"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+// exportAsyncThreshold is the number of notes above which an export runs as
+// a background job instead of streaming in the response
+const exportAsyncThreshold = 200
+
+// ExportFolder exports the notes of a folder the caller can read as a ZIP of
+// Markdown files. Large exports, or any export with ?async=true, run as a
+// background job with a download link.
+func ExportFolder(c *gin.Context) {
+	folderIDStr := c.Param("folderId")
+	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+
+	var folder models.Folder
+	if err := config.DB.Preload("Owner").First(&folder, folderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	notes, err := readableNotesInFolder(user.UserID, folder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notes"})
+		return
+	}
+
+	if len(notes) == 0 && !canRead(folderAccessFor(user.UserID, folder)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this folder"})
+		return
+	}
+
+	exportFolder := archive.ExportFolder{
+		FolderID: folder.FolderID,
+		Name:     folder.Name,
+		Owner:    folder.Owner.Username,
+	}
+	exportNotes := toExportNotes(notes)
+	fileName := fmt.Sprintf("folder-%d.zip", folder.FolderID)
+
+	if c.Query("async") == "true" || len(notes) > exportAsyncThreshold {
+		job := backgroundJobs.Start("folder-export", user.UserID, func() (jobs.Output, error) {
+			f, err := os.CreateTemp("", "folder-export-*.zip")
+			if err != nil {
+				return jobs.Output{}, err
+			}
+			defer f.Close()
+
+			if err := archive.WriteFolderZip(f, exportFolder, user.Username, exportNotes); err != nil {
+				os.Remove(f.Name())
+				return jobs.Output{}, err
+			}
+			return jobs.Output{
+				FilePath: f.Name(),
+				FileName: fileName,
+				Result:   gin.H{"notes": len(exportNotes)},
+			}, nil
+		})
+
+		c.JSON(http.StatusAccepted, jobResponse(job))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	if err := archive.WriteFolderZip(c.Writer, exportFolder, user.Username, exportNotes); err != nil {
+		// Headers are already sent, so all we can do is cut the stream short
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// toExportNotes converts notes with their owners loaded for an export archive
+func toExportNotes(notes []models.Note) []archive.ExportNote {
+	noteIDs := make([]uint, 0, len(notes))
+	for _, note := range notes {
+		noteIDs = append(noteIDs, note.NoteID)
+	}
+	tags := loadNoteTags(noteIDs)
+
+	exportNotes := make([]archive.ExportNote, 0, len(notes))
+	for _, note := range notes {
+		exportNotes = append(exportNotes, archive.ExportNote{
+			NoteID:    note.NoteID,
+			Title:     note.Title,
+			Body:      note.Body,
+			Owner:     note.Owner.Username,
+			Tags:      tags[note.NoteID],
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		})
+	}
+	return exportNotes
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
 )
 
 // CreateFolderRequest represents the request structure for creating a folder
@@ -20,19 +22,29 @@ type UpdateFolderRequest struct {
 	Name string `json:"name" binding:"required"`
 }
 
+// FolderPatch is the document a folder PATCH applies to
+type FolderPatch struct {
+	Name string `json:"name" binding:"required"`
+}
+
 // ShareFolderRequest represents the request for sharing a folder
 type ShareFolderRequest struct {
 	UserID uint   `json:"userId" binding:"required"`
 	Access string `json:"access" binding:"required,oneof=read write"`
+	TeamID *uint  `json:"teamId"` // share on behalf of a team the user is in
 }
 
-// CreateFolder creates a new folder
+// CreateFolder creates a new folder. Only admins can create folders owned
+// by another user.
 func CreateFolder(c *gin.Context) {
 	var req CreateFolderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !allowOwnerID(c, req.OwnerID) {
+		return
+	}
 
 	// Check if owner exists
 	var owner models.User
@@ -47,7 +59,13 @@ func CreateFolder(c *gin.Context) {
 		OwnerID: req.OwnerID,
 	}
 
-	if err := config.DB.Create(&folder).Error; err != nil {
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(&folder).Error; err != nil {
+			return err
+		}
+		return refreshFolderAccess(tx, folder.FolderID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
 		return
 	}
@@ -61,32 +79,26 @@ func CreateFolder(c *gin.Context) {
 	})
 }
 
-// GetFolder retrieves folder details
+// GetFolder retrieves the details of a folder the caller can read
 func GetFolder(c *gin.Context) {
-	folderIDStr := c.Param("folderId")
-	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+	folder, access, ok := findAccessibleFolder(c, canRead)
+	if !ok {
 		return
 	}
+	config.DB.Preload("Owner").First(&folder, folder.FolderID)
 
-	var folder models.Folder
-	if err := config.DB.Preload("Owner").First(&folder, folderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
-		return
-	}
+	// Readers get the folder on their recently viewed list
+	recordView(c, models.AssetTypeFolder, folder.FolderID, access)
 
 	c.JSON(http.StatusOK, gin.H{
 		"folder": folder,
 	})
 }
 
-// UpdateFolder updates folder information
+// UpdateFolder updates a folder the caller can write to
 func UpdateFolder(c *gin.Context) {
-	folderIDStr := c.Param("folderId")
-	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+	folder, _, ok := findAccessibleFolder(c, canWrite)
+	if !ok {
 		return
 	}
 
@@ -96,29 +108,72 @@ func UpdateFolder(c *gin.Context) {
 		return
 	}
 
+	// Update folder
+	folder.Name = req.Name
+	if err := config.DB.Save(&folder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+		return
+	}
+	invalidateListings(config.DB, models.AssetTypeFolder, folder.FolderID)
+
+	// Load updated folder with owner
+	config.DB.Preload("Owner").First(&folder, folder.FolderID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Folder updated successfully",
+		"folder":  folder,
+	})
+}
+
+// PatchFolder changes only the fields named in a JSON Merge Patch or JSON
+// Patch and reports which fields changed
+func PatchFolder(c *gin.Context) {
+	folderID, err := strconv.ParseUint(c.Param("folderId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
 	var folder models.Folder
 	if err := config.DB.First(&folder, folderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 		return
 	}
 
-	// Update folder
-	folder.Name = req.Name
-	if err := config.DB.Save(&folder).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+	user, _ := middleware.CurrentUser(c)
+	if !canWrite(folderAccessFor(user.UserID, folder)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have write access to this folder"})
 		return
 	}
 
+	var patched FolderPatch
+	if err := applyPatch(c, FolderPatch{Name: folder.Name}, &patched); err != nil {
+		respondActionError(c, err)
+		return
+	}
+
+	changed := []string{}
+	if patched.Name != folder.Name {
+		if err := config.DB.Model(&folder).Update("name", patched.Name).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+			return
+		}
+		invalidateListings(config.DB, models.AssetTypeFolder, folder.FolderID)
+		changed = append(changed, "name")
+	}
+
 	// Load updated folder with owner
 	config.DB.Preload("Owner").First(&folder, folder.FolderID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Folder updated successfully",
 		"folder":  folder,
+		"changed": changed,
 	})
 }
 
-// DeleteFolder deletes a folder and all its notes
+// DeleteFolder deletes a folder and all its notes. The caller must own the
+// folder.
 func DeleteFolder(c *gin.Context) {
 	folderIDStr := c.Param("folderId")
 	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
@@ -128,50 +183,30 @@ func DeleteFolder(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := beginTransaction()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	var folder models.Folder
-	if err := tx.First(&folder, folderID).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
-		return
-	}
-
-	// Delete all note shares for notes in this folder
-	if err := tx.Where("note_id IN (SELECT note_id FROM notes WHERE folder_id = ?)", folderID).Delete(&models.NoteShare{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note shares"})
-		return
-	}
-
-	// Delete all notes in the folder
-	if err := tx.Where("folder_id = ?", folderID).Delete(&models.Note{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notes"})
-		return
-	}
-
-	// Delete folder shares
-	if err := tx.Where("folder_id = ?", folderID).Delete(&models.FolderShare{}).Error; err != nil {
+	user, _ := middleware.CurrentUser(c)
+	folder, err := requireFolderOwner(tx, user.UserID, uint(folderID))
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder shares"})
+		respondActionError(c, err)
 		return
 	}
 
-	// Delete the folder
-	if err := tx.Delete(&folder).Error; err != nil {
+	// Delete the folder with its shares and every note inside it
+	if err := deleteFolderTree(tx, folder); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		respondActionError(c, err)
 		return
 	}
 
 	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
+	if err := commitTransaction(tx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
@@ -179,7 +214,7 @@ func DeleteFolder(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Folder and its contents deleted successfully"})
 }
 
-// ShareFolder shares a folder with a user
+// ShareFolder shares a folder with a user. The caller must own the folder.
 func ShareFolder(c *gin.Context) {
 	folderIDStr := c.Param("folderId")
 	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
@@ -194,59 +229,47 @@ func ShareFolder(c *gin.Context) {
 		return
 	}
 
-	// Check if folder exists
-	var folder models.Folder
-	if err := config.DB.First(&folder, folderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	user, _ := middleware.CurrentUser(c)
+	if _, err := requireFolderOwner(config.DB, user.UserID, uint(folderID)); err != nil {
+		respondActionError(c, err)
 		return
 	}
-
-	// Check if user exists
-	var user models.User
-	if err := config.DB.First(&user, req.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !allowShareTeam(c, req.TeamID, []uint{req.UserID}) {
 		return
 	}
 
-	// Check if folder is already shared with this user
-	var existingShare models.FolderShare
-	if err := config.DB.Where("folder_id = ? AND user_id = ?", folderID, req.UserID).First(&existingShare).Error; err == nil {
-		// Update existing share
-		existingShare.Access = req.Access
-		if err := config.DB.Save(&existingShare).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder share"})
-			return
-		}
-		config.DB.Preload("User").Preload("Folder").First(&existingShare, existingShare.ID)
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Folder share updated successfully",
-			"share":   existingShare,
-		})
+	// Create the share, or update the access of an existing one
+	var folderShare models.FolderShare
+	var created bool
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		var err error
+		folderShare, created, err = shareFolderWith(tx, uint(folderID), req.UserID, req.Access, req.TeamID)
+		return err
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
-	// Create new share
-	folderShare := models.FolderShare{
-		FolderID: uint(folderID),
-		UserID:   req.UserID,
-		Access:   req.Access,
-	}
+	// Load share with relationships
+	config.DB.Preload("User").Preload("Folder").First(&folderShare, folderShare.ID)
 
-	if err := config.DB.Create(&folderShare).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share folder"})
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Folder share updated successfully",
+			"share":   folderShare,
+		})
 		return
 	}
 
-	// Load share with relationships
-	config.DB.Preload("User").Preload("Folder").First(&folderShare, folderShare.ID)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Folder shared successfully",
 		"share":   folderShare,
 	})
 }
 
-// RevokeFolderShare revokes folder sharing for a user
+// RevokeFolderShare revokes folder sharing for a user. The caller must own
+// the folder.
 func RevokeFolderShare(c *gin.Context) {
 	folderIDStr := c.Param("folderId")
 	userIDStr := c.Param("userId")
@@ -263,17 +286,23 @@ func RevokeFolderShare(c *gin.Context) {
 		return
 	}
 
-	// Find and delete the folder share
-	var folderShare models.FolderShare
-	if err := config.DB.Where("folder_id = ? AND user_id = ?", folderID, userID).First(&folderShare).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder share not found"})
+	user, _ := middleware.CurrentUser(c)
+	if _, err := requireFolderOwner(config.DB, user.UserID, uint(folderID)); err != nil {
+		respondActionError(c, err)
 		return
 	}
 
-	if err := config.DB.Delete(&folderShare).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke folder share"})
+	// Find and delete the folder share
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return revokeFolderShareFrom(tx, uint(folderID), uint(userID))
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
+	// The user's bookmarks, recent views and notifications go with their access
+	forgetLostAssets(uint(userID))
+
 	c.JSON(http.StatusOK, gin.H{"message": "Folder share revoked successfully"})
 }
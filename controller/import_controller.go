@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/archive"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/jobs"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+const (
+	// maxImportSize caps the size of an uploaded archive
+	maxImportSize = 50 << 20
+
+	// importAsyncThreshold is the number of notes above which an import runs
+	// as a background job instead of inside the request
+	importAsyncThreshold = 200
+)
+
+// ImportResult reports the folder created by an import and what happened to
+// each entry of the archive
+type ImportResult struct {
+	Folder     models.Folder        `json:"folder"`
+	Imported   int                  `json:"imported"`
+	Skipped    int                  `json:"skipped"`
+	Duplicates int                  `json:"duplicates"`
+	Items      []archive.ItemReport `json:"items"`
+}
+
+// ImportFolder creates a folder for the caller from an uploaded ZIP of
+// Markdown files or an Evernote .enex export
+func ImportFolder(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An archive must be uploaded in the file field"})
+		return
+	}
+	if fileHeader.Size > maxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Archive is too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read archive"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read archive"})
+		return
+	}
+
+	var notes []archive.ImportedNote
+	var skips []archive.ItemReport
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	switch ext {
+	case ".zip":
+		notes, skips, err = archive.ReadMarkdownZip(data)
+	case ".enex":
+		notes, skips, err = archive.ReadENEX(bytes.NewReader(data))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported archive type, expected .zip or .enex"})
+		return
+	}
+	if errors.Is(err, archive.ErrTooManyEntries) || errors.Is(err, archive.ErrArchiveTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folderName := strings.TrimSpace(c.PostForm("folderName"))
+	if folderName == "" {
+		folderName = strings.TrimSuffix(filepath.Base(fileHeader.Filename), ext)
+	}
+
+	if c.PostForm("async") == "true" || len(notes) > importAsyncThreshold {
+		job := backgroundJobs.Start("folder-import", user.UserID, func() (jobs.Output, error) {
+			result, err := importNotes(user.UserID, folderName, notes, skips)
+			return jobs.Output{Result: result}, err
+		})
+
+		c.JSON(http.StatusAccepted, jobResponse(job))
+		return
+	}
+
+	result, err := importNotes(user.UserID, folderName, notes, skips)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import notes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Notes imported successfully",
+		"import":  result,
+	})
+}
+
+// importNotes stores the imported notes in a new folder owned by the user.
+// Titles that repeat within the import are flagged as duplicates.
+func importNotes(ownerID uint, folderName string, notes []archive.ImportedNote, skips []archive.ItemReport) (ImportResult, error) {
+	tx := beginTransaction()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	folder := models.Folder{
+		Name:    folderName,
+		OwnerID: ownerID,
+	}
+	if err := tx.Create(&folder).Error; err != nil {
+		tx.Rollback()
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{Items: make([]archive.ItemReport, 0, len(notes)+len(skips))}
+	seenTitles := make(map[string]bool)
+	for _, imported := range notes {
+		note := models.Note{
+			Title:     imported.Title,
+			Body:      imported.Body,
+			FolderID:  folder.FolderID,
+			OwnerID:   ownerID,
+			CreatedAt: imported.CreatedAt,
+			UpdatedAt: imported.UpdatedAt,
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			tx.Rollback()
+			return ImportResult{}, err
+		}
+		if err := applyNoteTags(tx, ownerID, note.NoteID, imported.Tags); err != nil {
+			tx.Rollback()
+			return ImportResult{}, err
+		}
+
+		key := strings.ToLower(imported.Title)
+		item := archive.ItemReport{
+			Source:    imported.Source,
+			Title:     imported.Title,
+			Status:    archive.ItemImported,
+			NoteID:    note.NoteID,
+			Duplicate: seenTitles[key],
+		}
+		if item.Duplicate {
+			item.Reason = "another note in this import has the same title"
+			result.Duplicates++
+		}
+		seenTitles[key] = true
+		result.Imported++
+		result.Items = append(result.Items, item)
+	}
+
+	result.Skipped = len(skips)
+	result.Items = append(result.Items, skips...)
+
+	// The owner reaches the folder and every imported note
+	if err := refreshFolderAccess(tx, folder.FolderID); err != nil {
+		tx.Rollback()
+		return ImportResult{}, err
+	}
+
+	if err := commitTransaction(tx); err != nil {
+		return ImportResult{}, err
+	}
+
+	config.DB.Preload("Owner").First(&folder, folder.FolderID)
+	result.Folder = folder
+	return result, nil
+}
@@ -0,0 +1,435 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultInvitationTTL is how long an invitation stays open by default
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	// maxInvitationHours caps how long an invitation can stay open
+	maxInvitationHours = 30 * 24
+)
+
+// InviteRequest represents a request to invite a user or an email address to
+// a team. Exactly one of UserID and Email is set.
+type InviteRequest struct {
+	UserID         uint   `json:"userId" binding:"required_without=Email,excluded_with=Email"`
+	Email          string `json:"email" binding:"required_without=UserID,omitempty,email"`
+	Role           string `json:"role" binding:"omitempty,oneof=member manager"`
+	ExpiresInHours int    `json:"expiresInHours" binding:"omitempty,min=1"`
+}
+
+// InviteToTeam invites a user, or an email address that has no account yet,
+// to join a team. Invitations for an email are turned into membership when
+// that email signs up.
+func InviteToTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
+		return
+	}
+
+	var req InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.TeamRoleMember
+	}
+	if req.ExpiresInHours > maxInvitationHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitations can stay open for at most 30 days"})
+		return
+	}
+	ttl := defaultInvitationTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	// An email that already has an account invites that user
+	var invitee models.User
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if req.UserID != 0 {
+		if err := config.DB.First(&invitee, req.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+	} else {
+		config.DB.Where("LOWER(email) = ?", email).First(&invitee)
+	}
+
+	if invitee.UserID != 0 && hasTeamRole(config.DB, team.TeamID, invitee.UserID, models.TeamRoleManager) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a manager of this team", "reason": TeamAlreadyManager})
+		return
+	}
+	if invitee.UserID != 0 && req.Role == models.TeamRoleMember && hasTeamRole(config.DB, team.TeamID, invitee.UserID, models.TeamRoleMember) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this team", "reason": TeamAlreadyMember})
+		return
+	}
+
+	// One open invitation per invitee and role
+	pending := config.DB.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND role = ? AND status = ? AND expires_at > ?", team.TeamID, req.Role, models.InvitationPending, time.Now())
+	if invitee.UserID != 0 {
+		pending = pending.Where("user_id = ?", invitee.UserID)
+	} else {
+		pending = pending.Where("email = ?", email)
+	}
+	var open int64
+	pending.Count(&open)
+	if open > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An invitation is already pending"})
+		return
+	}
+
+	caller, _ := middleware.CurrentUser(c)
+	invitation := models.TeamInvitation{
+		TeamID:      team.TeamID,
+		Role:        req.Role,
+		InvitedByID: caller.UserID,
+		Status:      models.InvitationPending,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if invitee.UserID != 0 {
+		invitation.UserID = &invitee.UserID
+		invitation.Email = strings.ToLower(invitee.Email)
+	} else {
+		invitation.Email = email
+	}
+
+	if err := config.DB.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	config.DB.Preload("Team").Preload("User").Preload("InvitedBy").First(&invitation, invitation.InvitationID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation sent successfully",
+		"invitation": invitation,
+	})
+}
+
+// inviteUsers invites users with accounts to take a role in a team, skipping
+// those who already have an open invitation for it
+func inviteUsers(tx *gorm.DB, team models.Team, role string, invitedByID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var pending []uint
+	err := tx.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND role = ? AND status = ? AND expires_at > ? AND user_id IN ?",
+			team.TeamID, role, models.InvitationPending, time.Now(), userIDs).
+		Pluck("user_id", &pending).Error
+	if err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to get invitations")
+	}
+	open := make(map[uint]bool, len(pending))
+	for _, userID := range pending {
+		open[userID] = true
+	}
+
+	var invitees []models.User
+	if err := tx.Where("user_id IN ?", userIDs).Find(&invitees).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to get users")
+	}
+	for _, invitee := range invitees {
+		if open[invitee.UserID] {
+			continue
+		}
+		invitation := models.TeamInvitation{
+			TeamID:      team.TeamID,
+			Role:        role,
+			UserID:      &invitee.UserID,
+			Email:       strings.ToLower(invitee.Email),
+			InvitedByID: invitedByID,
+			Status:      models.InvitationPending,
+			ExpiresAt:   time.Now().Add(defaultInvitationTTL),
+		}
+		if err := tx.Create(&invitation).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to create invitation")
+		}
+	}
+	return nil
+}
+
+// ListTeamInvitations lists a team's invitations. ?status= filters by state
+// and defaults to pending.
+func ListTeamInvitations(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", models.InvitationPending)
+	query, ok := filterInvitations(c, config.DB.Where("team_id = ?", team.TeamID), status)
+	if !ok {
+		return
+	}
+
+	var invitations []models.TeamInvitation
+	if err := query.Preload("User").Preload("InvitedBy").Order("created_at DESC").Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": withEffectiveStatus(invitations)})
+}
+
+// CancelInvitation withdraws a pending invitation
+func CancelInvitation(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseUint(c.Param("invitationId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	var invitation models.TeamInvitation
+	if err := config.DB.Where("team_id = ?", team.TeamID).First(&invitation, invitationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if status := effectiveStatus(invitation); status != models.InvitationPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invitation is already " + status})
+		return
+	}
+
+	if err := respondToInvitation(config.DB, &invitation, models.InvitationCancelled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invitation cancelled successfully",
+		"invitation": invitation,
+	})
+}
+
+// ListMyInvitations lists the caller's pending invitations
+func ListMyInvitations(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	// Invitations sent to the caller's email before they signed up
+	claimEmailInvitations(user)
+
+	var invitations []models.TeamInvitation
+	err := config.DB.Preload("Team").Preload("InvitedBy").
+		Where("user_id = ? AND status = ? AND expires_at > ?", user.UserID, models.InvitationPending, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// AcceptInvitation accepts one of the caller's invitations and joins the team
+func AcceptInvitation(c *gin.Context) {
+	invitation, ok := findMyPendingInvitation(c)
+	if !ok {
+		return
+	}
+
+	var team models.Team
+	if err := config.DB.First(&team, invitation.TeamID).Error; err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Team no longer exists"})
+		return
+	}
+	if rejectArchivedTeam(c, team) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Users who already hold the role just close the invitation
+		if err := addTeamRole(tx, team, *invitation.UserID, invitation.Role); err != nil && actionStatus(err) != http.StatusConflict {
+			return err
+		}
+		if err := respondToInvitation(tx, &invitation, models.InvitationAccepted); err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to accept invitation")
+		}
+		return nil
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invitation accepted successfully",
+		"invitation": invitation,
+	})
+}
+
+// DeclineInvitation declines one of the caller's invitations
+func DeclineInvitation(c *gin.Context) {
+	invitation, ok := findMyPendingInvitation(c)
+	if !ok {
+		return
+	}
+
+	if err := respondToInvitation(config.DB, &invitation, models.InvitationDeclined); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invitation declined successfully",
+		"invitation": invitation,
+	})
+}
+
+// StartInvitationSweeper periodically expires overdue invitations and turns
+// invitations for emails that have since signed up into membership. Accounts
+// are created by the user service, so new sign-ups are picked up here.
+func StartInvitationSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			sweepInvitations()
+			<-ticker.C
+		}
+	}()
+}
+
+// sweepInvitations runs one pass of the invitation sweeper
+func sweepInvitations() {
+	config.DB.Model(&models.TeamInvitation{}).
+		Where("status = ? AND expires_at <= ?", models.InvitationPending, time.Now()).
+		Update("status", models.InvitationExpired)
+
+	var users []models.User
+	config.DB.Where("LOWER(email) IN (?)", config.DB.Model(&models.TeamInvitation{}).
+		Select("email").
+		Where("user_id IS NULL AND status = ?", models.InvitationPending)).
+		Find(&users)
+	for _, user := range users {
+		claimEmailInvitations(user)
+	}
+}
+
+// claimEmailInvitations turns the pending invitations sent to a user's email
+// before they had an account into membership. Signing up with the invited
+// address counts as accepting.
+func claimEmailInvitations(user models.User) {
+	var invitations []models.TeamInvitation
+	config.DB.Where("user_id IS NULL AND email = ? AND status = ? AND expires_at > ?",
+		strings.ToLower(user.Email), models.InvitationPending, time.Now()).Find(&invitations)
+
+	for _, invitation := range invitations {
+		invitation.UserID = &user.UserID
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&invitation).Update("user_id", user.UserID).Error; err != nil {
+				return err
+			}
+
+			// Invitations to archived teams wait for the invitee to accept
+			var team models.Team
+			if err := tx.First(&team, invitation.TeamID).Error; err != nil || team.ArchivedAt != nil {
+				return nil
+			}
+
+			err := addTeamRole(tx, team, user.UserID, invitation.Role)
+			if actionReason(err) == TeamMemberCapReached {
+				// Full teams leave the invitation for the invitee to accept later
+				return nil
+			}
+			if err != nil && actionStatus(err) != http.StatusConflict {
+				return err
+			}
+			return respondToInvitation(tx, &invitation, models.InvitationAccepted)
+		})
+		if err != nil {
+			log.Printf("invitations: claiming invitation %d for user %d: %v", invitation.InvitationID, user.UserID, err)
+		}
+	}
+}
+
+// findMyPendingInvitation loads the caller's invitation named by the
+// invitationId parameter and checks that it is still open. It writes the
+// error response and returns false otherwise.
+func findMyPendingInvitation(c *gin.Context) (models.TeamInvitation, bool) {
+	invitationID, err := strconv.ParseUint(c.Param("invitationId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return models.TeamInvitation{}, false
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	claimEmailInvitations(user)
+
+	var invitation models.TeamInvitation
+	if err := config.DB.Where("user_id = ?", user.UserID).First(&invitation, invitationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return invitation, false
+	}
+	if status := effectiveStatus(invitation); status != models.InvitationPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invitation is already " + status})
+		return invitation, false
+	}
+	return invitation, true
+}
+
+// respondToInvitation closes an invitation with the given status
+func respondToInvitation(db *gorm.DB, invitation *models.TeamInvitation, status string) error {
+	now := time.Now()
+	if err := db.Model(invitation).Updates(map[string]interface{}{"status": status, "responded_at": now}).Error; err != nil {
+		return err
+	}
+	invitation.Status = status
+	invitation.RespondedAt = &now
+	return nil
+}
+
+// filterInvitations narrows a query to invitations in the given state,
+// treating overdue pending invitations as expired. It writes the error
+// response and returns false for an unknown state.
+func filterInvitations(c *gin.Context, query *gorm.DB, status string) (*gorm.DB, bool) {
+	now := time.Now()
+	switch status {
+	case "all":
+		return query, true
+	case models.InvitationPending:
+		return query.Where("status = ? AND expires_at > ?", models.InvitationPending, now), true
+	case models.InvitationExpired:
+		return query.Where("status = ? OR (status = ? AND expires_at <= ?)", models.InvitationExpired, models.InvitationPending, now), true
+	case models.InvitationAccepted, models.InvitationDeclined, models.InvitationCancelled:
+		return query.Where("status = ?", status), true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, accepted, declined, cancelled, expired or all"})
+	return query, false
+}
+
+// effectiveStatus is an invitation's status, counting overdue pending
+// invitations the sweeper has not reached yet as expired
+func effectiveStatus(invitation models.TeamInvitation) string {
+	if invitation.Status == models.InvitationPending && !invitation.ExpiresAt.After(time.Now()) {
+		return models.InvitationExpired
+	}
+	return invitation.Status
+}
+
+// withEffectiveStatus reports every invitation with its effective status
+func withEffectiveStatus(invitations []models.TeamInvitation) []models.TeamInvitation {
+	for i := range invitations {
+		invitations[i].Status = effectiveStatus(invitations[i])
+	}
+	return invitations
+}
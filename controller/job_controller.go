@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/jobs"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// backgroundJobs runs long exports and imports outside the request
+var backgroundJobs = jobs.NewManager()
+
+// jobResponse adds the links a client needs to follow a job
+func jobResponse(job jobs.Job) gin.H {
+	response := gin.H{
+		"job":       job,
+		"statusUrl": "/jobs/" + job.ID,
+	}
+	if job.HasFile {
+		response["downloadUrl"] = "/jobs/" + job.ID + "/download"
+	}
+	return response
+}
+
+// findOwnJob loads a job started by the caller.
+// It writes the error response and returns false otherwise.
+func findOwnJob(c *gin.Context) (jobs.Job, bool) {
+	user, _ := middleware.CurrentUser(c)
+
+	job, ok := backgroundJobs.Get(c.Param("jobId"))
+	if !ok || job.OwnerID != user.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return job, false
+	}
+	return job, true
+}
+
+// GetJob reports the status of a background job
+func GetJob(c *gin.Context) {
+	job, ok := findOwnJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, jobResponse(job))
+}
+
+// DownloadJobFile downloads the file produced by a completed job
+func DownloadJobFile(c *gin.Context) {
+	job, ok := findOwnJob(c)
+	if !ok {
+		return
+	}
+
+	path, name, ok := job.File()
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has no file to download", "status": job.Status})
+		return
+	}
+
+	c.FileAttachment(path, name)
+}
@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// SyncTeamRolesRequest represents the full desired list of a team's members
+// or managers. Users can be named by id, by email, or both.
+type SyncTeamRolesRequest struct {
+	UserIDs []uint   `json:"userIds" binding:"omitempty,max=1000"`
+	Emails  []string `json:"emails" binding:"omitempty,max=1000,dive,email"`
+	DryRun  bool     `json:"dryRun"`
+}
+
+// errDryRun rolls back the transaction of a dry run once its checks passed
+var errDryRun = errors.New("dry run")
+
+// SyncTeamMembers replaces a team's members with the given list
+func SyncTeamMembers(c *gin.Context) {
+	syncTeamRoles(c, models.TeamRoleMember)
+}
+
+// SyncTeamManagers replaces a team's managers with the given list
+func SyncTeamManagers(c *gin.Context) {
+	syncTeamRoles(c, models.TeamRoleManager)
+}
+
+// syncTeamRoles makes the users holding a role in a team match the desired
+// list. It adds and removes users in one transaction and reports the diff;
+// a dry run checks the team rules but rolls back. Managers count as members,
+// so managers on a members list are left as they are. ?revokeShares=true
+// also revokes the team-granted shares of the users removed.
+//
+// Only admins add users directly, as with AddMemberToTeam. The users a
+// manager's list adds are invited instead and reported as "invited".
+func syncTeamRoles(c *gin.Context, role string) {
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
+		return
+	}
+
+	var req SyncTeamRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// An omitted list is a mistake, not a request to remove everyone
+	if req.UserIDs == nil && req.Emails == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userIds or emails is required; send an empty list to remove everyone"})
+		return
+	}
+
+	desired, unknownIDs, unknownEmails := resolveUsers(req.UserIDs, req.Emails)
+	if len(unknownIDs) > 0 || len(unknownEmails) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":          "Some users were not found",
+			"unknownUserIds": unknownIDs,
+			"unknownEmails":  unknownEmails,
+		})
+		return
+	}
+
+	current, err := teamRoleUserIDs(config.DB, team.TeamID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current " + role + "s"})
+		return
+	}
+
+	managing := 0
+	if role == models.TeamRoleMember {
+		desired, managing = withoutManagers(team.TeamID, desired)
+	}
+	added, removed, unchanged := diffUserIDs(current, desired)
+
+	caller, _ := middleware.CurrentUser(c)
+	var invited []uint
+	if caller.Role != models.RoleAdmin {
+		invited, added = added, nil
+	}
+
+	revokeShares := revokeSharesRequested(c)
+	var folderShares, noteShares int64
+	if len(added) > 0 || len(removed) > 0 || len(invited) > 0 {
+		override := overrideTeamRules(c)
+		err := runTransaction(config.DB, func(tx *gorm.DB) error {
+			// Removing first frees places under the member cap
+			for _, userID := range removed {
+				if err := removeTeamRole(tx, team.TeamID, userID, role); err != nil {
+					return err
+				}
+			}
+			if revokeShares {
+				var err error
+				if folderShares, noteShares, err = revokeLeaversShares(tx, team.TeamID, removed); err != nil {
+					return err
+				}
+			}
+			for _, userID := range added {
+				if err := addTeamRole(tx, team, userID, role); err != nil {
+					return err
+				}
+			}
+			if role == models.TeamRoleManager && !override {
+				if err := checkTeamManagers(tx, team.TeamID); err != nil {
+					return err
+				}
+			}
+
+			// Pending invitations for users added here are fulfilled
+			if len(added) > 0 {
+				now := time.Now()
+				err := tx.Model(&models.TeamInvitation{}).
+					Where("team_id = ? AND role = ? AND status = ? AND user_id IN ?", team.TeamID, role, models.InvitationPending, added).
+					Updates(map[string]interface{}{"status": models.InvitationAccepted, "responded_at": now}).Error
+				if err != nil {
+					return failAction(http.StatusInternalServerError, "Failed to update invitations")
+				}
+			}
+			if err := inviteUsers(tx, team, role, caller.UserID, invited); err != nil {
+				return err
+			}
+
+			if req.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if err != nil && err != errDryRun {
+			respondActionError(c, err)
+			return
+		}
+		if revokeShares && !req.DryRun {
+			forgetLeaversAssets(removed)
+		}
+	}
+
+	message := "Team " + role + "s synced successfully"
+	if req.DryRun {
+		message = "Dry run: no changes were made"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   message,
+		"teamId":    team.TeamID,
+		"role":      role,
+		"dryRun":    req.DryRun,
+		"added":     loadUsers(added),
+		"invited":   loadUsers(invited),
+		"removed":   loadUsers(removed),
+		"unchanged": len(unchanged) + managing,
+		"revoked":   gin.H{"folderShares": folderShares, "noteShares": noteShares},
+	})
+}
+
+// resolveUsers looks up users by id and by email and returns the ids of
+// everyone found, without duplicates, along with the ids and emails that
+// matched no user
+func resolveUsers(userIDs []uint, emails []string) (found, unknownIDs []uint, unknownEmails []string) {
+	found, unknownIDs, unknownEmails = []uint{}, []uint{}, []string{}
+	seen := make(map[uint]bool)
+
+	if ids := uniqueIDs(userIDs); len(ids) > 0 {
+		var users []models.User
+		config.DB.Where("user_id IN ?", ids).Find(&users)
+		exists := make(map[uint]bool, len(users))
+		for _, user := range users {
+			exists[user.UserID] = true
+		}
+		for _, id := range ids {
+			if !exists[id] {
+				unknownIDs = append(unknownIDs, id)
+			} else if !seen[id] {
+				seen[id] = true
+				found = append(found, id)
+			}
+		}
+	}
+
+	if len(emails) > 0 {
+		lowered := make([]string, len(emails))
+		for i, email := range emails {
+			lowered[i] = strings.ToLower(strings.TrimSpace(email))
+		}
+		var users []models.User
+		config.DB.Where("LOWER(email) IN ?", lowered).Find(&users)
+		byEmail := make(map[string]uint, len(users))
+		for _, user := range users {
+			byEmail[strings.ToLower(user.Email)] = user.UserID
+		}
+		for i, email := range lowered {
+			id, ok := byEmail[email]
+			if !ok {
+				unknownEmails = append(unknownEmails, emails[i])
+			} else if !seen[id] {
+				seen[id] = true
+				found = append(found, id)
+			}
+		}
+	}
+	return found, unknownIDs, unknownEmails
+}
+
+// withoutManagers drops the team's managers from a list of users and returns
+// how many were dropped
+func withoutManagers(teamID uint, userIDs []uint) ([]uint, int) {
+	var managers []uint
+	config.DB.Model(&models.TeamManager{}).Where("team_id = ?", teamID).Pluck("user_id", &managers)
+	isManager := make(map[uint]bool, len(managers))
+	for _, id := range managers {
+		isManager[id] = true
+	}
+
+	kept := []uint{}
+	for _, id := range userIDs {
+		if !isManager[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept, len(userIDs) - len(kept)
+}
+
+// teamRoleUserIDs returns the ids of the users holding a role in a team
+func teamRoleUserIDs(db *gorm.DB, teamID uint, role string) ([]uint, error) {
+	var ids []uint
+	query := db.Model(&models.TeamMember{})
+	if role == models.TeamRoleManager {
+		query = db.Model(&models.TeamManager{})
+	}
+	err := query.Where("team_id = ?", teamID).Order("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// diffUserIDs compares the current and desired users, keeping the desired
+// order for additions and the current order for removals
+func diffUserIDs(current, desired []uint) (added, removed, unchanged []uint) {
+	added, removed, unchanged = []uint{}, []uint{}, []uint{}
+
+	have := make(map[uint]bool, len(current))
+	for _, id := range current {
+		have[id] = true
+	}
+	want := make(map[uint]bool, len(desired))
+	for _, id := range desired {
+		want[id] = true
+		if have[id] {
+			unchanged = append(unchanged, id)
+		} else {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !want[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed, unchanged
+}
+
+// loadUsers loads users by id, in the given order
+func loadUsers(ids []uint) []models.User {
+	users := []models.User{}
+	if len(ids) == 0 {
+		return users
+	}
+
+	var found []models.User
+	config.DB.Where("user_id IN ?", ids).Find(&found)
+	byID := make(map[uint]models.User, len(found))
+	for _, user := range found {
+		byID[user.UserID] = user
+	}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+func TestSyncTeamMembersInvitesForManagers(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core")
+
+	router := testRouter()
+	router.PUT("/teams/:teamId/members", middleware.RequireAuth(), SyncTeamMembers)
+	path := fmt.Sprintf("/teams/%d/members", team.TeamID)
+	body := map[string]interface{}{"userIds": []uint{testReader}}
+
+	status, response := testRequest(t, router, testManager, http.MethodPut, path, body)
+	if status != http.StatusOK {
+		t.Fatalf("manager sync returned %d: %v", status, response)
+	}
+	if added, _ := response["added"].([]interface{}); len(added) != 0 {
+		t.Fatalf("manager sync added %v", added)
+	}
+	if invited, _ := response["invited"].([]interface{}); len(invited) != 1 {
+		t.Fatalf("manager sync invited %v, want the reader", response["invited"])
+	}
+	if hasTeamRole(config.DB, team.TeamID, testReader, models.TeamRoleMember) {
+		t.Fatal("manager sync made the reader a member without an invitation")
+	}
+
+	// Syncing again leaves the open invitation as it is
+	testRequest(t, router, testManager, http.MethodPut, path, body)
+	var invitations int64
+	config.DB.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND user_id = ? AND status = ?", team.TeamID, testReader, models.InvitationPending).
+		Count(&invitations)
+	if invitations != 1 {
+		t.Fatalf("found %d pending invitations, want 1", invitations)
+	}
+
+	// Admins add members directly, fulfilling the invitation
+	status, response = testRequest(t, router, testAdmin, http.MethodPut, path, body)
+	if status != http.StatusOK {
+		t.Fatalf("admin sync returned %d: %v", status, response)
+	}
+	if added, _ := response["added"].([]interface{}); len(added) != 1 {
+		t.Fatalf("admin sync added %v, want the reader", response["added"])
+	}
+	if !hasTeamRole(config.DB, team.TeamID, testReader, models.TeamRoleMember) {
+		t.Fatal("admin sync did not add the reader")
+	}
+}
@@ -3,37 +3,49 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
 )
 
 // CreateNoteRequest represents the request structure for creating a note
 type CreateNoteRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Body    string `json:"body"`
+	Format  string `json:"format" binding:"omitempty,oneof=markdown html"`
 	OwnerID uint   `json:"ownerId" binding:"required"`
 }
 
 // UpdateNoteRequest represents the request structure for updating a note
 type UpdateNoteRequest struct {
-	Title string `json:"title" binding:"required"`
-	Body  string `json:"body"`
+	Title  string `json:"title" binding:"required"`
+	Body   string `json:"body"`
+	Format string `json:"format" binding:"omitempty,oneof=markdown html"`
+}
+
+// NotePatch is the document a note PATCH applies to
+type NotePatch struct {
+	Title  string `json:"title" binding:"required"`
+	Body   string `json:"body"`
+	Format string `json:"format,omitempty" binding:"omitempty,oneof=markdown html"`
 }
 
 // ShareNoteRequest represents the request for sharing a note
 type ShareNoteRequest struct {
 	UserID uint   `json:"userId" binding:"required"`
 	Access string `json:"access" binding:"required,oneof=read write"`
+	TeamID *uint  `json:"teamId"` // share on behalf of a team the user is in
 }
 
-// CreateNote creates a new note inside a folder
+// CreateNote creates a new note inside a folder the caller can write to.
+// Only admins can create notes owned by another user.
 func CreateNote(c *gin.Context) {
-	folderIDStr := c.Param("folderId")
-	folderID, err := strconv.ParseUint(folderIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+	folder, _, ok := findAccessibleFolder(c, canWrite)
+	if !ok {
 		return
 	}
 
@@ -42,11 +54,7 @@ func CreateNote(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Check if folder exists
-	var folder models.Folder
-	if err := config.DB.First(&folder, folderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	if !allowOwnerID(c, req.OwnerID) {
 		return
 	}
 
@@ -57,54 +65,112 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
+	// HTML bodies are stored as Markdown
+	body, err := bodyToMarkdown(req.Body, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to convert HTML body"})
+		return
+	}
+
 	// Create the note
 	note := models.Note{
 		Title:    req.Title,
-		Body:     req.Body,
-		FolderID: uint(folderID),
+		Body:     body,
+		FolderID: folder.FolderID,
 		OwnerID:  req.OwnerID,
 	}
 
-	if err := config.DB.Create(&note).Error; err != nil {
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		return refreshNoteAccess(tx, note.NoteID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
 		return
 	}
 
+	// Notify users mentioned in the body
+	mentioned := syncMentions(note, models.MentionSourceNote, note.NoteID, actorOf(c), note.Body)
+
 	// Load the note with relationships
 	config.DB.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Note created successfully",
-		"note":    note,
+		"message":  "Note created successfully",
+		"note":     note,
+		"mentions": mentioned,
 	})
 }
 
-// GetNote retrieves note details
+// GetNote retrieves the details of a note the caller can read
 func GetNote(c *gin.Context) {
-	noteIDStr := c.Param("noteId")
-	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+	note, access, ok := findAccessibleNote(c, canRead)
+	if !ok {
 		return
 	}
+	config.DB.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
 
-	var note models.Note
-	if err := config.DB.Preload("Owner").Preload("Folder").First(&note, noteID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
-		return
-	}
+	// Readers get the note on their recently viewed list
+	recordView(c, models.AssetTypeNote, note.NoteID, access)
 
 	c.JSON(http.StatusOK, gin.H{
 		"note": note,
+		"tags": loadNoteTags([]uint{note.NoteID})[note.NoteID],
 	})
 }
 
-// UpdateNote updates note information
-func UpdateNote(c *gin.Context) {
-	noteIDStr := c.Param("noteId")
-	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
+// ListNotes lists the notes the caller can read. ?q= searches titles and
+// bodies and ?tags=a,b&tagMode=all|any filters by tag.
+func ListNotes(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	tags, err := parseTagFilter(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every way of reaching a note has a row in the effective access table
+	readable := accessibleAssets(config.DB, user.UserID, models.AssetTypeNote)
+	query := config.DB.Preload("Owner").Preload("Folder").Where("note_id IN (?)", readable)
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(title) LIKE ? OR LOWER(body) LIKE ?", pattern, pattern)
+	}
+	query = tags.apply(query, "note_id")
+
+	var notes []models.Note
+	if err := query.Order("updated_at DESC").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notes"})
+		return
+	}
+
+	noteIDs := make([]uint, len(notes))
+	for i, note := range notes {
+		noteIDs[i] = note.NoteID
+	}
+	access := projectedAccessFor(config.DB, user.UserID, models.AssetTypeNote, noteIDs)
+
+	notesWithAccess := make([]NoteWithAccess, 0, len(notes))
+	for _, note := range notes {
+		notesWithAccess = append(notesWithAccess, NoteWithAccess{
+			Note:       note,
+			AccessType: access[note.NoteID],
+		})
+	}
+	attachNoteTags(notesWithAccess)
+	pinnedFirst(user.UserID, notesWithAccess)
+
+	c.JSON(http.StatusOK, gin.H{"notes": notesWithAccess})
+}
+
+// UpdateNote updates a note the caller can write to
+func UpdateNote(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canWrite)
+	if !ok {
 		return
 	}
 
@@ -114,30 +180,117 @@ func UpdateNote(c *gin.Context) {
 		return
 	}
 
-	var note models.Note
-	if err := config.DB.First(&note, noteID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	// A live editing session owns the body until it ends, and its next
+	// snapshot would overwrite a full replacement
+	if collabHub.Active(note.NoteID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Note is being edited in a live session"})
+		return
+	}
+
+	// HTML bodies are stored as Markdown
+	body, err := bodyToMarkdown(req.Body, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to convert HTML body"})
 		return
 	}
 
 	// Update note
 	note.Title = req.Title
-	note.Body = req.Body
+	note.Body = body
 	if err := config.DB.Save(&note).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
 		return
 	}
+	invalidateListings(config.DB, models.AssetTypeNote, note.NoteID)
+
+	// Keep comment anchors on the text they refer to
+	reanchorComments(config.DB, note.NoteID, note.Body)
+
+	// Notify users newly mentioned in the body
+	mentioned := syncMentions(note, models.MentionSourceNote, note.NoteID, actorOf(c), note.Body)
+
+	// Load updated note with relationships
+	config.DB.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Note updated successfully",
+		"note":     note,
+		"mentions": mentioned,
+	})
+}
+
+// PatchNote changes only the fields named in a JSON Merge Patch or JSON
+// Patch and reports which fields changed
+func PatchNote(c *gin.Context) {
+	note, _, ok := findAccessibleNote(c, canWrite)
+	if !ok {
+		return
+	}
+
+	var patched NotePatch
+	fields, err := applyPatchFields(c, NotePatch{Title: note.Title, Body: note.Body}, &patched)
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+
+	// format describes the patched body, so the stored body is never
+	// converted again
+	body := note.Body
+	if fields["body"] {
+		// HTML bodies are stored as Markdown
+		if body, err = bodyToMarkdown(patched.Body, patched.Format); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to convert HTML body"})
+			return
+		}
+	} else if patched.Format != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "format can only be given with a body"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	changed := []string{}
+	if patched.Title != note.Title {
+		updates["title"] = patched.Title
+		changed = append(changed, "title")
+	}
+	if body != note.Body {
+		// A live editing session owns the body until it ends
+		if collabHub.Active(note.NoteID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Note is being edited in a live session"})
+			return
+		}
+		updates["body"] = body
+		changed = append(changed, "body")
+	}
+
+	mentioned := []MentionResult{}
+	if len(updates) > 0 {
+		if err := config.DB.Model(&note).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+			return
+		}
+		invalidateListings(config.DB, models.AssetTypeNote, note.NoteID)
+		if _, bodyChanged := updates["body"]; bodyChanged {
+			// Keep comment anchors on their text and notify newly mentioned users
+			note.Body = body
+			reanchorComments(config.DB, note.NoteID, note.Body)
+			mentioned = syncMentions(note, models.MentionSourceNote, note.NoteID, actorOf(c), note.Body)
+		}
+	}
 
 	// Load updated note with relationships
 	config.DB.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Note updated successfully",
-		"note":    note,
+		"message":  "Note updated successfully",
+		"note":     note,
+		"changed":  changed,
+		"mentions": mentioned,
 	})
 }
 
-// DeleteNote deletes a note
+// DeleteNote deletes a note. The caller must own the note or its folder.
 func DeleteNote(c *gin.Context) {
 	noteIDStr := c.Param("noteId")
 	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
@@ -147,7 +300,7 @@ func DeleteNote(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := beginTransaction()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -155,28 +308,28 @@ func DeleteNote(c *gin.Context) {
 	}()
 
 	var note models.Note
-	if err := tx.First(&note, noteID).Error; err != nil {
+	if err := tx.Preload("Folder").First(&note, noteID).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
 		return
 	}
-
-	// Delete note shares
-	if err := tx.Where("note_id = ?", noteID).Delete(&models.NoteShare{}).Error; err != nil {
+	user, _ := middleware.CurrentUser(c)
+	if note.OwnerID != user.UserID && note.Folder.OwnerID != user.UserID {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note shares"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the note or folder owner can delete this note"})
 		return
 	}
 
-	// Delete the note
-	if err := tx.Delete(&note).Error; err != nil {
+	// Delete the note with its shares, attachments, tags, comments and
+	// everything else attached to it
+	if err := deleteNoteTree(tx, note); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		respondActionError(c, err)
 		return
 	}
 
 	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
+	if err := commitTransaction(tx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
@@ -184,7 +337,7 @@ func DeleteNote(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
 }
 
-// ShareNote shares a note with a user
+// ShareNote shares a note with a user. The caller must own the note.
 func ShareNote(c *gin.Context) {
 	noteIDStr := c.Param("noteId")
 	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
@@ -199,59 +352,47 @@ func ShareNote(c *gin.Context) {
 		return
 	}
 
-	// Check if note exists
-	var note models.Note
-	if err := config.DB.First(&note, noteID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+	user, _ := middleware.CurrentUser(c)
+	if _, err := requireNoteOwner(config.DB, user.UserID, uint(noteID)); err != nil {
+		respondActionError(c, err)
 		return
 	}
-
-	// Check if user exists
-	var user models.User
-	if err := config.DB.First(&user, req.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !allowShareTeam(c, req.TeamID, []uint{req.UserID}) {
 		return
 	}
 
-	// Check if note is already shared with this user
-	var existingShare models.NoteShare
-	if err := config.DB.Where("note_id = ? AND user_id = ?", noteID, req.UserID).First(&existingShare).Error; err == nil {
-		// Update existing share
-		existingShare.Access = req.Access
-		if err := config.DB.Save(&existingShare).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note share"})
-			return
-		}
-		config.DB.Preload("User").Preload("Note").First(&existingShare, existingShare.ID)
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Note share updated successfully",
-			"share":   existingShare,
-		})
+	// Create the share, or update the access of an existing one
+	var noteShare models.NoteShare
+	var created bool
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		var err error
+		noteShare, created, err = shareNoteWith(tx, uint(noteID), req.UserID, req.Access, req.TeamID)
+		return err
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
-	// Create new share
-	noteShare := models.NoteShare{
-		NoteID: uint(noteID),
-		UserID: req.UserID,
-		Access: req.Access,
-	}
+	// Load share with relationships
+	config.DB.Preload("User").Preload("Note").First(&noteShare, noteShare.ID)
 
-	if err := config.DB.Create(&noteShare).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share note"})
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Note share updated successfully",
+			"share":   noteShare,
+		})
 		return
 	}
 
-	// Load share with relationships
-	config.DB.Preload("User").Preload("Note").First(&noteShare, noteShare.ID)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Note shared successfully",
 		"share":   noteShare,
 	})
 }
 
-// RevokeNoteShare revokes note sharing for a user
+// RevokeNoteShare revokes note sharing for a user. The caller must own the
+// note.
 func RevokeNoteShare(c *gin.Context) {
 	noteIDStr := c.Param("noteId")
 	userIDStr := c.Param("userId")
@@ -268,17 +409,23 @@ func RevokeNoteShare(c *gin.Context) {
 		return
 	}
 
-	// Find and delete the note share
-	var noteShare models.NoteShare
-	if err := config.DB.Where("note_id = ? AND user_id = ?", noteID, userID).First(&noteShare).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note share not found"})
+	user, _ := middleware.CurrentUser(c)
+	if _, err := requireNoteOwner(config.DB, user.UserID, uint(noteID)); err != nil {
+		respondActionError(c, err)
 		return
 	}
 
-	if err := config.DB.Delete(&noteShare).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke note share"})
+	// Find and delete the note share
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return revokeNoteShareFrom(tx, uint(noteID), uint(userID))
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
+	// The user's bookmarks, recent views and notifications go with their access
+	forgetLostAssets(uint(userID))
+
 	c.JSON(http.StatusOK, gin.H{"message": "Note share revoked successfully"})
 }
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// patchTestNote sends a PATCH for a note as its owner with the given content
+// type and returns the status code
+func patchTestNote(t *testing.T, note models.Note, contentType, patch string) int {
+	t.Helper()
+	router := testRouter()
+	router.PATCH("/notes/:noteId", PatchNote)
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/notes/%d", note.NoteID), bytes.NewBufferString(patch))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, note.OwnerID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestPatchNoteConvertsOnlyAPatchedBody(t *testing.T) {
+	setupTestDB(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	// Markdown that would change if it were read as HTML
+	stored := "a  b\n\n<b>kept</b> *as is*"
+	config.DB.Model(&note).Update("body", stored)
+
+	if status := patchTestNote(t, note, MergePatchType, `{"title": "renamed"}`); status != http.StatusOK {
+		t.Fatalf("renaming returned %d", status)
+	}
+	config.DB.First(&note, note.NoteID)
+	if note.Title != "renamed" || note.Body != stored {
+		t.Fatalf("renaming left title %q and body %q", note.Title, note.Body)
+	}
+
+	for contentType, patch := range map[string]string{
+		MergePatchType: `{"format": "html"}`,
+		JSONPatchType:  `[{"op": "add", "path": "/format", "value": "html"}]`,
+	} {
+		if status := patchTestNote(t, note, contentType, patch); status != http.StatusUnprocessableEntity {
+			t.Fatalf("%s format without a body returned %d, want 422", contentType, status)
+		}
+	}
+
+	patch := `[{"op": "replace", "path": "/body", "value": "<p><strong>bold</strong></p>"}, {"op": "add", "path": "/format", "value": "html"}]`
+	if status := patchTestNote(t, note, JSONPatchType, patch); status != http.StatusOK {
+		t.Fatalf("patching an HTML body returned %d", status)
+	}
+	config.DB.First(&note, note.NoteID)
+	if note.Body != "**bold**" {
+		t.Fatalf("HTML body stored as %q, want **bold**", note.Body)
+	}
+}
+
+func TestNoteRoutesCheckAccess(t *testing.T) {
+	setupTestDB(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+
+	router := testRouter()
+	router.GET("/notes/:noteId", middleware.RequireAuth(), GetNote)
+	router.PUT("/notes/:noteId", middleware.RequireAuth(), UpdateNote)
+	router.DELETE("/notes/:noteId", middleware.RequireAuth(), DeleteNote)
+	router.POST("/notes/:noteId/share", middleware.RequireAuth(), ShareNote)
+	router.POST("/folders/:folderId/notes", middleware.RequireAuth(), CreateNote)
+	path := fmt.Sprintf("/notes/%d", note.NoteID)
+	update := gin.H{"title": "renamed", "body": "changed"}
+
+	if status, _ := testRequest(t, router, 0, http.MethodGet, path, nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous GET returned %d, want 401", status)
+	}
+	if status, _ := testRequest(t, router, testReader, http.MethodGet, path, nil); status != http.StatusForbidden {
+		t.Errorf("GET without access returned %d, want 403", status)
+	}
+	if status, _ := testRequest(t, router, testReader, http.MethodPost, fmt.Sprintf("/folders/%d/notes", folder.FolderID),
+		gin.H{"title": "mine", "ownerId": testReader}); status != http.StatusForbidden {
+		t.Errorf("creating a note in another user's folder returned %d, want 403", status)
+	}
+
+	if err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		_, _, err := shareNoteWith(tx, note.NoteID, testReader, AccessWrite, nil)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := testRequest(t, router, testReader, http.MethodPut, path, update); status != http.StatusOK {
+		t.Errorf("PUT with write access returned %d", status)
+	}
+	for method, body := range map[string]interface{}{
+		http.MethodDelete: nil,
+		http.MethodPost:   gin.H{"userId": testManager, "access": AccessRead},
+	} {
+		target := path
+		if method == http.MethodPost {
+			target += "/share"
+		}
+		if status, _ := testRequest(t, router, testReader, method, target, body); status != http.StatusForbidden {
+			t.Errorf("%s %s by a writer returned %d, want 403", method, target, status)
+		}
+	}
+	if status, _ := testRequest(t, router, testOwner, http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Fatalf("owner DELETE returned %d", status)
+	}
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/mentions"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// maxNotifications is how many notifications are listed at once
+const maxNotifications = 100
+
+// MentionResult reports a user mentioned in a saved note or comment. Users
+// who cannot read the note are not notified; share the note with them and
+// save again to notify them.
+type MentionResult struct {
+	UserID   uint   `json:"userId"`
+	Username string `json:"username"`
+	CanRead  bool   `json:"canRead"`
+	Notified bool   `json:"notified"`
+}
+
+// ListNotifications lists the caller's notifications, newest first.
+// ?unread=true lists only unread ones. Notifications about notes the caller
+// can no longer read are hidden, and left out of the unread count.
+func ListNotifications(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	visible := func() *gorm.DB {
+		return config.DB.Model(&models.Notification{}).
+			Where("user_id = ? AND note_id IN (?)", user.UserID, accessibleAssets(config.DB, user.UserID, models.AssetTypeNote))
+	}
+
+	query := visible().Preload("Actor")
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	notifications := []models.Notification{}
+	if err := query.Order("created_at DESC").Limit(maxNotifications).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
+	var unread int64
+	if err := visible().Where("read_at IS NULL").Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread":        unread,
+	})
+}
+
+// MarkNotificationRead marks one of the caller's notifications as read
+func MarkNotificationRead(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	notificationID, err := strconv.ParseUint(c.Param("notificationId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	var notification models.Notification
+	if err := config.DB.Where("user_id = ?", user.UserID).First(&notification, notificationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := config.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Notification marked as read",
+		"notification": notification,
+	})
+}
+
+// MarkAllNotificationsRead marks all of the caller's notifications as read
+func MarkAllNotificationsRead(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	result := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", user.UserID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications marked as read",
+		"updated": result.RowsAffected,
+	})
+}
+
+// syncMentions resolves the @mentions in a saved note body or comment and
+// notifies users who are newly mentioned and can read the note. The author
+// is never notified of their own mentions; actorID is nil for saves from a
+// live editing session.
+func syncMentions(note models.Note, sourceType string, sourceID uint, actorID *uint, text string) []MentionResult {
+	results := []MentionResult{}
+
+	var users []models.User
+	usernames := mentions.Parse(text)
+	if len(usernames) > 0 {
+		lowered := make([]string, len(usernames))
+		for i, username := range usernames {
+			lowered[i] = strings.ToLower(username)
+		}
+		config.DB.Where("LOWER(username) IN ?", lowered).Find(&users)
+	}
+
+	// Usernames are matched case-insensitively, preferring an exact match
+	byName := make(map[string]models.User, len(users))
+	for _, user := range users {
+		key := strings.ToLower(user.Username)
+		if _, taken := byName[key]; !taken || slices.Contains(usernames, user.Username) {
+			byName[key] = user
+		}
+	}
+
+	var existing []models.Mention
+	config.DB.Where("source_type = ? AND source_id = ?", sourceType, sourceID).Find(&existing)
+	alreadyMentioned := make(map[uint]bool, len(existing))
+	for _, mention := range existing {
+		alreadyMentioned[mention.UserID] = true
+	}
+
+	kept := make(map[uint]bool)
+	for _, username := range usernames {
+		user, found := byName[strings.ToLower(username)]
+		if !found || kept[user.UserID] || (actorID != nil && user.UserID == *actorID) {
+			continue
+		}
+
+		result := MentionResult{
+			UserID:   user.UserID,
+			Username: user.Username,
+			CanRead:  canRead(noteAccessFor(user.UserID, note)),
+		}
+		if result.CanRead {
+			kept[user.UserID] = true
+			if !alreadyMentioned[user.UserID] {
+				result.Notified = notifyMention(note, sourceType, sourceID, actorID, user.UserID) == nil
+			}
+		}
+		results = append(results, result)
+	}
+
+	// Users no longer mentioned, or who lost access, are notified again if
+	// they are mentioned in a later save
+	for _, mention := range existing {
+		if !kept[mention.UserID] {
+			config.DB.Delete(&mention)
+		}
+	}
+	return results
+}
+
+// notifyMention records a new mention and sends its notification
+func notifyMention(note models.Note, sourceType string, sourceID uint, actorID *uint, userID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		mention := models.Mention{
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			NoteID:     note.NoteID,
+			UserID:     userID,
+		}
+		if err := tx.Create(&mention).Error; err != nil {
+			return err
+		}
+
+		notification := models.Notification{
+			UserID:  userID,
+			ActorID: actorID,
+			Type:    models.NotificationMention,
+			NoteID:  note.NoteID,
+			Link:    fmt.Sprintf("/notes/%d", note.NoteID),
+		}
+		if sourceType == models.MentionSourceComment {
+			notification.CommentID = &sourceID
+			notification.Link = fmt.Sprintf("/notes/%d#comment-%d", note.NoteID, sourceID)
+		}
+		return tx.Create(&notification).Error
+	})
+}
+
+// actorOf returns the id of the signed-in caller, or nil for anonymous requests
+func actorOf(c *gin.Context) *uint {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return nil
+	}
+	return &user.UserID
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+func TestListNotificationsHidesNotesTheCallerCannotRead(t *testing.T) {
+	setupTestDB(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	kept := createTestNote(t, folder, testOwner, "plan")
+	lost := createTestNote(t, folder, testOwner, "notes")
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		for _, note := range []models.Note{kept, lost} {
+			if _, _, err := shareNoteWith(tx, note.NoteID, testReader, AccessRead, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []models.Note{kept, lost} {
+		if err := notifyMention(note, models.MentionSourceNote, note.NoteID, nil, testReader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := testRouter()
+	router.GET("/me/notifications", middleware.RequireAuth(), ListNotifications)
+	router.DELETE("/notes/:noteId/share/:userId", middleware.RequireAuth(), RevokeNoteShare)
+	path := fmt.Sprintf("/notes/%d/share/%d", lost.NoteID, testReader)
+	if status, response := testRequest(t, router, testOwner, http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Fatalf("revoking the share returned %d: %v", status, response)
+	}
+
+	status, response := testRequest(t, router, testReader, http.MethodGet, "/me/notifications", nil)
+	if status != http.StatusOK {
+		t.Fatalf("listing notifications returned %d: %v", status, response)
+	}
+	notifications := response["notifications"].([]interface{})
+	if len(notifications) != 1 || notifications[0].(map[string]interface{})["noteId"] != float64(kept.NoteID) {
+		t.Fatalf("notifications = %v, want only the one on the readable note", notifications)
+	}
+	if response["unread"] != float64(1) {
+		t.Fatalf("unread = %v, want 1", response["unread"])
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Content types accepted by PATCH endpoints
+const (
+	// MergePatchType is an RFC 7396 JSON Merge Patch. Plain application/json
+	// bodies are treated as merge patches too.
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is an RFC 6902 JSON Patch
+	JSONPatchType = "application/json-patch+json"
+)
+
+// applyPatch applies the request body as a patch to current and decodes the
+// result into patched, which is then validated. Fields that are not part of
+// the patchable document are rejected.
+func applyPatch(c *gin.Context, current, patched interface{}) error {
+	_, err := applyPatchFields(c, current, patched)
+	return err
+}
+
+// applyPatchFields is applyPatch that also returns the top-level fields the
+// patch writes, whether or not their values change. Fields a JSON Patch only
+// tests are not included.
+func applyPatchFields(c *gin.Context, current, patched interface{}) (map[string]bool, error) {
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil || len(bytes.TrimSpace(patch)) == 0 {
+		return nil, failAction(http.StatusBadRequest, "Patch body is required")
+	}
+
+	document, err := json.Marshal(current)
+	if err != nil {
+		return nil, failAction(http.StatusInternalServerError, "Failed to read current document")
+	}
+
+	fields := make(map[string]bool)
+	switch c.ContentType() {
+	case JSONPatchType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, failAction(http.StatusBadRequest, "Invalid JSON Patch: "+err.Error())
+		}
+		for _, operation := range operations {
+			if operation.Kind() == "test" {
+				continue
+			}
+			path, _ := operation.Path()
+			fields[topLevelField(path)] = true
+			if operation.Kind() == "move" {
+				from, _ := operation.From()
+				fields[topLevelField(from)] = true
+			}
+		}
+		document, err = operations.Apply(document)
+		if err != nil {
+			return nil, failAction(http.StatusUnprocessableEntity, "Failed to apply JSON Patch: "+err.Error())
+		}
+	case MergePatchType, "application/json", "":
+		document, err = jsonpatch.MergePatch(document, patch)
+		if err != nil {
+			return nil, failAction(http.StatusBadRequest, "Invalid merge patch: "+err.Error())
+		}
+		var named map[string]json.RawMessage
+		json.Unmarshal(patch, &named)
+		for field := range named {
+			fields[field] = true
+		}
+	default:
+		return nil, failAction(http.StatusUnsupportedMediaType, "Content-Type must be "+MergePatchType+" or "+JSONPatchType)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patched); err != nil {
+		return nil, failAction(http.StatusUnprocessableEntity, "Patched document is invalid: "+err.Error())
+	}
+	if err := binding.Validator.ValidateStruct(patched); err != nil {
+		return nil, failAction(http.StatusUnprocessableEntity, err.Error())
+	}
+	return fields, nil
+}
+
+// topLevelField returns the field a JSON Pointer such as "/body" or
+// "/tags/0" starts with
+func topLevelField(pointer string) string {
+	field, _, _ := strings.Cut(strings.TrimPrefix(pointer, "/"), "/")
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(field)
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/presence"
+)
+
+// presenceTracker holds who currently has a note or folder open
+var presenceTracker = presence.NewTracker()
+
+// HeartbeatRequest represents a presence heartbeat from a connected client
+type HeartbeatRequest struct {
+	SessionID string `json:"sessionId" binding:"required"`
+	State     string `json:"state" binding:"omitempty,oneof=viewing editing"`
+}
+
+// NoteHeartbeat records that the caller has a note open
+func NoteHeartbeat(c *gin.Context) {
+	presenceHeartbeat(c, presence.AssetNote, "noteId")
+}
+
+// NoteLeave removes the caller's session from a note's roster
+func NoteLeave(c *gin.Context) {
+	presenceLeave(c, presence.AssetNote, "noteId")
+}
+
+// GetNotePresence lists who currently has a note open
+func GetNotePresence(c *gin.Context) {
+	presenceRoster(c, presence.AssetNote, "noteId")
+}
+
+// StreamNotePresence streams a note's roster as server-sent events
+func StreamNotePresence(c *gin.Context) {
+	presenceStream(c, presence.AssetNote, "noteId")
+}
+
+// FolderHeartbeat records that the caller has a folder open
+func FolderHeartbeat(c *gin.Context) {
+	presenceHeartbeat(c, presence.AssetFolder, "folderId")
+}
+
+// FolderLeave removes the caller's session from a folder's roster
+func FolderLeave(c *gin.Context) {
+	presenceLeave(c, presence.AssetFolder, "folderId")
+}
+
+// GetFolderPresence lists who currently has a folder open
+func GetFolderPresence(c *gin.Context) {
+	presenceRoster(c, presence.AssetFolder, "folderId")
+}
+
+// StreamFolderPresence streams a folder's roster as server-sent events
+func StreamFolderPresence(c *gin.Context) {
+	presenceStream(c, presence.AssetFolder, "folderId")
+}
+
+// assetAccess resolves the caller's access to the note or folder behind a
+// presence key. ok is false if the asset does not exist.
+func assetAccess(userID uint, key presence.Key) (access string, ok bool) {
+	return assetAccessFor(userID, key.AssetType, key.AssetID)
+}
+
+// presenceKey parses the asset id and checks that the caller can read it.
+// It writes the error response and returns false otherwise.
+func presenceKey(c *gin.Context, assetType, param string) (presence.Key, models.User, bool) {
+	user, _ := middleware.CurrentUser(c)
+
+	assetID, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + assetType + " ID"})
+		return presence.Key{}, user, false
+	}
+
+	key := presence.Key{AssetType: assetType, AssetID: uint(assetID)}
+	access, found := assetAccess(user.UserID, key)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return key, user, false
+	}
+	if !canRead(access) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this " + assetType})
+		return key, user, false
+	}
+	return key, user, true
+}
+
+// visibleViewers drops viewers who have lost read access since their last
+// heartbeat so the roster never shows people who cannot see the asset
+func visibleViewers(key presence.Key, viewers []presence.Viewer) []presence.Viewer {
+	allowed := make(map[uint]bool)
+	visible := make([]presence.Viewer, 0, len(viewers))
+	for _, v := range viewers {
+		ok, checked := allowed[v.UserID]
+		if !checked {
+			access, _ := assetAccess(v.UserID, key)
+			ok = canRead(access)
+			allowed[v.UserID] = ok
+		}
+		if ok {
+			visible = append(visible, v)
+		}
+	}
+	return visible
+}
+
+func presenceHeartbeat(c *gin.Context, assetType, param string) {
+	key, user, ok := presenceKey(c, assetType, param)
+	if !ok {
+		return
+	}
+
+	var req HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.State == "" {
+		req.State = presence.StateViewing
+	}
+
+	roster := presenceTracker.Heartbeat(key, presence.Viewer{
+		SessionID: req.SessionID,
+		UserID:    user.UserID,
+		Username:  user.Username,
+		State:     req.State,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"viewers": visibleViewers(key, roster),
+	})
+}
+
+func presenceLeave(c *gin.Context, assetType, param string) {
+	key, user, ok := presenceKey(c, assetType, param)
+	if !ok {
+		return
+	}
+
+	presenceTracker.Leave(key, user.UserID, c.Param("sessionId"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session left successfully"})
+}
+
+func presenceRoster(c *gin.Context, assetType, param string) {
+	key, _, ok := presenceKey(c, assetType, param)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"viewers": visibleViewers(key, presenceTracker.Roster(key)),
+	})
+}
+
+func presenceStream(c *gin.Context, assetType, param string) {
+	key, user, ok := presenceKey(c, assetType, param)
+	if !ok {
+		return
+	}
+
+	updates := presenceTracker.Subscribe(key)
+	defer presenceTracker.Unsubscribe(key, updates)
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case roster, open := <-updates:
+			if !open {
+				return false
+			}
+			// Stop streaming once the watcher loses access themselves
+			if access, _ := assetAccess(user.UserID, key); !canRead(access) {
+				return false
+			}
+			c.SSEvent("presence", gin.H{"viewers": visibleViewers(key, roster)})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/markdown"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+// Body formats accepted when creating or updating a note
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// bodyToMarkdown converts a submitted note body to the Markdown that is stored
+func bodyToMarkdown(body, format string) (string, error) {
+	if format == FormatHTML {
+		return markdown.FromHTML(body)
+	}
+	return body, nil
+}
+
+// RenderNote renders a note's Markdown body to sanitized HTML
+func RenderNote(c *gin.Context) {
+	noteIDStr := c.Param("noteId")
+	noteID, err := strconv.ParseUint(noteIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+
+	var note models.Note
+	if err := config.DB.First(&note, noteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	if !canRead(noteAccessFor(user.UserID, note)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this note"})
+		return
+	}
+
+	html, err := markdown.Render(note.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render note"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"noteId": note.NoteID,
+		"title":  note.Title,
+		"html":   html,
+	})
+}
+
+// GetMarkdownStyles returns the stylesheet for highlighted code blocks
+func GetMarkdownStyles(c *gin.Context) {
+	css, err := markdown.StyleSheet()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build stylesheet"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/css; charset=utf-8", []byte(css))
+}
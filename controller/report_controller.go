@@ -0,0 +1,360 @@
+package controller
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/reports"
+	"gorm.io/gorm"
+)
+
+// defaultStaleDays is how long an asset goes without updates before a
+// report lists it as stale, unless ?staleDays= says otherwise
+const defaultStaleDays = 90
+
+// reportDateLayout is the format of the from and to dates of a report
+const reportDateLayout = "2006-01-02"
+
+// TeamReportQuery holds the query options of a team report. from and to are
+// dates, both inclusive; either can be left out.
+type TeamReportQuery struct {
+	From      string `form:"from"`
+	To        string `form:"to"`
+	StaleDays int    `form:"staleDays" binding:"omitempty,min=1,max=3650"`
+	Format    string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+	Section   string `form:"section" binding:"omitempty,oneof=members stale"`
+}
+
+// GetTeamReport aggregates, per member and manager, the folders and notes
+// they own, the shares they gave and received, the size of their notes and
+// when they were last active, and lists the team's stale assets
+func GetTeamReport(c *gin.Context) {
+	team, report, ok := teamReportFor(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"teamId": team.TeamID,
+		"report": report,
+	})
+}
+
+// DownloadTeamReport returns a team report as a file: ?format=xlsx (the
+// default) for a workbook with every table, or ?format=csv&section=members|stale
+// for one table
+func DownloadTeamReport(c *gin.Context) {
+	team, report, ok := teamReportFor(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "xlsx")
+	fileName := fmt.Sprintf("team-%d-report", team.TeamID)
+	if report.From != nil {
+		fileName += "-from-" + report.From.Format(reportDateLayout)
+	}
+	if report.To != nil {
+		fileName += "-to-" + report.To.AddDate(0, 0, -1).Format(reportDateLayout)
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+	switch format {
+	case "csv":
+		section := c.DefaultQuery("section", reports.SectionMembers)
+		if err := reports.WriteCSV(&buf, report, section); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write report"})
+			return
+		}
+		contentType = "text/csv"
+		fileName += "-" + section + ".csv"
+	default:
+		if err := reports.WriteXLSX(&buf, report); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write report"})
+			return
+		}
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		fileName += ".xlsx"
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// teamReportFor loads the team named by the teamId parameter, checks that the
+// caller can manage it and builds its report from the query options. It
+// writes the error response and returns false otherwise.
+func teamReportFor(c *gin.Context) (models.Team, reports.TeamReport, bool) {
+	var query TeamReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.Team{}, reports.TeamReport{}, false
+	}
+
+	var from, to *time.Time
+	if query.From != "" {
+		day, err := time.Parse(reportDateLayout, query.From)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2024-01-31"})
+			return models.Team{}, reports.TeamReport{}, false
+		}
+		from = &day
+	}
+	if query.To != "" {
+		day, err := time.Parse(reportDateLayout, query.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2024-01-31"})
+			return models.Team{}, reports.TeamReport{}, false
+		}
+		// The whole of the last day is included
+		end := day.AddDate(0, 0, 1)
+		to = &end
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return models.Team{}, reports.TeamReport{}, false
+	}
+	if query.StaleDays == 0 {
+		query.StaleDays = defaultStaleDays
+	}
+
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return team, reports.TeamReport{}, false
+	}
+
+	report, err := buildTeamReport(team, from, to, query.StaleDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return team, report, false
+	}
+	return team, report, true
+}
+
+// userCount is a per-user aggregate read from the database
+type userCount struct {
+	UserID uint
+	Count  int64
+	Size   int64
+}
+
+// userTime is a per-user latest time read from the database
+type userTime struct {
+	UserID uint
+	Latest latestTime
+}
+
+// latestTime scans the MAX of a time column. Postgres keeps the column type;
+// SQLite returns the stored text, which is parsed here.
+type latestTime struct {
+	time.Time
+}
+
+// sqliteTimeLayouts are the layouts SQLite drivers store times in
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// Scan implements sql.Scanner
+func (t *latestTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		value = string(v)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T as a time", value)
+	}
+	for _, layout := range sqliteTimeLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a time", text)
+}
+
+// Value implements driver.Valuer, which gorm needs to accept the type
+func (t latestTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+// buildTeamReport aggregates the figures of a team's members and managers
+// over the period [from, to), either end of which may be open
+func buildTeamReport(team models.Team, from, to *time.Time, staleDays int) (reports.TeamReport, error) {
+	now := time.Now()
+	report := reports.TeamReport{
+		TeamID:      team.TeamID,
+		TeamName:    team.TeamName,
+		From:        from,
+		To:          to,
+		StaleDays:   staleDays,
+		GeneratedAt: now,
+		Members:     []reports.MemberStats{},
+		StaleAssets: []reports.StaleAsset{},
+	}
+
+	userIDs := teamUserIDs(config.DB, team.TeamID)
+	if len(userIDs) == 0 {
+		return report, nil
+	}
+	managerIDs, err := teamRoleUserIDs(config.DB, team.TeamID, models.TeamRoleManager)
+	if err != nil {
+		return report, err
+	}
+	isManager := make(map[uint]bool, len(managerIDs))
+	for _, id := range managerIDs {
+		isManager[id] = true
+	}
+
+	// during limits a query to rows whose column falls in the period
+	during := func(query *gorm.DB, column string) *gorm.DB {
+		if from != nil {
+			query = query.Where(column+" >= ?", *from)
+		}
+		if to != nil {
+			query = query.Where(column+" < ?", *to)
+		}
+		return query
+	}
+
+	var folders, notes, folderSharesGiven, noteSharesGiven, folderSharesReceived, noteSharesReceived []userCount
+	queries := []struct {
+		query *gorm.DB
+		dest  *[]userCount
+	}{
+		{during(config.DB.Model(&models.Folder{}).
+			Select("owner_id AS user_id, COUNT(*) AS count").
+			Where("owner_id IN ?", userIDs), "created_at").Group("owner_id"), &folders},
+		{during(config.DB.Model(&models.Note{}).
+			Select("owner_id AS user_id, COUNT(*) AS count, COALESCE(SUM(LENGTH(body)), 0) AS size").
+			Where("owner_id IN ?", userIDs), "created_at").Group("owner_id"), &notes},
+		{during(config.DB.Table("folder_shares").
+			Select("folders.owner_id AS user_id, COUNT(*) AS count").
+			Joins("JOIN folders ON folders.folder_id = folder_shares.folder_id AND folders.deleted_at IS NULL").
+			Where("folders.owner_id IN ?", userIDs), "folder_shares.created_at").Group("folders.owner_id"), &folderSharesGiven},
+		{during(config.DB.Table("note_shares").
+			Select("notes.owner_id AS user_id, COUNT(*) AS count").
+			Joins("JOIN notes ON notes.note_id = note_shares.note_id AND notes.deleted_at IS NULL").
+			Where("notes.owner_id IN ?", userIDs), "note_shares.created_at").Group("notes.owner_id"), &noteSharesGiven},
+		{during(config.DB.Table("folder_shares").
+			Select("user_id, COUNT(*) AS count").
+			Where("user_id IN ?", userIDs), "created_at").Group("user_id"), &folderSharesReceived},
+		{during(config.DB.Table("note_shares").
+			Select("user_id, COUNT(*) AS count").
+			Where("user_id IN ?", userIDs), "created_at").Group("user_id"), &noteSharesReceived},
+	}
+	for _, q := range queries {
+		if err := q.query.Scan(q.dest).Error; err != nil {
+			return report, err
+		}
+	}
+
+	// Activity is the latest change to an owned folder or note, or the
+	// latest comment written, in the period: the newest of each user's
+	// latest time in the three tables
+	lastActivity := make(map[uint]time.Time)
+	activities := []*gorm.DB{
+		during(config.DB.Model(&models.Folder{}).Select("owner_id AS user_id, MAX(updated_at) AS latest").
+			Where("owner_id IN ?", userIDs), "updated_at").Group("owner_id"),
+		during(config.DB.Model(&models.Note{}).Select("owner_id AS user_id, MAX(updated_at) AS latest").
+			Where("owner_id IN ?", userIDs), "updated_at").Group("owner_id"),
+		during(config.DB.Model(&models.Comment{}).Select("author_id AS user_id, MAX(created_at) AS latest").
+			Where("author_id IN ?", userIDs), "created_at").Group("author_id"),
+	}
+	for _, query := range activities {
+		var times []userTime
+		if err := query.Scan(&times).Error; err != nil {
+			return report, err
+		}
+		for _, t := range times {
+			if t.Latest.After(lastActivity[t.UserID]) {
+				lastActivity[t.UserID] = t.Latest.Time
+			}
+		}
+	}
+
+	byUser := func(counts []userCount) map[uint]userCount {
+		m := make(map[uint]userCount, len(counts))
+		for _, count := range counts {
+			m[count.UserID] = count
+		}
+		return m
+	}
+	folderCounts, noteCounts := byUser(folders), byUser(notes)
+	folderGiven, noteGiven := byUser(folderSharesGiven), byUser(noteSharesGiven)
+	folderReceived, noteReceived := byUser(folderSharesReceived), byUser(noteSharesReceived)
+
+	for _, user := range loadUsers(userIDs) {
+		stats := reports.MemberStats{
+			UserID:         user.UserID,
+			Username:       user.Username,
+			Email:          user.Email,
+			Role:           models.TeamRoleMember,
+			FoldersOwned:   folderCounts[user.UserID].Count,
+			NotesOwned:     noteCounts[user.UserID].Count,
+			SharesGiven:    folderGiven[user.UserID].Count + noteGiven[user.UserID].Count,
+			SharesReceived: folderReceived[user.UserID].Count + noteReceived[user.UserID].Count,
+			BodySize:       noteCounts[user.UserID].Size,
+		}
+		if isManager[user.UserID] {
+			stats.Role = models.TeamRoleManager
+		}
+		if latest, ok := lastActivity[user.UserID]; ok {
+			stats.LastActivity = &latest
+		}
+		report.Members = append(report.Members, stats)
+	}
+
+	// Stale assets are listed whatever the period, oldest first
+	cutoff := now.AddDate(0, 0, -staleDays)
+	var staleFolders []models.Folder
+	if err := config.DB.Preload("Owner").Where("owner_id IN ? AND updated_at < ?", userIDs, cutoff).
+		Order("updated_at").Find(&staleFolders).Error; err != nil {
+		return report, err
+	}
+	for _, folder := range staleFolders {
+		report.StaleAssets = append(report.StaleAssets, reports.StaleAsset{
+			AssetType: models.AssetTypeFolder,
+			AssetID:   folder.FolderID,
+			Name:      folder.Name,
+			OwnerID:   folder.OwnerID,
+			Owner:     folder.Owner.Username,
+			UpdatedAt: folder.UpdatedAt,
+			DaysStale: int(now.Sub(folder.UpdatedAt).Hours() / 24),
+		})
+	}
+	var staleNotes []models.Note
+	if err := config.DB.Preload("Owner").Where("owner_id IN ? AND updated_at < ?", userIDs, cutoff).
+		Order("updated_at").Find(&staleNotes).Error; err != nil {
+		return report, err
+	}
+	for _, note := range staleNotes {
+		report.StaleAssets = append(report.StaleAssets, reports.StaleAsset{
+			AssetType: models.AssetTypeNote,
+			AssetID:   note.NoteID,
+			Name:      note.Title,
+			OwnerID:   note.OwnerID,
+			Owner:     note.Owner.Username,
+			UpdatedAt: note.UpdatedAt,
+			DaysStale: int(now.Sub(note.UpdatedAt).Hours() / 24),
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Users seeded by setupTestDB
+const (
+	testAdmin   uint = 1
+	testOwner   uint = 2
+	testReader  uint = 3
+	testManager uint = 4
+)
+
+// setupTestDB points config.DB at a fresh in-memory database holding every
+// table and four users: an admin, two users and a manager
+func setupTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens its own database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&models.User{}, &models.Team{}, &models.TeamMember{}, &models.TeamManager{},
+		&models.Folder{}, &models.Note{}, &models.FolderShare{}, &models.NoteShare{},
+		&models.Attachment{}, &models.AttachmentThumbnail{}, &models.Tag{}, &models.NoteTag{},
+		&models.Bookmark{}, &models.RecentView{}, &models.Comment{}, &models.Mention{},
+		&models.Notification{}, &models.TeamInvitation{}, &models.EffectiveAccess{},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, role := range []string{models.RoleAdmin, models.RoleUser, models.RoleUser, models.RoleManager} {
+		user := models.User{
+			Username:     fmt.Sprintf("user%d", i+1),
+			Email:        fmt.Sprintf("user%d@example.com", i+1),
+			Role:         role,
+			PasswordHash: "-",
+		}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousDB, previousCache := config.DB, config.Cache
+	config.DB, config.Cache = db, nil
+	t.Cleanup(func() { config.DB, config.Cache = previousDB, previousCache })
+	return db
+}
+
+// testRouter returns an engine that authenticates callers like the server
+func testRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(middleware.Authenticate())
+	return router
+}
+
+// testRequest sends a request as userID, or anonymously when userID is 0,
+// and decodes the JSON response
+func testRequest(t testing.TB, router *gin.Engine, userID uint, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.Header.Set("Authorization", "Bearer "+testToken(t, userID))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// testToken signs a token for userID like the login endpoint
+func testToken(t testing.TB, userID uint) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": userID,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(config.JWTSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// createTestFolder creates a folder owned by ownerID with its effective access
+func createTestFolder(t *testing.T, ownerID uint, name string) models.Folder {
+	t.Helper()
+	folder := models.Folder{Name: name, OwnerID: ownerID}
+	if err := config.DB.Create(&folder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshFolderAccess(config.DB, folder.FolderID); err != nil {
+		t.Fatal(err)
+	}
+	return folder
+}
+
+// createTestNote creates a note in a folder with its effective access
+func createTestNote(t *testing.T, folder models.Folder, ownerID uint, title string) models.Note {
+	t.Helper()
+	note := models.Note{Title: title, Body: "body", FolderID: folder.FolderID, OwnerID: ownerID}
+	if err := config.DB.Create(&note).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshNoteAccess(config.DB, note.NoteID); err != nil {
+		t.Fatal(err)
+	}
+	return note
+}
@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tag filter modes
+const (
+	TagMatchAll = "all"
+	TagMatchAny = "any"
+)
+
+// TagRequest represents the request for creating or renaming a tag
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// MergeTagRequest represents the request for merging a tag into another
+type MergeTagRequest struct {
+	TargetTagID uint `json:"targetTagId" binding:"required"`
+}
+
+// BulkTagRequest represents the request for tagging or untagging notes
+type BulkTagRequest struct {
+	NoteIDs []uint `json:"noteIds" binding:"required,min=1"`
+	TagIDs  []uint `json:"tagIds" binding:"required,min=1"`
+}
+
+// TagWithCount is a tag with the number of notes it is applied to
+type TagWithCount struct {
+	models.Tag
+	NoteCount int64 `json:"noteCount"`
+}
+
+// normalizeTagName trims and lowercases a tag so names compare case-insensitively
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// isDuplicate reports whether err is a unique constraint violation
+func isDuplicate(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// ListTags lists the caller's tags with how many notes each is applied to
+func ListTags(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	var tags []TagWithCount
+	err := config.DB.Model(&models.Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM note_tags WHERE note_tags.tag_id = tags.tag_id) AS note_count").
+		Where("owner_id = ?", user.UserID).
+		Order("name").
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// CreateTag creates a tag for the caller
+func CreateTag(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := normalizeTagName(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name cannot be empty"})
+		return
+	}
+
+	var existing models.Tag
+	if err := config.DB.Where("owner_id = ? AND name = ?", user.UserID, name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists", "tag": existing})
+		return
+	}
+
+	tag := models.Tag{OwnerID: user.UserID, Name: name}
+	if err := config.DB.Create(&tag).Error; err != nil {
+		// A concurrent request created it since the check above
+		if isDuplicate(config.DB, err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Tag created successfully",
+		"tag":     tag,
+	})
+}
+
+// RenameTag renames one of the caller's tags
+func RenameTag(c *gin.Context) {
+	tag, ok := findOwnTag(c, c.Param("tagId"))
+	if !ok {
+		return
+	}
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := normalizeTagName(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name cannot be empty"})
+		return
+	}
+
+	var existing models.Tag
+	if err := config.DB.Where("owner_id = ? AND name = ? AND tag_id <> ?", tag.OwnerID, name, tag.TagID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another tag already has this name, merge the tags instead", "tag": existing})
+		return
+	}
+
+	tag.Name = name
+	if err := config.DB.Save(&tag).Error; err != nil {
+		if isDuplicate(config.DB, err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another tag already has this name, merge the tags instead"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename tag"})
+		return
+	}
+	invalidateTaggedListings(config.DB, tag.TagID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tag renamed successfully",
+		"tag":     tag,
+	})
+}
+
+// MergeTag moves every note of a tag onto another of the caller's tags and
+// deletes the merged tag
+func MergeTag(c *gin.Context) {
+	source, ok := findOwnTag(c, c.Param("tagId"))
+	if !ok {
+		return
+	}
+
+	var req MergeTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, ok := findOwnTag(c, strconv.FormatUint(uint64(req.TargetTagID), 10))
+	if !ok {
+		return
+	}
+	if source.TagID == target.TagID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A tag cannot be merged into itself"})
+		return
+	}
+
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		invalidateTaggedListings(tx, source.TagID)
+
+		// Notes that already carry the target tag only lose the source tag
+		if err := tx.Exec(`UPDATE note_tags SET tag_id = ? WHERE tag_id = ?
+			AND note_id NOT IN (SELECT note_id FROM note_tags WHERE tag_id = ?)`,
+			target.TagID, source.TagID, target.TagID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.TagID).Delete(&models.NoteTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tags merged successfully",
+		"tag":     target,
+	})
+}
+
+// DeleteTag deletes one of the caller's tags and removes it from all notes
+func DeleteTag(c *gin.Context) {
+	tag, ok := findOwnTag(c, c.Param("tagId"))
+	if !ok {
+		return
+	}
+
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		invalidateTaggedListings(tx, tag.TagID)
+		if err := tx.Where("tag_id = ?", tag.TagID).Delete(&models.NoteTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// TagNotes applies the caller's tags to notes they own
+func TagNotes(c *gin.Context) {
+	req, ok := bindBulkTagRequest(c)
+	if !ok {
+		return
+	}
+
+	var links []models.NoteTag
+	for _, noteID := range req.NoteIDs {
+		for _, tagID := range req.TagIDs {
+			links = append(links, models.NoteTag{NoteID: noteID, TagID: tagID})
+		}
+	}
+
+	// Notes that already carry a tag keep their existing link
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tag notes"})
+		return
+	}
+	invalidateListings(config.DB, models.AssetTypeNote, req.NoteIDs...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notes tagged successfully",
+		"tags":    loadNoteTags(req.NoteIDs),
+	})
+}
+
+// UntagNotes removes the caller's tags from notes they own
+func UntagNotes(c *gin.Context) {
+	req, ok := bindBulkTagRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Where("note_id IN ? AND tag_id IN ?", req.NoteIDs, req.TagIDs).Delete(&models.NoteTag{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to untag notes"})
+		return
+	}
+	invalidateListings(config.DB, models.AssetTypeNote, req.NoteIDs...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notes untagged successfully",
+		"tags":    loadNoteTags(req.NoteIDs),
+	})
+}
+
+// bindBulkTagRequest parses a bulk tag request and checks that the caller owns
+// every tag and note in it. It writes the error response and returns false
+// otherwise.
+func bindBulkTagRequest(c *gin.Context) (BulkTagRequest, bool) {
+	user, _ := middleware.CurrentUser(c)
+
+	var req BulkTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	req.NoteIDs = uniqueIDs(req.NoteIDs)
+	req.TagIDs = uniqueIDs(req.TagIDs)
+
+	var tagCount int64
+	config.DB.Model(&models.Tag{}).Where("tag_id IN ? AND owner_id = ?", req.TagIDs, user.UserID).Count(&tagCount)
+	if int(tagCount) != len(req.TagIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "One or more tags not found"})
+		return req, false
+	}
+
+	var noteCount int64
+	config.DB.Model(&models.Note{}).Where("note_id IN ? AND owner_id = ?", req.NoteIDs, user.UserID).Count(&noteCount)
+	if int(noteCount) != len(req.NoteIDs) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only notes you own can be tagged"})
+		return req, false
+	}
+	return req, true
+}
+
+// findOwnTag loads one of the caller's tags by id. It writes the error
+// response and returns false otherwise.
+func findOwnTag(c *gin.Context, tagIDStr string) (models.Tag, bool) {
+	user, _ := middleware.CurrentUser(c)
+
+	var tag models.Tag
+	tagID, err := strconv.ParseUint(tagIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+		return tag, false
+	}
+
+	if err := config.DB.Where("owner_id = ?", user.UserID).First(&tag, tagID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return tag, false
+	}
+	return tag, true
+}
+
+// loadNoteTags returns the tag names of each note, sorted by name
+func loadNoteTags(noteIDs []uint) map[uint][]string {
+	tags := make(map[uint][]string, len(noteIDs))
+	if len(noteIDs) == 0 {
+		return tags
+	}
+
+	var rows []struct {
+		NoteID uint
+		Name   string
+	}
+	config.DB.Table("note_tags").
+		Select("note_tags.note_id, tags.name").
+		Joins("JOIN tags ON tags.tag_id = note_tags.tag_id").
+		Where("note_tags.note_id IN ?", noteIDs).
+		Order("tags.name").
+		Scan(&rows)
+
+	for _, row := range rows {
+		tags[row.NoteID] = append(tags[row.NoteID], row.Name)
+	}
+	return tags
+}
+
+// attachNoteTags fills in the tags of each note in an asset listing
+func attachNoteTags(notes []NoteWithAccess) {
+	noteIDs := make([]uint, 0, len(notes))
+	for _, note := range notes {
+		noteIDs = append(noteIDs, note.NoteID)
+	}
+
+	tags := loadNoteTags(noteIDs)
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].NoteID]
+		if notes[i].Tags == nil {
+			notes[i].Tags = []string{}
+		}
+	}
+}
+
+// applyNoteTags creates any missing tags for the owner and applies them to a
+// note, inside the given transaction
+func applyNoteTags(tx *gorm.DB, ownerID, noteID uint, names []string) error {
+	for _, name := range names {
+		name = normalizeTagName(name)
+		if name == "" {
+			continue
+		}
+
+		tag := models.Tag{OwnerID: ownerID, Name: name}
+		if err := tx.Where(models.Tag{OwnerID: ownerID, Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		link := models.NoteTag{NoteID: noteID, TagID: tag.TagID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagFilter restricts note queries to notes carrying some or all of a set of
+// tag names
+type tagFilter struct {
+	Names []string
+	Mode  string
+}
+
+// parseTagFilter reads ?tags=a,b&tagMode=all|any. The default mode is all.
+func parseTagFilter(c *gin.Context) (tagFilter, error) {
+	filter := tagFilter{Mode: c.DefaultQuery("tagMode", TagMatchAll)}
+	if filter.Mode != TagMatchAll && filter.Mode != TagMatchAny {
+		return filter, errors.New("tagMode must be all or any")
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		name = normalizeTagName(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			filter.Names = append(filter.Names, name)
+		}
+	}
+	sort.Strings(filter.Names)
+	return filter, nil
+}
+
+// active reports whether the filter restricts anything
+func (f tagFilter) active() bool {
+	return len(f.Names) > 0
+}
+
+// apply adds the filter to a query over notes, where column holds the note id
+func (f tagFilter) apply(query *gorm.DB, column string) *gorm.DB {
+	if !f.active() {
+		return query
+	}
+
+	sub := config.DB.Table("note_tags").
+		Select("note_tags.note_id").
+		Joins("JOIN tags ON tags.tag_id = note_tags.tag_id").
+		Where("tags.name IN ?", f.Names)
+	if f.Mode == TagMatchAll {
+		sub = sub.Group("note_tags.note_id").Having("COUNT(DISTINCT tags.name) = ?", len(f.Names))
+	}
+	return query.Where(column+" IN (?)", sub)
+}
+
+// uniqueIDs removes duplicate ids, keeping their order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
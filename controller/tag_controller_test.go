@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+func TestTagNameRaceIsAConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		// register adds a callback to the create or update chain
+		register func(db *gorm.DB, name string, fn func(*gorm.DB)) error
+	}{
+		{"creating", http.MethodPost, func(db *gorm.DB, name string, fn func(*gorm.DB)) error {
+			return db.Callback().Create().Before("gorm:create").Register(name, fn)
+		}},
+		{"renaming", http.MethodPut, func(db *gorm.DB, name string, fn func(*gorm.DB)) error {
+			return db.Callback().Update().Before("gorm:update").Register(name, fn)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			home := models.Tag{OwnerID: testOwner, Name: "home"}
+			if err := db.Create(&home).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// Another request takes the name between the handler's check and its write
+			raced := false
+			err := tt.register(db, "test:race", func(tx *gorm.DB) {
+				if raced || tx.Statement.Table != "tags" {
+					return
+				}
+				raced = true
+				if err := tx.Session(&gorm.Session{NewDB: true}).Create(&models.Tag{OwnerID: testOwner, Name: "work"}).Error; err != nil {
+					t.Error(err)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router := testRouter()
+			router.POST("/tags", middleware.RequireAuth(), CreateTag)
+			router.PUT("/tags/:tagId", middleware.RequireAuth(), RenameTag)
+			path := "/tags"
+			if tt.method == http.MethodPut {
+				path = fmt.Sprintf("/tags/%d", home.TagID)
+			}
+			status, response := testRequest(t, router, testOwner, tt.method, path, gin.H{"name": "Work"})
+			if !raced {
+				t.Fatal("the handler did not write the tag")
+			}
+			if status != http.StatusConflict {
+				t.Fatalf("%s returned %d: %v", tt.name, status, response)
+			}
+		})
+	}
+}
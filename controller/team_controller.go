@@ -2,18 +2,24 @@ package controller
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
 )
 
 // CreateTeamRequest represents the request structure for creating a team
 type CreateTeamRequest struct {
-	TeamName string               `json:"teamName" binding:"required"`
-	Managers []TeamManagerRequest `json:"managers"`
-	Members  []TeamMemberRequest  `json:"members"`
+	TeamName     string               `json:"teamName" binding:"required"`
+	ParentTeamID *uint                `json:"parentTeamId"`
+	MaxMembers   *int                 `json:"maxMembers" binding:"omitempty,min=1"`
+	Managers     []TeamManagerRequest `json:"managers"`
+	Members      []TeamMemberRequest  `json:"members"`
 }
 
 type TeamManagerRequest struct {
@@ -26,6 +32,52 @@ type TeamMemberRequest struct {
 	MemberName string `json:"memberName" binding:"required"`
 }
 
+// UpdateTeamRequest represents the request structure for renaming a team
+type UpdateTeamRequest struct {
+	TeamName string `json:"teamName" binding:"required"`
+}
+
+// What DeleteTeam does with the team's memberships
+const (
+	// TeamMembersRemove ends every membership and management role
+	TeamMembersRemove = "remove"
+	// TeamMembersMove carries memberships and management roles over to
+	// another team
+	TeamMembersMove = "move"
+)
+
+// What DeleteTeam does with the assets in the team's asset report
+const (
+	// TeamAssetsKeep leaves every asset with its owner
+	TeamAssetsKeep = "keep"
+	// TeamAssetsTransfer gives the folders and notes owned by members to
+	// another user. The previous owners keep write access through a share.
+	TeamAssetsTransfer = "transfer"
+)
+
+// DeleteTeamRequest holds the query options of a team deletion
+type DeleteTeamRequest struct {
+	Members      string `form:"members" binding:"omitempty,oneof=remove move"`
+	TargetTeamID uint   `form:"targetTeamId" binding:"required_if=Members move"`
+	Assets       string `form:"assets" binding:"omitempty,oneof=keep transfer"`
+	AssetOwnerID uint   `form:"assetOwnerId" binding:"required_if=Assets transfer"`
+	RevokeShares bool   `form:"revokeShares"` // revoke the shares granted on behalf of the team
+}
+
+// TeamSummary is a team with its headcount
+type TeamSummary struct {
+	models.Team
+	MemberCount  int64 `json:"memberCount"`
+	ManagerCount int64 `json:"managerCount"`
+}
+
+// TeamPatch is the document a team PATCH applies to
+type TeamPatch struct {
+	TeamName     string `json:"teamName" binding:"required"`
+	ParentTeamID *uint  `json:"parentTeamId"`
+	MaxMembers   *int   `json:"maxMembers" binding:"omitempty,min=1"`
+}
+
 // AddMemberRequest represents the request for adding a member to a team
 type AddMemberRequest struct {
 	UserID uint `json:"userId" binding:"required"`
@@ -36,7 +88,12 @@ type AddManagerRequest struct {
 	UserID uint `json:"userId" binding:"required"`
 }
 
-// CreateTeam creates a new team with managers and members
+// CreateTeam creates a new team with managers and members. A team needs at
+// least one manager unless an admin passes ?force=true. Managers count as
+// members, so users listed as both are only added as managers. Nesting the
+// team under a parent needs the right to manage the parent. Only admins add
+// the listed users directly: other callers become the team's manager and the
+// users they list are invited, and reported as "invited".
 func CreateTeam(c *gin.Context) {
 	var req CreateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -44,6 +101,15 @@ func CreateTeam(c *gin.Context) {
 		return
 	}
 
+	caller, _ := middleware.CurrentUser(c)
+	direct := caller.Role == models.RoleAdmin
+	if req.ParentTeamID != nil {
+		if err := checkTeamParent(config.DB, 0, *req.ParentTeamID, caller); err != nil {
+			respondActionError(c, err)
+			return
+		}
+	}
+
 	// Start a transaction
 	tx := config.DB.Begin()
 	defer func() {
@@ -54,7 +120,9 @@ func CreateTeam(c *gin.Context) {
 
 	// Create the team
 	team := models.Team{
-		TeamName: req.TeamName,
+		TeamName:     req.TeamName,
+		ParentTeamID: req.ParentTeamID,
+		MaxMembers:   req.MaxMembers,
 	}
 
 	if err := tx.Create(&team).Error; err != nil {
@@ -64,6 +132,16 @@ func CreateTeam(c *gin.Context) {
 	}
 
 	// Add managers
+	managerIDs := make(map[uint64]bool, len(req.Managers))
+	var invitedManagers, invitedMembers []uint
+	if !direct {
+		if err := tx.Create(&models.TeamManager{UserID: caller.UserID, TeamID: team.TeamID}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add manager"})
+			return
+		}
+		managerIDs[uint64(caller.UserID)] = true
+	}
 	for _, managerReq := range req.Managers {
 		userID, err := strconv.ParseUint(managerReq.ManagerID, 10, 32)
 		if err != nil {
@@ -79,6 +157,14 @@ func CreateTeam(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Manager not found"})
 			return
 		}
+		if managerIDs[userID] {
+			continue
+		}
+		managerIDs[userID] = true
+		if !direct {
+			invitedManagers = append(invitedManagers, uint(userID))
+			continue
+		}
 
 		teamManager := models.TeamManager{
 			UserID: uint(userID),
@@ -108,6 +194,13 @@ func CreateTeam(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
 			return
 		}
+		if managerIDs[userID] {
+			continue
+		}
+		if !direct {
+			invitedMembers = append(invitedMembers, uint(userID))
+			continue
+		}
 
 		teamMember := models.TeamMember{
 			UserID: uint(userID),
@@ -121,6 +214,29 @@ func CreateTeam(c *gin.Context) {
 		}
 	}
 
+	if !overrideTeamRules(c) {
+		if err := checkTeamManagers(tx, team.TeamID); err != nil {
+			tx.Rollback()
+			respondActionError(c, err)
+			return
+		}
+	}
+	if err := checkTeamCap(tx, team); err != nil {
+		tx.Rollback()
+		respondActionError(c, err)
+		return
+	}
+	if err := inviteUsers(tx, team, models.TeamRoleManager, caller.UserID, invitedManagers); err != nil {
+		tx.Rollback()
+		respondActionError(c, err)
+		return
+	}
+	if err := inviteUsers(tx, team, models.TeamRoleMember, caller.UserID, invitedMembers); err != nil {
+		tx.Rollback()
+		respondActionError(c, err)
+		return
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
@@ -134,10 +250,536 @@ func CreateTeam(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Team created successfully",
 		"team":    createdTeam,
+		"invited": gin.H{
+			"managers": loadUsers(invitedManagers),
+			"members":  loadUsers(invitedMembers),
+		},
+	})
+}
+
+// ListTeams lists teams with their headcount. Archived teams are hidden
+// unless ?archived=true includes them or ?archived=only lists only them.
+func ListTeams(c *gin.Context) {
+	query := config.DB.Model(&models.Team{}).Select("teams.*, " +
+		"(SELECT COUNT(*) FROM team_members WHERE team_members.team_id = teams.team_id) AS member_count, " +
+		"(SELECT COUNT(*) FROM team_managers WHERE team_managers.team_id = teams.team_id) AS manager_count")
+
+	switch c.Query("archived") {
+	case "", "false":
+		query = query.Where("archived_at IS NULL")
+	case "only":
+		query = query.Where("archived_at IS NOT NULL")
+	case "true":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true, false or only"})
+		return
+	}
+
+	teams := []TeamSummary{}
+	if err := query.Order("team_name").Scan(&teams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"teams": teams})
+}
+
+// GetTeam retrieves a team with its managers and members
+func GetTeam(c *gin.Context) {
+	teamIDStr := c.Param("teamId")
+	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	var team models.Team
+	if err := config.DB.First(&team, teamID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	var managers []models.TeamManager
+	config.DB.Preload("User").Where("team_id = ?", team.TeamID).Find(&managers)
+
+	var members []models.TeamMember
+	config.DB.Preload("User").Where("team_id = ?", team.TeamID).Find(&members)
+
+	c.JSON(http.StatusOK, gin.H{
+		"team":     team,
+		"managers": managers,
+		"members":  members,
+	})
+}
+
+// UpdateTeam renames a team
+func UpdateTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
+		return
+	}
+
+	var req UpdateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Model(&team).Update("team_name", req.TeamName).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team updated successfully",
+		"team":    team,
+	})
+}
+
+// ArchiveTeam makes a team read-only and hides it from default listings
+func ArchiveTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+	if team.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Team is already archived"})
+		return
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(&team).Update("archived_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive team"})
+		return
+	}
+	team.ArchivedAt = &now
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team archived successfully",
+		"team":    team,
+	})
+}
+
+// UnarchiveTeam restores an archived team
+func UnarchiveTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+	if team.ArchivedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Team is not archived"})
+		return
+	}
+
+	if err := config.DB.Model(&team).Update("archived_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unarchive team"})
+		return
+	}
+	team.ArchivedAt = nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team unarchived successfully",
+		"team":    team,
+	})
+}
+
+// DeleteTeam deletes a team. ?members=remove|move&targetTeamId= chooses what
+// happens to its memberships and ?assets=keep|transfer&assetOwnerId= what
+// happens to the folders and notes its members own. Moving members needs the
+// right to manage the target team, and only admins can transfer the assets to
+// someone outside the team. Its sub-teams move up to its parent.
+func DeleteTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+
+	var req DeleteTeamRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Members == "" {
+		req.Members = TeamMembersRemove
+	}
+	if req.Assets == "" {
+		req.Assets = TeamAssetsKeep
+	}
+
+	// The asset report covers the assets of the team's members and managers
+	memberIDs := teamUserIDs(config.DB, team.TeamID)
+	caller, _ := middleware.CurrentUser(c)
+
+	var target models.Team
+	if req.Members == TeamMembersMove {
+		if err := config.DB.First(&target, req.TargetTeamID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target team not found"})
+			return
+		}
+		if target.TeamID == team.TeamID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Members cannot be moved to the team being deleted"})
+			return
+		}
+		if !canManageTeam(caller, target.TeamID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and managers of the target team can move members into it"})
+			return
+		}
+		if rejectArchivedTeam(c, target) {
+			return
+		}
+	}
+	if req.Assets == TeamAssetsTransfer {
+		var owner models.User
+		if err := config.DB.First(&owner, req.AssetOwnerID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset owner not found"})
+			return
+		}
+		// Managers can only hand the assets to someone in the team they manage
+		if caller.Role != models.RoleAdmin && !slices.Contains(memberIDs, owner.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can transfer assets to a user outside the team"})
+			return
+		}
+	}
+
+	var moved, folders, notes int
+	var subTeams, folderShares, noteShares int64
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		if req.Assets == TeamAssetsTransfer {
+			var err error
+			if folders, notes, err = transferOwnedAssets(tx, memberIDs, req.AssetOwnerID, true); err != nil {
+				return err
+			}
+		}
+
+		if req.Members == TeamMembersMove {
+			var err error
+			if moved, err = moveTeamRoles(tx, team.TeamID, req.TargetTeamID); err != nil {
+				return err
+			}
+			if err := checkTeamCap(tx, target); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("team_id = ?", team.TeamID).Delete(&models.TeamMember{}).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to remove members")
+		}
+		if err := tx.Where("team_id = ?", team.TeamID).Delete(&models.TeamManager{}).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to remove managers")
+		}
+		if req.RevokeShares {
+			var err error
+			if folderShares, noteShares, err = revokeLeaversShares(tx, team.TeamID, memberIDs); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.TeamInvitation{}).
+			Where("team_id = ? AND status = ?", team.TeamID, models.InvitationPending).
+			Update("status", models.InvitationCancelled).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to cancel invitations")
+		}
+
+		reparent := tx.Model(&models.Team{}).Where("parent_team_id = ?", team.TeamID).Update("parent_team_id", team.ParentTeamID)
+		if reparent.Error != nil {
+			return failAction(http.StatusInternalServerError, "Failed to move sub-teams")
+		}
+		subTeams = reparent.RowsAffected
+
+		if err := tx.Delete(&team).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to delete team")
+		}
+		return nil
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+	if req.RevokeShares {
+		forgetLeaversAssets(memberIDs)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Team deleted successfully",
+		"members":  gin.H{"action": req.Members, "moved": moved},
+		"revoked":  gin.H{"folderShares": folderShares, "noteShares": noteShares},
+		"assets":   gin.H{"action": req.Assets, "folders": folders, "notes": notes},
+		"subTeams": gin.H{"moved": subTeams, "parentTeamId": team.ParentTeamID},
+	})
+}
+
+// moveTeamRoles copies the memberships and management roles of one team to
+// another, skipping users who already hold them there. Members who manage the
+// other team stay managers, and managers who are members there are promoted.
+// It returns how many were copied.
+func moveTeamRoles(tx *gorm.DB, fromTeamID, toTeamID uint) (int, error) {
+	moved := 0
+
+	var members []models.TeamMember
+	if err := tx.Where("team_id = ?", fromTeamID).Find(&members).Error; err != nil {
+		return 0, failAction(http.StatusInternalServerError, "Failed to get team members")
+	}
+	for _, member := range members {
+		if hasTeamRole(tx, toTeamID, member.UserID, models.TeamRoleMember) ||
+			hasTeamRole(tx, toTeamID, member.UserID, models.TeamRoleManager) {
+			continue
+		}
+		if err := tx.Create(&models.TeamMember{UserID: member.UserID, TeamID: toTeamID}).Error; err != nil {
+			return 0, failAction(http.StatusInternalServerError, "Failed to move members")
+		}
+		moved++
+	}
+
+	var managers []models.TeamManager
+	if err := tx.Where("team_id = ?", fromTeamID).Find(&managers).Error; err != nil {
+		return 0, failAction(http.StatusInternalServerError, "Failed to get team managers")
+	}
+	for _, manager := range managers {
+		if hasTeamRole(tx, toTeamID, manager.UserID, models.TeamRoleManager) {
+			continue
+		}
+		if err := removeTeamRole(tx, toTeamID, manager.UserID, models.TeamRoleMember); err != nil {
+			return 0, err
+		}
+		if err := tx.Create(&models.TeamManager{UserID: manager.UserID, TeamID: toTeamID}).Error; err != nil {
+			return 0, failAction(http.StatusInternalServerError, "Failed to move managers")
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// transferOwnedAssets gives the folders and notes owned by the given users to
+// a new owner. With keepAccess each previous owner keeps write access through
+// a share. Shares the new owner held on those assets are dropped.
+func transferOwnedAssets(tx *gorm.DB, ownerIDs []uint, newOwnerID uint, keepAccess bool) (folders, notes int, err error) {
+	var previous []uint
+	for _, id := range uniqueIDs(ownerIDs) {
+		if id != newOwnerID {
+			previous = append(previous, id)
+		}
+	}
+	if len(previous) == 0 {
+		return 0, 0, nil
+	}
+
+	var ownedFolders []models.Folder
+	if err := tx.Where("owner_id IN ?", previous).Find(&ownedFolders).Error; err != nil {
+		return 0, 0, failAction(http.StatusInternalServerError, "Failed to get folders")
+	}
+	for _, folder := range ownedFolders {
+		if err := transferFolder(tx, folder, newOwnerID, keepAccess); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var ownedNotes []models.Note
+	if err := tx.Where("owner_id IN ?", previous).Find(&ownedNotes).Error; err != nil {
+		return 0, 0, failAction(http.StatusInternalServerError, "Failed to get notes")
+	}
+	for _, note := range ownedNotes {
+		if err := transferNote(tx, note, newOwnerID, keepAccess); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(ownedFolders), len(ownedNotes), nil
+}
+
+// PatchTeam changes only the fields named in a JSON Merge Patch or JSON Patch
+// and reports which fields changed. Only admins and the team's managers can
+// change a team.
+func PatchTeam(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
+		return
+	}
+
+	var patched TeamPatch
+	current := TeamPatch{TeamName: team.TeamName, ParentTeamID: team.ParentTeamID, MaxMembers: team.MaxMembers}
+	if err := applyPatch(c, current, &patched); err != nil {
+		respondActionError(c, err)
+		return
+	}
+
+	changed := []string{}
+	updates := map[string]interface{}{}
+	if patched.TeamName != team.TeamName {
+		updates["team_name"] = patched.TeamName
+		changed = append(changed, "teamName")
+	}
+	if !sameID(patched.ParentTeamID, team.ParentTeamID) {
+		// Moving a team out of a parent needs the right to manage that parent
+		caller, _ := middleware.CurrentUser(c)
+		if team.ParentTeamID != nil && !canManageTeam(caller, *team.ParentTeamID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and managers of the parent team can move this team"})
+			return
+		}
+		if patched.ParentTeamID != nil {
+			if err := checkTeamParent(config.DB, team.TeamID, *patched.ParentTeamID, caller); err != nil {
+				respondActionError(c, err)
+				return
+			}
+		}
+		updates["parent_team_id"] = patched.ParentTeamID
+		changed = append(changed, "parentTeamId")
+	}
+	if !sameCap(patched.MaxMembers, team.MaxMembers) {
+		// A cap cannot be set below the team's current headcount
+		if err := checkTeamCap(config.DB, models.Team{TeamID: team.TeamID, MaxMembers: patched.MaxMembers}); err != nil {
+			respondActionError(c, err)
+			return
+		}
+		updates["max_members"] = patched.MaxMembers
+		changed = append(changed, "maxMembers")
+	}
+
+	if len(updates) > 0 {
+		if err := config.DB.Model(&team).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+			return
+		}
+		team.ParentTeamID = patched.ParentTeamID
+		team.MaxMembers = patched.MaxMembers
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team updated successfully",
+		"team":    team,
+		"changed": changed,
 	})
 }
 
-// AddMemberToTeam adds a member to an existing team
+// sameCap reports whether two member caps are equal, nil meaning no cap
+func sameCap(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameID reports whether two optional ids are equal
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// findManagedTeam loads the team named by the teamId parameter and checks
+// that the caller can manage it. It writes the error response and returns
+// false otherwise.
+func findManagedTeam(c *gin.Context) (models.Team, bool) {
+	teamID, err := strconv.ParseUint(c.Param("teamId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return models.Team{}, false
+	}
+
+	var team models.Team
+	if err := config.DB.First(&team, teamID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return team, false
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	if !canManageTeam(user, team.TeamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and team managers can change this team"})
+		return team, false
+	}
+	return team, true
+}
+
+// rejectArchivedTeam writes a conflict response and returns true when the
+// team is archived and therefore read-only
+func rejectArchivedTeam(c *gin.Context, team models.Team) bool {
+	if team.ArchivedAt == nil {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Team is archived; unarchive it first"})
+	return true
+}
+
+// hasTeamRole reports whether the user is a member or manager of the team
+func hasTeamRole(db *gorm.DB, teamID, userID uint, role string) bool {
+	var count int64
+	if role == models.TeamRoleManager {
+		db.Model(&models.TeamManager{}).Where("user_id = ? AND team_id = ?", userID, teamID).Count(&count)
+	} else {
+		db.Model(&models.TeamMember{}).Where("user_id = ? AND team_id = ?", userID, teamID).Count(&count)
+	}
+	return count > 0
+}
+
+// addTeamRole makes the user a member or manager of the team. Managers count
+// as members: making a member a manager promotes them, and a manager cannot
+// also be added as a member. The team's member cap is respected.
+func addTeamRole(db *gorm.DB, team models.Team, userID uint, role string) error {
+	teamID := team.TeamID
+	if hasTeamRole(db, teamID, userID, models.TeamRoleManager) {
+		return failRule(http.StatusConflict, TeamAlreadyManager, "User is already a manager of this team")
+	}
+	isMember := hasTeamRole(db, teamID, userID, models.TeamRoleMember)
+	if isMember && role == models.TeamRoleMember {
+		return failRule(http.StatusConflict, TeamAlreadyMember, "User is already a member of this team")
+	}
+
+	// Only newcomers raise the headcount
+	if !isMember && team.MaxMembers != nil {
+		if err := lockTeam(db, teamID); err != nil {
+			return err
+		}
+		if teamHeadcount(db, teamID) >= int64(*team.MaxMembers) {
+			return memberCapError(*team.MaxMembers)
+		}
+	}
+
+	if role == models.TeamRoleManager {
+		if isMember {
+			if err := removeTeamRole(db, teamID, userID, models.TeamRoleMember); err != nil {
+				return err
+			}
+		}
+		if err := db.Create(&models.TeamManager{UserID: userID, TeamID: teamID}).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to add manager")
+		}
+		return nil
+	}
+	if err := db.Create(&models.TeamMember{UserID: userID, TeamID: teamID}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to add member")
+	}
+	return nil
+}
+
+// removeTeamRole ends a user's membership or management role in the team
+func removeTeamRole(db *gorm.DB, teamID, userID uint, role string) error {
+	if role == models.TeamRoleManager {
+		if err := db.Where("user_id = ? AND team_id = ?", userID, teamID).Delete(&models.TeamManager{}).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to remove manager")
+		}
+		return nil
+	}
+	if err := db.Where("user_id = ? AND team_id = ?", userID, teamID).Delete(&models.TeamMember{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to remove member")
+	}
+	return nil
+}
+
+// canManageTeam reports whether the user is an admin or manages the team or
+// one of its parent teams
+func canManageTeam(user models.User, teamID uint) bool {
+	if user.Role == models.RoleAdmin {
+		return true
+	}
+	if user.UserID == 0 {
+		return false
+	}
+	teamIDs := append([]uint{teamID}, teamAncestorIDs(config.DB, teamID)...)
+	var count int64
+	config.DB.Model(&models.TeamManager{}).Where("user_id = ? AND team_id IN ?", user.UserID, teamIDs).Count(&count)
+	return count > 0
+}
+
+// AddMemberToTeam adds a member to an existing team without an invitation.
+// Only admins can do this; managers invite users instead.
 func AddMemberToTeam(c *gin.Context) {
 	teamIDStr := c.Param("teamId")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
@@ -146,6 +788,12 @@ func AddMemberToTeam(c *gin.Context) {
 		return
 	}
 
+	caller, _ := middleware.CurrentUser(c)
+	if caller.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can add members directly; invite the user instead"})
+		return
+	}
+
 	var req AddMemberRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -158,6 +806,9 @@ func AddMemberToTeam(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
+	if rejectArchivedTeam(c, team) {
+		return
+	}
 
 	// Check if user exists
 	var user models.User
@@ -166,70 +817,74 @@ func AddMemberToTeam(c *gin.Context) {
 		return
 	}
 
-	// Check if user is already a member
-	var existingMember models.TeamMember
-	if err := config.DB.Where("user_id = ? AND team_id = ?", req.UserID, teamID).First(&existingMember).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this team"})
-		return
-	}
-
 	// Add the member
-	teamMember := models.TeamMember{
-		UserID: req.UserID,
-		TeamID: uint(teamID),
-	}
-
-	if err := config.DB.Create(&teamMember).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return addTeamRole(tx, team, req.UserID, models.TeamRoleMember)
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
+	var teamMember models.TeamMember
+	config.DB.Where("user_id = ? AND team_id = ?", req.UserID, team.TeamID).First(&teamMember)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Member added successfully",
 		"member":  teamMember,
 	})
 }
 
-// RemoveMemberFromTeam removes a member from a team
+// RemoveMemberFromTeam removes a member from a team. ?revokeShares=true also
+// revokes the shares the member was granted on behalf of the team.
 func RemoveMemberFromTeam(c *gin.Context) {
-	teamIDStr := c.Param("teamId")
-	memberIDStr := c.Param("memberId")
-
-	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
 		return
 	}
 
-	memberID, err := strconv.ParseUint(memberIDStr, 10, 32)
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
 		return
 	}
 
-	// Check if team exists
-	var team models.Team
-	if err := config.DB.First(&team, teamID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
-		return
-	}
-
 	// Find and delete the team member relationship
 	var teamMember models.TeamMember
-	if err := config.DB.Where("user_id = ? AND team_id = ?", memberID, teamID).First(&teamMember).Error; err != nil {
+	if err := config.DB.Where("user_id = ? AND team_id = ?", memberID, team.TeamID).First(&teamMember).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found in team"})
 		return
 	}
 
-	if err := config.DB.Delete(&teamMember).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+	revokeShares := revokeSharesRequested(c)
+	var folderShares, noteShares int64
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := tx.Delete(&teamMember).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to remove member")
+		}
+		if !revokeShares {
+			return nil
+		}
+		var err error
+		folderShares, noteShares, err = revokeTeamGrantedShares(tx, team.TeamID, teamMember.UserID)
+		return err
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
+	if revokeShares {
+		forgetLostAssets(teamMember.UserID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+		"revoked": gin.H{"folderShares": folderShares, "noteShares": noteShares},
+	})
 }
 
-// AddManagerToTeam adds a manager to an existing team
+// AddManagerToTeam adds a manager to an existing team without an invitation.
+// Only admins can do this; managers invite users instead.
 func AddManagerToTeam(c *gin.Context) {
 	teamIDStr := c.Param("teamId")
 	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
@@ -238,6 +893,12 @@ func AddManagerToTeam(c *gin.Context) {
 		return
 	}
 
+	caller, _ := middleware.CurrentUser(c)
+	if caller.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can add managers directly; invite the user instead"})
+		return
+	}
+
 	var req AddManagerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -250,6 +911,9 @@ func AddManagerToTeam(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
+	if rejectArchivedTeam(c, team) {
+		return
+	}
 
 	// Check if user exists
 	var user models.User
@@ -258,65 +922,77 @@ func AddManagerToTeam(c *gin.Context) {
 		return
 	}
 
-	// Check if user is already a manager
-	var existingManager models.TeamManager
-	if err := config.DB.Where("user_id = ? AND team_id = ?", req.UserID, teamID).First(&existingManager).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User is already a manager of this team"})
-		return
-	}
-
 	// Add the manager
-	teamManager := models.TeamManager{
-		UserID: req.UserID,
-		TeamID: uint(teamID),
-	}
-
-	if err := config.DB.Create(&teamManager).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add manager"})
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return addTeamRole(tx, team, req.UserID, models.TeamRoleManager)
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
 
+	var teamManager models.TeamManager
+	config.DB.Where("user_id = ? AND team_id = ?", req.UserID, team.TeamID).First(&teamManager)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Manager added successfully",
 		"manager": teamManager,
 	})
 }
 
-// RemoveManagerFromTeam removes a manager from a team
+// RemoveManagerFromTeam removes a manager from a team. A team keeps at least
+// one manager unless an admin passes ?force=true. Managers count as members,
+// so ?revokeShares=true revokes their team-granted shares as it does for
+// members.
 func RemoveManagerFromTeam(c *gin.Context) {
-	teamIDStr := c.Param("teamId")
-	managerIDStr := c.Param("managerId")
-
-	teamID, err := strconv.ParseUint(teamIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+	team, ok := findManagedTeam(c)
+	if !ok || rejectArchivedTeam(c, team) {
 		return
 	}
 
-	managerID, err := strconv.ParseUint(managerIDStr, 10, 32)
+	managerID, err := strconv.ParseUint(c.Param("managerId"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid manager ID"})
 		return
 	}
 
-	// Check if team exists
-	var team models.Team
-	if err := config.DB.First(&team, teamID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
-		return
-	}
-
 	// Find and delete the team manager relationship
 	var teamManager models.TeamManager
-	if err := config.DB.Where("user_id = ? AND team_id = ?", managerID, teamID).First(&teamManager).Error; err != nil {
+	if err := config.DB.Where("user_id = ? AND team_id = ?", managerID, team.TeamID).First(&teamManager).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Manager not found in team"})
 		return
 	}
 
-	if err := config.DB.Delete(&teamManager).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove manager"})
+	// The last manager can only be removed by an admin with ?force=true
+	override := overrideTeamRules(c)
+	revokeShares := revokeSharesRequested(c)
+	var folderShares, noteShares int64
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := tx.Delete(&teamManager).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to remove manager")
+		}
+		if !override {
+			if err := checkTeamManagers(tx, team.TeamID); err != nil {
+				return err
+			}
+		}
+		if !revokeShares {
+			return nil
+		}
+		var err error
+		folderShares, noteShares, err = revokeTeamGrantedShares(tx, team.TeamID, teamManager.UserID)
+		return err
+	})
+	if err != nil {
+		respondActionError(c, err)
 		return
 	}
+	if revokeShares {
+		forgetLostAssets(teamManager.UserID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Manager removed successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Manager removed successfully",
+		"revoked": gin.H{"folderShares": folderShares, "noteShares": noteShares},
+	})
 }
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+// createTestTeam creates a team managed by testManager with the given members
+func createTestTeam(t testing.TB, name string, memberIDs ...uint) models.Team {
+	t.Helper()
+	team := models.Team{TeamName: name}
+	if err := config.DB.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.TeamManager{UserID: testManager, TeamID: team.TeamID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, memberID := range memberIDs {
+		if err := config.DB.Create(&models.TeamMember{UserID: memberID, TeamID: team.TeamID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return team
+}
+
+// shareViaTeam shares a folder with a user on behalf of a team
+func shareViaTeam(t *testing.T, folder models.Folder, userID uint, team models.Team) {
+	t.Helper()
+	share := models.FolderShare{FolderID: folder.FolderID, UserID: userID, Access: AccessRead, GrantedViaTeamID: &team.TeamID}
+	if err := config.DB.Create(&share).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := refreshFolderAccess(config.DB, folder.FolderID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateTeamInvitesListedUsers(t *testing.T) {
+	setupTestDB(t)
+	router := testRouter()
+	router.POST("/teams", middleware.RequireAuth(), CreateTeam)
+	body := gin.H{
+		"teamName": "core",
+		"managers": []gin.H{{"managerId": strconv.Itoa(int(testReader)), "managerName": "reader"}},
+		"members":  []gin.H{{"memberId": strconv.Itoa(int(testManager)), "memberName": "manager"}},
+	}
+
+	if status, _ := testRequest(t, router, 0, http.MethodPost, "/teams", body); status != http.StatusUnauthorized {
+		t.Fatalf("anonymous create returned %d, want 401", status)
+	}
+
+	status, response := testRequest(t, router, testOwner, http.MethodPost, "/teams", body)
+	if status != http.StatusCreated {
+		t.Fatalf("create returned %d: %v", status, response)
+	}
+	var team models.Team
+	config.DB.Where("team_name = ?", "core").First(&team)
+	if !hasTeamRole(config.DB, team.TeamID, testOwner, models.TeamRoleManager) {
+		t.Error("the caller does not manage the new team")
+	}
+	if hasTeamRole(config.DB, team.TeamID, testReader, models.TeamRoleManager) ||
+		hasTeamRole(config.DB, team.TeamID, testManager, models.TeamRoleMember) {
+		t.Error("listed users were enrolled without an invitation")
+	}
+	var invitations int64
+	config.DB.Model(&models.TeamInvitation{}).Where("team_id = ? AND status = ?", team.TeamID, models.InvitationPending).Count(&invitations)
+	if invitations != 2 {
+		t.Fatalf("%d invitations, want 2", invitations)
+	}
+
+	body["teamName"] = "platform"
+	if status, response := testRequest(t, router, testAdmin, http.MethodPost, "/teams", body); status != http.StatusCreated {
+		t.Fatalf("admin create returned %d: %v", status, response)
+	}
+	team = models.Team{}
+	config.DB.Where("team_name = ?", "platform").First(&team)
+	if !hasTeamRole(config.DB, team.TeamID, testReader, models.TeamRoleManager) ||
+		!hasTeamRole(config.DB, team.TeamID, testManager, models.TeamRoleMember) {
+		t.Fatal("admin create did not enrol the listed users")
+	}
+}
+
+func TestRemovingTeamRolesRequiresAManager(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testReader)
+
+	router := testRouter()
+	router.DELETE("/teams/:teamId/members/:memberId", middleware.RequireAuth(), RemoveMemberFromTeam)
+	router.DELETE("/teams/:teamId/managers/:managerId", middleware.RequireAuth(), RemoveManagerFromTeam)
+
+	for _, path := range []string{
+		fmt.Sprintf("/teams/%d/members/%d", team.TeamID, testReader),
+		fmt.Sprintf("/teams/%d/managers/%d", team.TeamID, testManager),
+	} {
+		if status, _ := testRequest(t, router, 0, http.MethodDelete, path, nil); status != http.StatusUnauthorized {
+			t.Errorf("anonymous DELETE %s returned %d, want 401", path, status)
+		}
+		if status, _ := testRequest(t, router, testOwner, http.MethodDelete, path, nil); status != http.StatusForbidden {
+			t.Errorf("non-manager DELETE %s returned %d, want 403", path, status)
+		}
+	}
+	if !hasTeamRole(config.DB, team.TeamID, testReader, models.TeamRoleMember) ||
+		!hasTeamRole(config.DB, team.TeamID, testManager, models.TeamRoleManager) {
+		t.Fatal("rejected requests removed team roles")
+	}
+
+	status, response := testRequest(t, router, testManager, http.MethodDelete, fmt.Sprintf("/teams/%d/members/%d", team.TeamID, testReader), nil)
+	if status != http.StatusOK {
+		t.Fatalf("manager removing a member returned %d: %v", status, response)
+	}
+}
+
+func TestRemovingManagerRevokesTeamShares(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core")
+	config.DB.Create(&models.TeamManager{UserID: testReader, TeamID: team.TeamID})
+	folder := createTestFolder(t, testOwner, "team docs")
+	shareViaTeam(t, folder, testReader, team)
+
+	router := testRouter()
+	router.DELETE("/teams/:teamId/managers/:managerId", middleware.RequireAuth(), RemoveManagerFromTeam)
+	status, response := testRequest(t, router, testManager, http.MethodDelete,
+		fmt.Sprintf("/teams/%d/managers/%d?revokeShares=true", team.TeamID, testReader), nil)
+	if status != http.StatusOK {
+		t.Fatalf("removing the manager returned %d: %v", status, response)
+	}
+	if access := folderAccessFor(testReader, folder); access != "" {
+		t.Fatalf("removed manager kept %q access", access)
+	}
+}
+
+func TestSyncTeamMembersRevokesTeamShares(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testReader)
+	folder := createTestFolder(t, testOwner, "team docs")
+	shareViaTeam(t, folder, testReader, team)
+
+	router := testRouter()
+	router.PUT("/teams/:teamId/members", middleware.RequireAuth(), SyncTeamMembers)
+	status, response := testRequest(t, router, testManager, http.MethodPut,
+		fmt.Sprintf("/teams/%d/members?revokeShares=true", team.TeamID), map[string]interface{}{"userIds": []uint{}})
+	if status != http.StatusOK {
+		t.Fatalf("syncing members returned %d: %v", status, response)
+	}
+	if access := folderAccessFor(testReader, folder); access != "" {
+		t.Fatalf("removed member kept %q access", access)
+	}
+}
+
+func TestDeleteTeamRevokesTeamShares(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testReader)
+	folder := createTestFolder(t, testOwner, "team docs")
+	shareViaTeam(t, folder, testReader, team)
+
+	router := testRouter()
+	router.DELETE("/teams/:teamId", middleware.RequireAuth(), DeleteTeam)
+	status, response := testRequest(t, router, testManager, http.MethodDelete,
+		fmt.Sprintf("/teams/%d?revokeShares=true", team.TeamID), nil)
+	if status != http.StatusOK {
+		t.Fatalf("deleting the team returned %d: %v", status, response)
+	}
+	if access := folderAccessFor(testReader, folder); access != "" {
+		t.Fatalf("member of the deleted team kept %q access", access)
+	}
+}
+
+func TestDeleteTeamChecksTargetsAndAssetOwner(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testReader)
+	other := models.Team{TeamName: "other"}
+	config.DB.Create(&other)
+
+	router := testRouter()
+	router.DELETE("/teams/:teamId", middleware.RequireAuth(), DeleteTeam)
+	for _, query := range []string{
+		fmt.Sprintf("members=move&targetTeamId=%d", other.TeamID),
+		fmt.Sprintf("assets=transfer&assetOwnerId=%d", testOwner),
+	} {
+		path := fmt.Sprintf("/teams/%d?%s", team.TeamID, query)
+		if status, response := testRequest(t, router, testManager, http.MethodDelete, path, nil); status != http.StatusForbidden {
+			t.Fatalf("manager DELETE %s returned %d, want 403: %v", path, status, response)
+		}
+	}
+
+	// A member of the team can receive the assets
+	path := fmt.Sprintf("/teams/%d?assets=transfer&assetOwnerId=%d", team.TeamID, testReader)
+	if status, response := testRequest(t, router, testManager, http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Fatalf("manager DELETE %s returned %d: %v", path, status, response)
+	}
+}
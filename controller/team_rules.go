@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a team change is rejected, returned as "reason" in error responses
+const (
+	// TeamNeedsManager: the change would leave the team without a manager
+	TeamNeedsManager = "team_needs_manager"
+	// TeamMemberCapReached: the change would take the team over its member cap
+	TeamMemberCapReached = "team_member_cap_reached"
+	// TeamAlreadyMember: the user is already a member of the team
+	TeamAlreadyMember = "team_already_member"
+	// TeamAlreadyManager: the user already manages the team. Managers count
+	// as members, so they cannot also be added as members.
+	TeamAlreadyManager = "team_already_manager"
+	// TeamParentCycle: the team would be nested under itself or a sub-team
+	TeamParentCycle = "team_parent_cycle"
+)
+
+// lockTeam locks the team's row until the transaction db belongs to ends, so
+// concurrent changes to the team's people are counted one after another
+func lockTeam(db *gorm.DB, teamID uint) error {
+	var team models.Team
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("team_id").First(&team, teamID).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to lock team")
+	}
+	return nil
+}
+
+// checkTeamManagers verifies, once a change was made inside db, that the
+// team still has at least one manager
+func checkTeamManagers(db *gorm.DB, teamID uint) error {
+	if err := lockTeam(db, teamID); err != nil {
+		return err
+	}
+	var managers int64
+	db.Model(&models.TeamManager{}).Where("team_id = ?", teamID).Count(&managers)
+	if managers == 0 {
+		return failRule(http.StatusUnprocessableEntity, TeamNeedsManager,
+			"A team must keep at least one manager; an admin can override with ?force=true")
+	}
+	return nil
+}
+
+// checkTeamCap verifies, once a change was made inside db, that the team has
+// no more people than its member cap. Managers count towards the cap.
+func checkTeamCap(db *gorm.DB, team models.Team) error {
+	if team.MaxMembers == nil {
+		return nil
+	}
+	if err := lockTeam(db, team.TeamID); err != nil {
+		return err
+	}
+	if teamHeadcount(db, team.TeamID) > int64(*team.MaxMembers) {
+		return memberCapError(*team.MaxMembers)
+	}
+	return nil
+}
+
+// memberCapError reports that a team is full
+func memberCapError(maxMembers int) error {
+	return failRule(http.StatusUnprocessableEntity, TeamMemberCapReached,
+		fmt.Sprintf("Team is limited to %d members, managers included", maxMembers))
+}
+
+// teamHeadcount counts the people in a team: its members and managers
+func teamHeadcount(db *gorm.DB, teamID uint) int64 {
+	var headcount int64
+	db.Raw("SELECT COUNT(*) FROM ("+
+		"SELECT user_id FROM team_members WHERE team_id = ? "+
+		"UNION SELECT user_id FROM team_managers WHERE team_id = ?) AS people", teamID, teamID).
+		Scan(&headcount)
+	return headcount
+}
+
+// teamUserIDs returns the ids of a team's members and managers. Managers
+// count as members, so they see and are reported with the team's assets.
+func teamUserIDs(db *gorm.DB, teamID uint) []uint {
+	var members, managers []uint
+	db.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &members)
+	db.Model(&models.TeamManager{}).Where("team_id = ?", teamID).Pluck("user_id", &managers)
+	return uniqueIDs(append(members, managers...))
+}
+
+// overrideTeamRules reports whether an admin asked with ?force=true to let a
+// team go without managers
+func overrideTeamRules(c *gin.Context) bool {
+	user, ok := middleware.CurrentUser(c)
+	return ok && user.Role == models.RoleAdmin && c.Query("force") == "true"
+}
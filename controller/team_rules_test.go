@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+func TestTeamRulesReasons(t *testing.T) {
+	setupTestDB(t)
+	admin := models.User{UserID: testAdmin, Role: models.RoleAdmin}
+	maxMembers := 3
+
+	tests := []struct {
+		name   string
+		change func(tx *gorm.DB, team models.Team) error
+		reason string
+	}{
+		{"removing the last manager", func(tx *gorm.DB, team models.Team) error {
+			if err := removeTeamRole(tx, team.TeamID, testManager, models.TeamRoleManager); err != nil {
+				return err
+			}
+			return checkTeamManagers(tx, team.TeamID)
+		}, TeamNeedsManager},
+		{"adding past the cap", func(tx *gorm.DB, team models.Team) error {
+			return addTeamRole(tx, team, testAdmin, models.TeamRoleMember)
+		}, TeamMemberCapReached},
+		{"lowering the cap below the headcount", func(tx *gorm.DB, team models.Team) error {
+			lower := 2
+			return checkTeamCap(tx, models.Team{TeamID: team.TeamID, MaxMembers: &lower})
+		}, TeamMemberCapReached},
+		{"adding a member twice", func(tx *gorm.DB, team models.Team) error {
+			return addTeamRole(tx, team, testOwner, models.TeamRoleMember)
+		}, TeamAlreadyMember},
+		{"adding a manager as a member", func(tx *gorm.DB, team models.Team) error {
+			return addTeamRole(tx, team, testManager, models.TeamRoleMember)
+		}, TeamAlreadyManager},
+		{"adding a manager twice", func(tx *gorm.DB, team models.Team) error {
+			return addTeamRole(tx, team, testManager, models.TeamRoleManager)
+		}, TeamAlreadyManager},
+		{"nesting a team under itself", func(tx *gorm.DB, team models.Team) error {
+			return checkTeamParent(tx, team.TeamID, team.TeamID, admin)
+		}, TeamParentCycle},
+		{"nesting a team under its sub-team", func(tx *gorm.DB, team models.Team) error {
+			sub := models.Team{TeamName: "sub", ParentTeamID: &team.TeamID}
+			if err := tx.Create(&sub).Error; err != nil {
+				return err
+			}
+			return checkTeamParent(tx, team.TeamID, sub.TeamID, admin)
+		}, TeamParentCycle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The team is full: a manager and two members
+			team := createTestTeam(t, tt.name, testOwner, testReader)
+			config.DB.Model(&team).Update("max_members", maxMembers)
+			team.MaxMembers = &maxMembers
+
+			err := runTransaction(config.DB, func(tx *gorm.DB) error {
+				return tt.change(tx, team)
+			})
+			if reason := actionReason(err); reason != tt.reason {
+				t.Fatalf("error %v has reason %q, want %q", err, reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAddTeamRoleAllowsPromotionAtTheCap(t *testing.T) {
+	setupTestDB(t)
+	team := createTestTeam(t, "core", testOwner)
+	maxMembers := 2
+	config.DB.Model(&team).Update("max_members", maxMembers)
+	team.MaxMembers = &maxMembers
+
+	// Promoting a member does not raise the headcount
+	err := runTransaction(config.DB, func(tx *gorm.DB) error {
+		return addTeamRole(tx, team, testOwner, models.TeamRoleManager)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasTeamRole(config.DB, team.TeamID, testOwner, models.TeamRoleManager) ||
+		hasTeamRole(config.DB, team.TeamID, testOwner, models.TeamRoleMember) {
+		t.Fatal("the member was not promoted to manager")
+	}
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// checkShareTeam verifies that shares can be granted to users on behalf of a
+// team: the caller manages the team and every user is in it
+func checkShareTeam(caller models.User, teamID uint, userIDs []uint) error {
+	var team models.Team
+	if err := config.DB.First(&team, teamID).Error; err != nil {
+		return failAction(http.StatusNotFound, "Team not found")
+	}
+	if !canManageTeam(caller, team.TeamID) {
+		return failAction(http.StatusForbidden, "Only admins and team managers can share on behalf of a team")
+	}
+
+	inTeam := make(map[uint]bool)
+	for _, userID := range teamUserIDs(config.DB, team.TeamID) {
+		inTeam[userID] = true
+	}
+	for _, userID := range userIDs {
+		if !inTeam[userID] {
+			return failAction(http.StatusUnprocessableEntity, "User "+strconv.FormatUint(uint64(userID), 10)+" is not in this team")
+		}
+	}
+	return nil
+}
+
+// teamGrantedShares returns the folder and note shares a user was granted on
+// behalf of a team
+func teamGrantedShares(db *gorm.DB, teamID, userID uint) ([]models.FolderShare, []models.NoteShare) {
+	folderShares := []models.FolderShare{}
+	noteShares := []models.NoteShare{}
+	db.Preload("Folder").Where("granted_via_team_id = ? AND user_id = ?", teamID, userID).Find(&folderShares)
+	db.Preload("Note").Where("granted_via_team_id = ? AND user_id = ?", teamID, userID).Find(&noteShares)
+	return folderShares, noteShares
+}
+
+// revokeTeamGrantedShares removes the shares a user was granted on behalf of
+// a team and returns how many were removed
+func revokeTeamGrantedShares(tx *gorm.DB, teamID, userID uint) (folders, notes int64, err error) {
+	revoked := tx.Where("granted_via_team_id = ? AND user_id = ?", teamID, userID).Delete(&models.FolderShare{})
+	if revoked.Error != nil {
+		return 0, 0, failAction(http.StatusInternalServerError, "Failed to revoke folder shares")
+	}
+	folders = revoked.RowsAffected
+
+	revoked = tx.Where("granted_via_team_id = ? AND user_id = ?", teamID, userID).Delete(&models.NoteShare{})
+	if revoked.Error != nil {
+		return 0, 0, failAction(http.StatusInternalServerError, "Failed to revoke note shares")
+	}
+	notes = revoked.RowsAffected
+	return folders, notes, refreshUserAccess(tx, userID)
+}
+
+// revokeLeaversShares revokes the shares granted on behalf of a team to each
+// user leaving it and returns how many were removed in all
+func revokeLeaversShares(tx *gorm.DB, teamID uint, userIDs []uint) (folders, notes int64, err error) {
+	for _, userID := range userIDs {
+		userFolders, userNotes, err := revokeTeamGrantedShares(tx, teamID, userID)
+		if err != nil {
+			return 0, 0, err
+		}
+		folders += userFolders
+		notes += userNotes
+	}
+	return folders, notes, nil
+}
+
+// forgetLeaversAssets drops the bookmarks, recent views and notifications
+// that users who left a team can no longer read
+func forgetLeaversAssets(userIDs []uint) {
+	for _, userID := range userIDs {
+		forgetLostAssets(userID)
+	}
+}
+
+// revokeSharesRequested reports whether the caller asked with
+// ?revokeShares=true to revoke the team-granted shares of the users a change
+// removes from a team
+func revokeSharesRequested(c *gin.Context) bool {
+	return c.Query("revokeShares") == "true"
+}
+
+// PreviewMemberRemoval lists the shares that removing a member or manager
+// with ?revokeShares=true would revoke: those granted on behalf of the team
+func PreviewMemberRemoval(c *gin.Context) {
+	team, ok := findManagedTeam(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.ParseUint(c.Param("memberId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+	if !hasTeamRole(config.DB, team.TeamID, uint(memberID), models.TeamRoleMember) &&
+		!hasTeamRole(config.DB, team.TeamID, uint(memberID), models.TeamRoleManager) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found in team"})
+		return
+	}
+
+	folderShares, noteShares := teamGrantedShares(config.DB, team.TeamID, uint(memberID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"teamId":       team.TeamID,
+		"memberId":     memberID,
+		"folderShares": folderShares,
+		"noteShares":   noteShares,
+	})
+}
+
+// allowShareTeam checks the optional team a share request is made on behalf
+// of. It writes the error response and returns false when the shares cannot
+// be granted on behalf of that team.
+func allowShareTeam(c *gin.Context, teamID *uint, userIDs []uint) bool {
+	if teamID == nil {
+		return true
+	}
+	caller, _ := middleware.CurrentUser(c)
+	if err := checkShareTeam(caller, *teamID, userIDs); err != nil {
+		respondActionError(c, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// TeamNode is a team in the org tree with its headcount
+type TeamNode struct {
+	TeamID       uint       `json:"teamId"`
+	TeamName     string     `json:"teamName"`
+	ParentTeamID *uint      `json:"parentTeamId,omitempty"`
+	ArchivedAt   *time.Time `json:"archivedAt,omitempty"`
+	MemberCount  int        `json:"memberCount"`
+	ManagerCount int        `json:"managerCount"`
+	// TotalHeadcount counts the distinct people in the team and all of its
+	// sub-teams, managers included
+	TotalHeadcount int         `json:"totalHeadcount"`
+	SubTeams       []*TeamNode `json:"subTeams"`
+}
+
+// GetOrgTree returns the team hierarchy with headcounts. ?rootTeamId= returns
+// the subtree under one team. Archived teams are left out unless
+// ?archived=true; sub-teams of a hidden team are then shown at the top level.
+func GetOrgTree(c *gin.Context) {
+	query := config.DB.Model(&models.Team{})
+	switch c.Query("archived") {
+	case "", "false":
+		query = query.Where("archived_at IS NULL")
+	case "true":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true or false"})
+		return
+	}
+
+	var teams []models.Team
+	if err := query.Order("team_name").Find(&teams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get teams"})
+		return
+	}
+
+	var members []models.TeamMember
+	var managers []models.TeamManager
+	config.DB.Select("team_id", "user_id").Find(&members)
+	config.DB.Select("team_id", "user_id").Find(&managers)
+
+	nodes := make(map[uint]*TeamNode, len(teams))
+	people := make(map[uint]map[uint]bool, len(teams))
+	for _, team := range teams {
+		nodes[team.TeamID] = &TeamNode{
+			TeamID:       team.TeamID,
+			TeamName:     team.TeamName,
+			ParentTeamID: team.ParentTeamID,
+			ArchivedAt:   team.ArchivedAt,
+			SubTeams:     []*TeamNode{},
+		}
+		people[team.TeamID] = make(map[uint]bool)
+	}
+	for _, member := range members {
+		if node, ok := nodes[member.TeamID]; ok {
+			node.MemberCount++
+			people[member.TeamID][member.UserID] = true
+		}
+	}
+	for _, manager := range managers {
+		if node, ok := nodes[manager.TeamID]; ok {
+			node.ManagerCount++
+			people[manager.TeamID][manager.UserID] = true
+		}
+	}
+
+	roots := []*TeamNode{}
+	for _, team := range teams {
+		node := nodes[team.TeamID]
+		if team.ParentTeamID != nil {
+			if parent, ok := nodes[*team.ParentTeamID]; ok {
+				parent.SubTeams = append(parent.SubTeams, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	for _, root := range roots {
+		countHeadcount(root, people)
+	}
+
+	if rootParam := c.Query("rootTeamId"); rootParam != "" {
+		rootID, err := strconv.ParseUint(rootParam, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid root team ID"})
+			return
+		}
+		root, ok := nodes[uint(rootID)]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+			return
+		}
+		roots = []*TeamNode{root}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"teams": roots})
+}
+
+// countHeadcount sets the total headcount of a node and its sub-teams and
+// returns the people it counted
+func countHeadcount(node *TeamNode, people map[uint]map[uint]bool) map[uint]bool {
+	counted := make(map[uint]bool, len(people[node.TeamID]))
+	for userID := range people[node.TeamID] {
+		counted[userID] = true
+	}
+	for _, sub := range node.SubTeams {
+		for userID := range countHeadcount(sub, people) {
+			counted[userID] = true
+		}
+	}
+	node.TotalHeadcount = len(counted)
+	return counted
+}
+
+// teamAncestorIDs returns the ids of a team's parent, its parent's parent and
+// so on up to the top of the hierarchy
+func teamAncestorIDs(db *gorm.DB, teamID uint) []uint {
+	ancestors := []uint{}
+	seen := map[uint]bool{teamID: true}
+	for {
+		var team models.Team
+		if err := db.Select("team_id", "parent_team_id").First(&team, teamID).Error; err != nil {
+			return ancestors
+		}
+		if team.ParentTeamID == nil || seen[*team.ParentTeamID] {
+			return ancestors
+		}
+		teamID = *team.ParentTeamID
+		seen[teamID] = true
+		ancestors = append(ancestors, teamID)
+	}
+}
+
+// teamDescendants returns a team's sub-teams, their sub-teams and so on,
+// level by level
+func teamDescendants(db *gorm.DB, teamID uint) []models.Team {
+	descendants := []models.Team{}
+	seen := map[uint]bool{teamID: true}
+	level := []uint{teamID}
+	for len(level) > 0 {
+		var children []models.Team
+		db.Where("parent_team_id IN ?", level).Order("team_name").Find(&children)
+		level = nil
+		for _, child := range children {
+			if !seen[child.TeamID] {
+				seen[child.TeamID] = true
+				descendants = append(descendants, child)
+				level = append(level, child.TeamID)
+			}
+		}
+	}
+	return descendants
+}
+
+// checkTeamParent verifies that a team can be nested under a parent: the
+// parent exists, the nesting makes no cycle, and the user can manage the
+// parent. A new team has a zero TeamID.
+func checkTeamParent(db *gorm.DB, teamID, parentID uint, user models.User) error {
+	var parent models.Team
+	if err := db.First(&parent, parentID).Error; err != nil {
+		return failAction(http.StatusNotFound, "Parent team not found")
+	}
+
+	if teamID != 0 {
+		if parentID == teamID {
+			return failRule(http.StatusConflict, TeamParentCycle, "A team cannot be its own parent")
+		}
+		for _, ancestorID := range teamAncestorIDs(db, parentID) {
+			if ancestorID == teamID {
+				return failRule(http.StatusConflict, TeamParentCycle, "A team cannot be nested under one of its sub-teams")
+			}
+		}
+	}
+
+	if !canManageTeam(user, parent.TeamID) {
+		return failAction(http.StatusForbidden, "Only admins and managers of the parent team can nest teams under it")
+	}
+	return nil
+}
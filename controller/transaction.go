@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"gorm.io/gorm"
+)
+
+// pendingWork holds work that must wait until a transaction has committed,
+// such as dropping cache entries or deleting blobs
+type pendingWork struct {
+	mu    sync.Mutex
+	tasks []func()
+}
+
+// pendingWorkKey is the context key under which a transaction carries its
+// pending work
+type pendingWorkKey struct{}
+
+// add appends tasks to the pending work
+func (p *pendingWork) add(tasks ...func()) {
+	p.mu.Lock()
+	p.tasks = append(p.tasks, tasks...)
+	p.mu.Unlock()
+}
+
+// take removes and returns the pending work
+func (p *pendingWork) take() []func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tasks := p.tasks
+	p.tasks = nil
+	return tasks
+}
+
+// run runs the pending work in the order it was added
+func (p *pendingWork) run() {
+	for _, task := range p.take() {
+		task()
+	}
+}
+
+// runTransaction runs fn in a transaction on db. Work passed to afterCommit
+// inside it runs once the transaction has committed, and not at all when it
+// is rolled back. Inside another transaction fn runs in a savepoint, and its
+// work waits for the outer commit unless the savepoint is rolled back.
+func runTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	pending := &pendingWork{}
+	ctx := context.WithValue(db.Statement.Context, pendingWorkKey{}, pending)
+	if err := db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+
+	if outer, nested := db.Statement.Context.Value(pendingWorkKey{}).(*pendingWork); nested {
+		outer.add(pending.take()...)
+		return nil
+	}
+	pending.run()
+	return nil
+}
+
+// beginTransaction starts a transaction on config.DB for handlers that commit
+// it themselves with commitTransaction
+func beginTransaction() *gorm.DB {
+	ctx := context.WithValue(context.Background(), pendingWorkKey{}, &pendingWork{})
+	return config.DB.WithContext(ctx).Begin()
+}
+
+// commitTransaction commits a transaction started with beginTransaction and
+// runs the work its statements left for after the commit
+func commitTransaction(tx *gorm.DB) error {
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	if pending, ok := tx.Statement.Context.Value(pendingWorkKey{}).(*pendingWork); ok {
+		pending.run()
+	}
+	return nil
+}
+
+// afterCommit runs task once the transaction db belongs to has committed.
+// Outside runTransaction and beginTransaction, db writes straight to the
+// database and task runs at once.
+func afterCommit(db *gorm.DB, task func()) {
+	if db.Statement.Context != nil {
+		if pending, ok := db.Statement.Context.Value(pendingWorkKey{}).(*pendingWork); ok {
+			pending.add(task)
+			return
+		}
+	}
+	task()
+}
@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+)
+
+// TransferOwnershipRequest names the new owner of a folder or note
+type TransferOwnershipRequest struct {
+	NewOwnerID uint `json:"newOwnerId" binding:"required"`
+	KeepAccess bool `json:"keepAccess"` // the previous owner keeps a write share
+}
+
+// OffboardUserRequest names the user who takes over an offboarded user's
+// folders and notes
+type OffboardUserRequest struct {
+	SuccessorID uint `json:"successorId" binding:"required"`
+}
+
+// TransferFolder gives a folder, and the notes in it that the folder owner
+// owns, to another user. Only the owner or an admin can do this.
+func TransferFolder(c *gin.Context) {
+	folderID, err := strconv.ParseUint(c.Param("folderId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folder models.Folder
+	if err := config.DB.First(&folder, folderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+	if !canTransfer(c, folder.OwnerID, req.NewOwnerID) {
+		return
+	}
+
+	previousOwnerID := folder.OwnerID
+	var notes int
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		if err := transferFolder(tx, folder, req.NewOwnerID, req.KeepAccess); err != nil {
+			return err
+		}
+
+		var owned []models.Note
+		if err := tx.Where("folder_id = ? AND owner_id = ?", folder.FolderID, previousOwnerID).Find(&owned).Error; err != nil {
+			return failAction(http.StatusInternalServerError, "Failed to get notes")
+		}
+		for _, note := range owned {
+			if err := transferNote(tx, note, req.NewOwnerID, req.KeepAccess); err != nil {
+				return err
+			}
+		}
+		notes = len(owned)
+		return nil
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+	if !req.KeepAccess {
+		forgetLostAssets(previousOwnerID)
+	}
+
+	config.DB.Preload("Owner").First(&folder, folder.FolderID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Folder transferred successfully",
+		"folder":  folder,
+		"notes":   notes,
+	})
+}
+
+// TransferNote gives a note to another user. Only the owner or an admin can
+// do this.
+func TransferNote(c *gin.Context) {
+	noteID, err := strconv.ParseUint(c.Param("noteId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var note models.Note
+	if err := config.DB.First(&note, noteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if !canTransfer(c, note.OwnerID, req.NewOwnerID) {
+		return
+	}
+
+	previousOwnerID := note.OwnerID
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		return transferNote(tx, note, req.NewOwnerID, req.KeepAccess)
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+	if !req.KeepAccess {
+		forgetLostAssets(previousOwnerID)
+	}
+
+	config.DB.Preload("Owner").Preload("Folder").First(&note, note.NoteID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Note transferred successfully",
+		"note":    note,
+	})
+}
+
+// OffboardUser gives every folder and note a user owns to a successor and
+// revokes every share the user holds, in one transaction. Admins and the
+// managers of one of the user's teams can do this. Only admins can offboard
+// admins and managers; other callers must pick a successor from a team they
+// manage, other than themselves.
+func OffboardUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req OffboardUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	caller, _ := middleware.CurrentUser(c)
+	if caller.UserID == user.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot offboard yourself"})
+		return
+	}
+	if !canOffboard(caller, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can offboard admins and managers, and managers of the user's teams other users"})
+		return
+	}
+
+	if req.SuccessorID == user.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The successor must be another user"})
+		return
+	}
+	var successor models.User
+	if err := config.DB.First(&successor, req.SuccessorID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Successor not found"})
+		return
+	}
+	if caller.Role != models.RoleAdmin {
+		if successor.UserID == caller.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can name themselves as successor"})
+			return
+		}
+		if !managesTeamOf(caller, successor.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The successor must be in a team you manage"})
+			return
+		}
+	}
+
+	var folders, notes int
+	var folderShares, noteShares int64
+	err = runTransaction(config.DB, func(tx *gorm.DB) error {
+		var err error
+		if folders, notes, err = transferOwnedAssets(tx, []uint{user.UserID}, successor.UserID, false); err != nil {
+			return err
+		}
+
+		revoked := tx.Where("user_id = ?", user.UserID).Delete(&models.FolderShare{})
+		if revoked.Error != nil {
+			return failAction(http.StatusInternalServerError, "Failed to revoke folder shares")
+		}
+		folderShares = revoked.RowsAffected
+
+		revoked = tx.Where("user_id = ?", user.UserID).Delete(&models.NoteShare{})
+		if revoked.Error != nil {
+			return failAction(http.StatusInternalServerError, "Failed to revoke note shares")
+		}
+		noteShares = revoked.RowsAffected
+		return refreshUserAccess(tx, user.UserID)
+	})
+	if err != nil {
+		respondActionError(c, err)
+		return
+	}
+	forgetLostAssets(user.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "User offboarded successfully",
+		"userId":    user.UserID,
+		"successor": successor,
+		"transferred": gin.H{
+			"folders": folders,
+			"notes":   notes,
+		},
+		"revoked": gin.H{
+			"folderShares": folderShares,
+			"noteShares":   noteShares,
+		},
+	})
+}
+
+// canTransfer checks that the caller can give away an asset owned by ownerID
+// and that the new owner exists. It writes the error response and returns
+// false otherwise.
+func canTransfer(c *gin.Context, ownerID, newOwnerID uint) bool {
+	caller, _ := middleware.CurrentUser(c)
+	if caller.UserID != ownerID && caller.Role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can transfer ownership"})
+		return false
+	}
+	if newOwnerID == ownerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The new owner already owns this"})
+		return false
+	}
+	var newOwner models.User
+	if err := config.DB.First(&newOwner, newOwnerID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "New owner not found"})
+		return false
+	}
+	return true
+}
+
+// canOffboard reports whether the caller is an admin, or manages one of the
+// teams of a user who is neither an admin nor a manager
+func canOffboard(caller models.User, user models.User) bool {
+	if caller.Role == models.RoleAdmin {
+		return true
+	}
+	if user.Role == models.RoleAdmin || user.Role == models.RoleManager {
+		return false
+	}
+	var managing int64
+	config.DB.Model(&models.TeamManager{}).Where("user_id = ?", user.UserID).Count(&managing)
+	if managing > 0 {
+		return false
+	}
+	return managesTeamOf(caller, user.UserID)
+}
+
+// managesTeamOf reports whether the caller can manage one of the teams the
+// user is a member or manager of
+func managesTeamOf(caller models.User, userID uint) bool {
+	var teamIDs []uint
+	config.DB.Model(&models.TeamMember{}).Where("user_id = ?", userID).Pluck("team_id", &teamIDs)
+	var managed []uint
+	config.DB.Model(&models.TeamManager{}).Where("user_id = ?", userID).Pluck("team_id", &managed)
+	for _, teamID := range uniqueIDs(append(teamIDs, managed...)) {
+		if canManageTeam(caller, teamID) {
+			return true
+		}
+	}
+	return false
+}
+
+// transferFolder gives a folder to a new owner. Shares the new owner held on
+// it are dropped; with keepAccess the previous owner keeps a write share.
+func transferFolder(tx *gorm.DB, folder models.Folder, newOwnerID uint, keepAccess bool) error {
+	previousOwnerID := folder.OwnerID
+	if err := tx.Model(&folder).Update("owner_id", newOwnerID).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to transfer folder")
+	}
+	if err := tx.Where("folder_id = ? AND user_id = ?", folder.FolderID, newOwnerID).Delete(&models.FolderShare{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to transfer folder")
+	}
+	if keepAccess {
+		if _, _, err := shareFolderWith(tx, folder.FolderID, previousOwnerID, AccessWrite, nil); err != nil {
+			return err
+		}
+	}
+	return refreshFolderAccess(tx, folder.FolderID, previousOwnerID, newOwnerID)
+}
+
+// transferNote gives a note to a new owner. Shares the new owner held on it
+// are dropped; with keepAccess the previous owner keeps a write share. Tags
+// belong to their owner, so the note's tags move to the new owner's tags of
+// the same names.
+func transferNote(tx *gorm.DB, note models.Note, newOwnerID uint, keepAccess bool) error {
+	previousOwnerID := note.OwnerID
+	if err := tx.Model(&note).Update("owner_id", newOwnerID).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to transfer note")
+	}
+	if err := retagNote(tx, note.NoteID, newOwnerID); err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to transfer note tags")
+	}
+	if err := tx.Where("note_id = ? AND user_id = ?", note.NoteID, newOwnerID).Delete(&models.NoteShare{}).Error; err != nil {
+		return failAction(http.StatusInternalServerError, "Failed to transfer note")
+	}
+	if keepAccess {
+		if _, _, err := shareNoteWith(tx, note.NoteID, previousOwnerID, AccessWrite, nil); err != nil {
+			return err
+		}
+	}
+	return refreshNoteAccess(tx, note.NoteID, previousOwnerID, newOwnerID)
+}
+
+// retagNote replaces the links from a note to tags of other owners with links
+// to the owner's tags of the same names, creating those tags as needed
+func retagNote(tx *gorm.DB, noteID, ownerID uint) error {
+	var foreign []models.Tag
+	err := tx.Joins("JOIN note_tags ON note_tags.tag_id = tags.tag_id").
+		Where("note_tags.note_id = ? AND tags.owner_id <> ?", noteID, ownerID).
+		Find(&foreign).Error
+	if err != nil || len(foreign) == 0 {
+		return err
+	}
+
+	tagIDs := make([]uint, len(foreign))
+	names := make([]string, len(foreign))
+	for i, tag := range foreign {
+		tagIDs[i] = tag.TagID
+		names[i] = tag.Name
+	}
+	if err := tx.Where("note_id = ? AND tag_id IN ?", noteID, tagIDs).Delete(&models.NoteTag{}).Error; err != nil {
+		return err
+	}
+	return applyNoteTags(tx, ownerID, noteID, names)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+func TestTransferNoteMovesTagsToNewOwner(t *testing.T) {
+	setupTestDB(t)
+	folder := createTestFolder(t, testOwner, "docs")
+	note := createTestNote(t, folder, testOwner, "plan")
+	if err := applyNoteTags(config.DB, testOwner, note.NoteID, []string{"plans", "q3"}); err != nil {
+		t.Fatal(err)
+	}
+	// The new owner already has one of the tags
+	existing := models.Tag{OwnerID: testReader, Name: "plans"}
+	config.DB.Create(&existing)
+
+	router := testRouter()
+	router.POST("/notes/:noteId/transfer", middleware.RequireAuth(), TransferNote)
+	status, response := testRequest(t, router, testOwner, http.MethodPost, fmt.Sprintf("/notes/%d/transfer", note.NoteID),
+		map[string]interface{}{"newOwnerId": testReader})
+	if status != http.StatusOK {
+		t.Fatalf("transfer returned %d: %v", status, response)
+	}
+
+	var tags []models.Tag
+	config.DB.Joins("JOIN note_tags ON note_tags.tag_id = tags.tag_id").Where("note_tags.note_id = ?", note.NoteID).Find(&tags)
+	names := []string{}
+	for _, tag := range tags {
+		if tag.OwnerID != testReader {
+			t.Errorf("note still carries tag %q of user %d", tag.Name, tag.OwnerID)
+		}
+		if tag.Name == "plans" && tag.TagID != existing.TagID {
+			t.Errorf("note got a new plans tag instead of the new owner's")
+		}
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[plans q3]" {
+		t.Fatalf("note tags = %v, want [plans q3]", names)
+	}
+}
+
+func TestOffboardUserLimitsManagers(t *testing.T) {
+	setupTestDB(t)
+	createTestTeam(t, "core", testAdmin, testOwner, testReader)
+	folder := createTestFolder(t, testOwner, "docs")
+	// The reader manages a team of their own
+	other := models.Team{TeamName: "other"}
+	config.DB.Create(&other)
+	config.DB.Create(&models.TeamManager{UserID: testReader, TeamID: other.TeamID})
+	outsider := models.User{Username: "outsider", Email: "outsider@example.com", PasswordHash: "x", Role: models.RoleUser}
+	config.DB.Create(&outsider)
+
+	router := testRouter()
+	router.POST("/users/:userId/offboard", middleware.RequireAuth(), OffboardUser)
+	offboard := func(userID, successorID uint) int {
+		status, _ := testRequest(t, router, testManager, http.MethodPost, fmt.Sprintf("/users/%d/offboard", userID),
+			map[string]interface{}{"successorId": successorID})
+		return status
+	}
+
+	for name, status := range map[string]int{
+		"an admin":                       offboard(testAdmin, testOwner),
+		"a fellow manager":               offboard(testReader, testOwner),
+		"a successor outside your teams": offboard(testOwner, outsider.UserID),
+		"yourself as successor":          offboard(testOwner, testManager),
+	} {
+		if status != http.StatusForbidden {
+			t.Errorf("offboarding with %s returned %d, want 403", name, status)
+		}
+	}
+	config.DB.First(&folder, folder.FolderID)
+	if folder.OwnerID != testOwner {
+		t.Fatalf("a rejected offboarding gave the folder to user %d", folder.OwnerID)
+	}
+
+	if status := offboard(testOwner, testReader); status != http.StatusOK {
+		t.Fatalf("offboarding a member to a teammate returned %d", status)
+	}
+}
@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Retention is how long finished jobs and their files are kept
+const Retention = 24 * time.Hour
+
+// Job states
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job is a background task started by a user
+type Job struct {
+	ID         string      `json:"jobId"`
+	Kind       string      `json:"kind"`
+	OwnerID    uint        `json:"ownerId"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	HasFile    bool        `json:"hasFile"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+
+	filePath string
+	fileName string
+}
+
+// Output is what a finished job produced. FilePath, if set, is removed
+// together with the job once it expires.
+type Output struct {
+	FilePath string
+	FileName string
+	Result   interface{}
+}
+
+// RunFunc does the work of a job
+type RunFunc func() (Output, error)
+
+// Manager runs jobs in the background and keeps their state in memory
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a manager and starts removing expired jobs
+func NewManager() *Manager {
+	m := &Manager{jobs: make(map[string]*Job)}
+	go m.cleanupLoop()
+	return m
+}
+
+// Start queues a job and runs it in a new goroutine
+func (m *Manager) Start(kind string, ownerID uint, run RunFunc) Job {
+	job := &Job{
+		ID:        newID(),
+		Kind:      kind,
+		OwnerID:   ownerID,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	go m.run(job, run)
+	return snapshot
+}
+
+func (m *Manager) run(job *Job, run RunFunc) {
+	m.setStatus(job, StatusRunning)
+
+	output, err := run()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		log.Printf("jobs: %s job %s failed: %v", job.Kind, job.ID, err)
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = StatusCompleted
+	job.Result = output.Result
+	job.filePath = output.FilePath
+	job.fileName = output.FileName
+	job.HasFile = output.FilePath != ""
+}
+
+func (m *Manager) setStatus(job *Job, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.Status = status
+}
+
+// Get returns a copy of a job
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// File returns the path and download name of a completed job's output
+func (j Job) File() (path, name string, ok bool) {
+	return j.filePath, j.fileName, j.HasFile
+}
+
+func (m *Manager) cleanupLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.cleanup()
+	}
+}
+
+// cleanup drops finished jobs past their retention period
+func (m *Manager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-Retention)
+	for id, job := range m.jobs {
+		if job.FinishedAt == nil || job.FinishedAt.After(cutoff) {
+			continue
+		}
+		if job.filePath != "" {
+			os.Remove(job.filePath)
+		}
+		delete(m.jobs, id)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
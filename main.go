@@ -1,16 +1,39 @@
 package main
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/previews"
 	"github.com/seta-namnv-6798/go-apis/routes"
 )
 
 func main() {
+	// Tokens cannot be verified without the shared secret
+	config.RequireJWTSecret()
+
 	// Initialize database connection
 	config.Connect()
+	config.ConnectStorage()
+	config.ConnectCache()
+
+	// Access checks read the effective access table, so fill it on first run
+	if err := controller.EnsureEffectiveAccess(config.DB); err != nil {
+		panic(err)
+	}
+
+	// Generate attachment thumbnails and previews in the background
+	previews.Start(2)
+
+	// Expire team invitations and claim those for emails that signed up
+	controller.StartInvitationSweeper(time.Minute)
 
 	router := gin.New()
+	router.Use(middleware.Authenticate())
+
 	router.GET("/", func(c *gin.Context) {
 		c.String(200, "Go APIs - Asset Management System")
 	})
@@ -20,6 +43,13 @@ func main() {
 	routes.SetupFolderRoutes(router)
 	routes.SetupNoteRoutes(router)
 	routes.SetupAssetRoutes(router)
+	routes.SetupJobRoutes(router)
+	routes.SetupTagRoutes(router)
+	routes.SetupMeRoutes(router)
+	routes.SetupBulkRoutes(router)
+	routes.SetupBatchRoutes(router)
+	routes.SetupUserRoutes(router)
+	routes.SetupCacheRoutes(router)
 
 	router.Run(":8080")
 }
@@ -0,0 +1,87 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	htmltomd "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// highlightStyle is the chroma style used for the code block stylesheet
+const highlightStyle = "github"
+
+var (
+	// renderer converts GitHub-flavoured Markdown to HTML. Raw HTML is passed
+	// through because every result is sanitized afterwards.
+	renderer = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(highlightStyle),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	// policy strips scripts, event handlers and unsafe URLs while keeping
+	// what the renderer produces for tables, task lists, highlighted code
+	// and heading anchors
+	policy = newPolicy()
+
+	// converter turns HTML back into GitHub-flavoured Markdown
+	converter = htmltomd.NewConverter("", true, nil).Use(plugin.GitHubFlavored())
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\s-]+$`)).OnElements("span", "code", "pre", "div")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// Render converts Markdown to sanitized HTML
+func Render(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return Sanitize(buf.String()), nil
+}
+
+// Sanitize removes scripts and dangerous attributes from HTML
+func Sanitize(htmlSrc string) string {
+	return policy.Sanitize(htmlSrc)
+}
+
+// FromHTML converts HTML to Markdown. The input is sanitized first so
+// nothing unsafe survives in the stored body.
+func FromHTML(htmlSrc string) (string, error) {
+	md, err := converter.ConvertString(Sanitize(htmlSrc))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(md), nil
+}
+
+// StyleSheet returns the CSS for the classes used in highlighted code blocks
+func StyleSheet() (string, error) {
+	var buf bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&buf, styles.Get(highlightStyle)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,38 @@
+// Package mentions finds @username mentions in note bodies and comments.
+package mentions
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// A mention starts at the beginning of the text or after a character that
+	// cannot be part of an email address or another word. Usernames may
+	// contain dots and dashes, but not end with one, so trailing punctuation
+	// is left out.
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@.\-/])@(\w+(?:[.\-]\w+)*)`)
+
+	// Code is not scanned for mentions. A fence left open runs to the end
+	// of the text, as in CommonMark.
+	fencedCode = regexp.MustCompile("(?ms)^\\s*(```|~~~).*?(?:^\\s*(```|~~~)\\s*$|\\z)")
+	inlineCode = regexp.MustCompile("`[^`\n]*`")
+)
+
+// Parse returns the usernames mentioned in Markdown text, in order of first
+// appearance and without duplicates. Mentions inside code are ignored.
+func Parse(text string) []string {
+	text = fencedCode.ReplaceAllString(text, "")
+	text = inlineCode.ReplaceAllString(text, "")
+
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		key := strings.ToLower(match[1])
+		if !seen[key] {
+			seen[key] = true
+			usernames = append(usernames, match[1])
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,43 @@
+package mentions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no mentions here", nil},
+		{"start of text", "@alice look", []string{"alice"}},
+		{"after a space", "hi @alice", []string{"alice"}},
+		{"after punctuation", "(@alice) and [@bob]", []string{"alice", "bob"}},
+		{"order of appearance", "@bob then @alice", []string{"bob", "alice"}},
+		{"duplicates ignore case", "@Alice and @alice", []string{"Alice"}},
+		{"dots and dashes", "ping @alice.smith and @bob-jones", []string{"alice.smith", "bob-jones"}},
+		{"trailing period", "thanks @alice.", []string{"alice"}},
+		{"trailing punctuation", "@alice, @bob! @carol? @dave-", []string{"alice", "bob", "carol", "dave"}},
+		{"email address", "mail alice@example.com", nil},
+		{"email next to a mention", "alice@example.com cc @bob", []string{"bob"}},
+		{"url path", "see https://example.com/@alice", nil},
+		{"double at", "@@alice", nil},
+		{"after multi-byte text", "日本 @alice と@bob", []string{"alice", "bob"}},
+		{"multi-byte after the name", "@alice’s note", []string{"alice"}},
+		{"inline code", "`@alice` and @bob", []string{"bob"}},
+		{"fenced code", "```\n@alice\n```\n@bob", []string{"bob"}},
+		{"tilde fence with a language", "~~~go\n// @alice\n~~~\n@bob", []string{"bob"}},
+		{"indented fence", "  ```\n  @alice\n  ```\n@bob", []string{"bob"}},
+		{"several fences", "```\n@a\n```\n@b\n```\n@c\n```", []string{"b"}},
+		{"unclosed fence", "@bob\n```\n@alice", []string{"bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
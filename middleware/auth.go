@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+)
+
+// currentUserKey is the gin context key holding the authenticated user
+const currentUserKey = "currentUser"
+
+// tokenClaims mirrors the payload signed by the user service
+type tokenClaims struct {
+	UserID uint `json:"userId"`
+	jwt.RegisteredClaims
+}
+
+// Authenticate resolves the caller from a user-service JWT if one is present.
+// The token is read from the Authorization header, or from the access_token
+// query parameter for WebSocket clients that cannot set headers.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenStr == "" {
+			tokenStr = c.Query("access_token")
+		}
+		if tokenStr == "" {
+			c.Next()
+			return
+		}
+
+		var claims tokenClaims
+		token, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+			// An empty secret would accept tokens signed with an empty key
+			secret := config.JWTSecret()
+			if len(secret) == 0 {
+				return nil, errors.New("JWT_SECRET is not set")
+			}
+			return secret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		var user models.User
+		if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.Set(currentUserKey, user)
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests that were not authenticated
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentUser(c); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the authenticated user for the request
+func CurrentUser(c *gin.Context) (models.User, bool) {
+	value, ok := c.Get(currentUserKey)
+	if !ok {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
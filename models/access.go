@@ -0,0 +1,28 @@
+package models
+
+// Ways a user can reach a folder or note
+const (
+	AccessSourceOwner       = "owner"        // the user owns the asset
+	AccessSourceShare       = "share"        // the asset was shared with the user
+	AccessSourceTeamShare   = "team_share"   // the asset was shared with the user on behalf of a team
+	AccessSourceFolderOwner = "folder_owner" // the user owns the note's folder
+	AccessSourceFolderShare = "folder_share" // the note's folder was shared with the user
+)
+
+// EffectiveAccess is one way a user can reach a folder or note and the
+// access it grants. The table is a projection of ownership and shares: it is
+// updated in the same transaction as the records it is derived from and can
+// be rebuilt from them at any time.
+type EffectiveAccess struct {
+	ID        uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint   `json:"userId" gorm:"not null;uniqueIndex:idx_effective_access_grant"`
+	AssetType string `json:"assetType" gorm:"not null;size:16;uniqueIndex:idx_effective_access_grant;index:idx_effective_access_asset"`
+	AssetID   uint   `json:"assetId" gorm:"not null;uniqueIndex:idx_effective_access_grant;index:idx_effective_access_asset"`
+	Source    string `json:"source" gorm:"not null;size:16;uniqueIndex:idx_effective_access_grant"`
+	Level     string `json:"level" gorm:"not null;check:level IN ('read', 'write', 'owner')"`
+}
+
+// TableName override for the effective access table
+func (EffectiveAccess) TableName() string {
+	return "effective_access"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment represents a file uploaded to a note
+type Attachment struct {
+	AttachmentID  uint                  `json:"attachmentId" gorm:"primaryKey;autoIncrement"`
+	NoteID        uint                  `json:"noteId" gorm:"not null;index"`
+	UploaderID    uint                  `json:"uploaderId" gorm:"not null;index"`
+	FileName      string                `json:"fileName" gorm:"not null"`
+	ContentType   string                `json:"contentType" gorm:"not null"`
+	Size          int64                 `json:"size" gorm:"not null"`
+	Checksum      string                `json:"checksum" gorm:"not null"` // SHA-256, hex encoded
+	StorageKey    string                `json:"-" gorm:"not null;uniqueIndex"`
+	PreviewStatus string                `json:"previewStatus" gorm:"not null;default:none"` // none, pending, ready or failed
+	PreviewText   string                `json:"previewText,omitempty" gorm:"type:text"`
+	PreviewError  string                `json:"previewError,omitempty"`
+	Thumbnails    []AttachmentThumbnail `json:"thumbnails" gorm:"foreignKey:AttachmentID;references:AttachmentID"`
+	Note          Note                  `json:"-" gorm:"foreignKey:NoteID;references:NoteID"`
+	Uploader      User                  `json:"uploader" gorm:"foreignKey:UploaderID;references:UserID"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	UpdatedAt     time.Time             `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt        `json:"-" gorm:"index"`
+}
+
+// AttachmentThumbnail is a resized copy of an image attachment
+type AttachmentThumbnail struct {
+	ID           uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	AttachmentID uint      `json:"attachmentId" gorm:"not null;index"`
+	Size         string    `json:"size" gorm:"not null"` // small, medium or large
+	Width        int       `json:"width" gorm:"not null"`
+	Height       int       `json:"height" gorm:"not null"`
+	ContentType  string    `json:"contentType" gorm:"not null"`
+	StorageKey   string    `json:"-" gorm:"not null;uniqueIndex"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Asset types that per-user records can point at
+const (
+	AssetTypeNote   = "note"
+	AssetTypeFolder = "folder"
+)
+
+// Bookmark records that a user starred or pinned a note or folder. It is
+// private to the user and removed when both flags are cleared.
+type Bookmark struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint       `json:"userId" gorm:"not null;uniqueIndex:idx_bookmarks_user_asset"`
+	AssetType string     `json:"assetType" gorm:"not null;uniqueIndex:idx_bookmarks_user_asset;check:asset_type IN ('note', 'folder')"`
+	AssetID   uint       `json:"assetId" gorm:"not null;uniqueIndex:idx_bookmarks_user_asset"`
+	Starred   bool       `json:"starred" gorm:"not null;default:false"`
+	Pinned    bool       `json:"pinned" gorm:"not null;default:false"`
+	PinnedAt  *time.Time `json:"pinnedAt,omitempty"`
+	User      User       `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// RecentView records the last time a user opened a note or folder
+type RecentView struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    uint      `json:"userId" gorm:"not null;uniqueIndex:idx_recent_views_user_asset;index:idx_recent_views_user_viewed"`
+	AssetType string    `json:"assetType" gorm:"not null;uniqueIndex:idx_recent_views_user_asset"`
+	AssetID   uint      `json:"assetId" gorm:"not null;uniqueIndex:idx_recent_views_user_asset"`
+	ViewedAt  time.Time `json:"viewedAt" gorm:"not null;index:idx_recent_views_user_viewed"`
+	User      User      `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+}
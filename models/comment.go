@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a comment on a note. Comments without a parent start a thread;
+// replies point at the thread's first comment. Only thread starters carry an
+// anchor and a resolved state.
+type Comment struct {
+	CommentID    uint           `json:"commentId" gorm:"primaryKey;autoIncrement"`
+	NoteID       uint           `json:"noteId" gorm:"not null;index"`
+	AuthorID     uint           `json:"authorId" gorm:"not null;index"`
+	ParentID     *uint          `json:"parentId" gorm:"index"`
+	Body         string         `json:"body" gorm:"type:text;not null"`
+	AnchorStart  *int           `json:"anchorStart,omitempty"` // in Unicode code points of the note body
+	AnchorEnd    *int           `json:"anchorEnd,omitempty"`
+	AnchorText   string         `json:"anchorText,omitempty" gorm:"type:text"`
+	AnchorStatus string         `json:"anchorStatus,omitempty"` // attached or detached, empty if not anchored
+	ResolvedAt   *time.Time     `json:"resolvedAt,omitempty"`
+	ResolvedByID *uint          `json:"resolvedById,omitempty"`
+	Edited       bool           `json:"edited" gorm:"not null;default:false"`
+	Author       User           `json:"author" gorm:"foreignKey:AuthorID;references:UserID"`
+	Note         Note           `json:"-" gorm:"foreignKey:NoteID;references:NoteID"`
+	Replies      []Comment      `json:"replies,omitempty" gorm:"foreignKey:ParentID;references:CommentID"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -19,14 +19,15 @@ type Folder struct {
 
 // FolderShare represents folder sharing permissions
 type FolderShare struct {
-	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	FolderID  uint      `json:"folderId" gorm:"not null;index"`
-	UserID    uint      `json:"userId" gorm:"not null;index"`
-	Access    string    `json:"access" gorm:"not null;check:access IN ('read', 'write')"`
-	Folder    Folder    `json:"folder" gorm:"foreignKey:FolderID;references:FolderID"`
-	User      User      `json:"user" gorm:"foreignKey:UserID;references:UserID"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	FolderID         uint      `json:"folderId" gorm:"not null;index"`
+	UserID           uint      `json:"userId" gorm:"not null;index"`
+	Access           string    `json:"access" gorm:"not null;check:access IN ('read', 'write')"`
+	GrantedViaTeamID *uint     `json:"grantedViaTeamId,omitempty" gorm:"index"` // team the share was granted on behalf of, if any
+	Folder           Folder    `json:"folder" gorm:"foreignKey:FolderID;references:FolderID"`
+	User             User      `json:"user" gorm:"foreignKey:UserID;references:UserID"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
 }
 
 // TableName override for folder shares table
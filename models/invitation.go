@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Roles a team invitation can offer
+const (
+	TeamRoleMember  = "member"
+	TeamRoleManager = "manager"
+)
+
+// Invitation states. A pending invitation past its expiry counts as expired.
+const (
+	InvitationPending   = "pending"
+	InvitationAccepted  = "accepted"
+	InvitationDeclined  = "declined"
+	InvitationCancelled = "cancelled"
+	InvitationExpired   = "expired"
+)
+
+// TeamInvitation invites a user, or an email address without an account yet,
+// to join a team as a member or manager
+type TeamInvitation struct {
+	InvitationID uint       `json:"invitationId" gorm:"primaryKey;autoIncrement"`
+	TeamID       uint       `json:"teamId" gorm:"not null;index"`
+	Role         string     `json:"role" gorm:"not null;check:role IN ('member', 'manager')"`
+	UserID       *uint      `json:"userId,omitempty" gorm:"index"` // set once the invitee has an account
+	Email        string     `json:"email,omitempty" gorm:"index"`  // lowercased; set for invitations by email
+	InvitedByID  uint       `json:"invitedById" gorm:"not null"`
+	Status       string     `json:"status" gorm:"not null;index"`
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"not null"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty"`
+	Team         Team       `json:"team" gorm:"foreignKey:TeamID;references:TeamID"`
+	User         *User      `json:"user,omitempty" gorm:"foreignKey:UserID;references:UserID"`
+	InvitedBy    User       `json:"invitedBy" gorm:"foreignKey:InvitedByID;references:UserID"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
@@ -22,14 +22,15 @@ type Note struct {
 
 // NoteShare represents note sharing permissions
 type NoteShare struct {
-	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	NoteID    uint      `json:"noteId" gorm:"not null;index"`
-	UserID    uint      `json:"userId" gorm:"not null;index"`
-	Access    string    `json:"access" gorm:"not null;check:access IN ('read', 'write')"`
-	Note      Note      `json:"note" gorm:"foreignKey:NoteID;references:NoteID"`
-	User      User      `json:"user" gorm:"foreignKey:UserID;references:UserID"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	NoteID           uint      `json:"noteId" gorm:"not null;index"`
+	UserID           uint      `json:"userId" gorm:"not null;index"`
+	Access           string    `json:"access" gorm:"not null;check:access IN ('read', 'write')"`
+	GrantedViaTeamID *uint     `json:"grantedViaTeamId,omitempty" gorm:"index"` // team the share was granted on behalf of, if any
+	Note             Note      `json:"note" gorm:"foreignKey:NoteID;references:NoteID"`
+	User             User      `json:"user" gorm:"foreignKey:UserID;references:UserID"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
 }
 
 // TableName override for note shares table
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationMention = "mention"
+)
+
+// Sources a mention can be written in
+const (
+	MentionSourceNote    = "note"
+	MentionSourceComment = "comment"
+)
+
+// Notification is a message for a user about something that happened on a
+// note they can read
+type Notification struct {
+	NotificationID uint       `json:"notificationId" gorm:"primaryKey;autoIncrement"`
+	UserID         uint       `json:"userId" gorm:"not null;index"`
+	ActorID        *uint      `json:"actorId"` // empty when the change came from a live editing session
+	Type           string     `json:"type" gorm:"not null"`
+	NoteID         uint       `json:"noteId" gorm:"not null;index"`
+	CommentID      *uint      `json:"commentId,omitempty"`
+	Link           string     `json:"link" gorm:"not null"`
+	ReadAt         *time.Time `json:"readAt,omitempty"`
+	Actor          *User      `json:"actor,omitempty" gorm:"foreignKey:ActorID;references:UserID"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Mention records that a note body or comment mentions a user, so only new
+// mentions notify on later saves
+type Mention struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SourceType string    `json:"sourceType" gorm:"not null;uniqueIndex:idx_mentions_source_user;check:source_type IN ('note', 'comment')"`
+	SourceID   uint      `json:"sourceId" gorm:"not null;uniqueIndex:idx_mentions_source_user"`
+	NoteID     uint      `json:"noteId" gorm:"not null;index"`
+	UserID     uint      `json:"userId" gorm:"not null;uniqueIndex:idx_mentions_source_user"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Tag is a label a user applies to their own notes
+type Tag struct {
+	TagID     uint      `json:"tagId" gorm:"primaryKey;autoIncrement"`
+	OwnerID   uint      `json:"ownerId" gorm:"not null;uniqueIndex:idx_tags_owner_name"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex:idx_tags_owner_name"`
+	Owner     User      `json:"-" gorm:"foreignKey:OwnerID;references:UserID"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NoteTag represents the many-to-many relationship between notes and tags
+type NoteTag struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	NoteID    uint      `json:"noteId" gorm:"not null;uniqueIndex:idx_note_tags_note_tag"`
+	TagID     uint      `json:"tagId" gorm:"not null;uniqueIndex:idx_note_tags_note_tag;index"`
+	Note      Note      `json:"-" gorm:"foreignKey:NoteID;references:NoteID"`
+	Tag       Tag       `json:"tag" gorm:"foreignKey:TagID;references:TagID"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName override for the note tags mapping table
+func (NoteTag) TableName() string {
+	return "note_tags"
+}
@@ -8,11 +8,14 @@ import (
 
 // Team represents a team in the system
 type Team struct {
-	TeamID    uint           `json:"teamId" gorm:"primaryKey;autoIncrement"`
-	TeamName  string         `json:"teamName" gorm:"not null"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	TeamID       uint           `json:"teamId" gorm:"primaryKey;autoIncrement"`
+	TeamName     string         `json:"teamName" gorm:"not null"`
+	ParentTeamID *uint          `json:"parentTeamId,omitempty" gorm:"index"` // managers of a parent team manage its sub-teams
+	MaxMembers   *int           `json:"maxMembers,omitempty"`                // cap on members and managers together; nil means no cap
+	ArchivedAt   *time.Time     `json:"archivedAt,omitempty" gorm:"index"`   // archived teams are read-only
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TeamMember represents the many-to-many relationship between users and teams
@@ -6,6 +6,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles, as issued by the user service
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleUser    = "USER"
+)
+
 // User represents a user in the system
 type User struct {
 	UserID       uint           `json:"userId" gorm:"primaryKey;autoIncrement"`
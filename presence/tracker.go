@@ -0,0 +1,203 @@
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TTL is how long a session stays on the roster without a heartbeat
+const TTL = 30 * time.Second
+
+// States a connected client can report
+const (
+	StateViewing = "viewing"
+	StateEditing = "editing"
+)
+
+// Asset types that presence is tracked for
+const (
+	AssetNote   = "note"
+	AssetFolder = "folder"
+)
+
+// Key identifies the note or folder a roster belongs to
+type Key struct {
+	AssetType string
+	AssetID   uint
+}
+
+// Viewer is one connected session on a roster
+type Viewer struct {
+	SessionID string    `json:"sessionId"`
+	UserID    uint      `json:"userId"`
+	Username  string    `json:"username"`
+	State     string    `json:"state"`
+	JoinedAt  time.Time `json:"joinedAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// sessionKey scopes client session ids to a user so one user cannot
+// overwrite another user's entry
+type sessionKey struct {
+	UserID    uint
+	SessionID string
+}
+
+// Tracker records heartbeats per asset and notifies subscribers when a
+// roster changes
+type Tracker struct {
+	mu          sync.Mutex
+	rosters     map[Key]map[sessionKey]Viewer
+	subscribers map[Key]map[chan []Viewer]struct{}
+	now         func() time.Time
+}
+
+// NewTracker creates a tracker and starts expiring stale sessions
+func NewTracker() *Tracker {
+	t := &Tracker{
+		rosters:     make(map[Key]map[sessionKey]Viewer),
+		subscribers: make(map[Key]map[chan []Viewer]struct{}),
+		now:         time.Now,
+	}
+	go t.sweepLoop()
+	return t
+}
+
+// Heartbeat adds or refreshes a session on the asset's roster
+func (t *Tracker) Heartbeat(key Key, viewer Viewer) []Viewer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	roster, ok := t.rosters[key]
+	if !ok {
+		roster = make(map[sessionKey]Viewer)
+		t.rosters[key] = roster
+	}
+
+	now := t.now()
+	sk := sessionKey{UserID: viewer.UserID, SessionID: viewer.SessionID}
+	existing, seen := roster[sk]
+	viewer.LastSeen = now
+	viewer.JoinedAt = now
+	if seen {
+		viewer.JoinedAt = existing.JoinedAt
+	}
+	roster[sk] = viewer
+
+	// Plain refreshes do not change what subscribers see
+	if !seen || existing.State != viewer.State {
+		t.publish(key)
+	}
+	return t.snapshot(key)
+}
+
+// Leave removes a session from the asset's roster
+func (t *Tracker) Leave(key Key, userID uint, sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	roster := t.rosters[key]
+	sk := sessionKey{UserID: userID, SessionID: sessionID}
+	if _, ok := roster[sk]; !ok {
+		return
+	}
+	delete(roster, sk)
+	if len(roster) == 0 {
+		delete(t.rosters, key)
+	}
+	t.publish(key)
+}
+
+// Roster returns the sessions currently on the asset's roster
+func (t *Tracker) Roster(key Key) []Viewer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot(key)
+}
+
+// Subscribe returns a channel that receives the full roster whenever it
+// changes, starting with the current one
+func (t *Tracker) Subscribe(key Key) chan []Viewer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch := make(chan []Viewer, 8)
+	if t.subscribers[key] == nil {
+		t.subscribers[key] = make(map[chan []Viewer]struct{})
+	}
+	t.subscribers[key][ch] = struct{}{}
+	ch <- t.snapshot(key)
+	return ch
+}
+
+// Unsubscribe stops updates on a channel returned by Subscribe
+func (t *Tracker) Unsubscribe(key Key, ch chan []Viewer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.subscribers[key][ch]; !ok {
+		return
+	}
+	delete(t.subscribers[key], ch)
+	if len(t.subscribers[key]) == 0 {
+		delete(t.subscribers, key)
+	}
+	close(ch)
+}
+
+// snapshot must be called with the lock held
+func (t *Tracker) snapshot(key Key) []Viewer {
+	viewers := make([]Viewer, 0, len(t.rosters[key]))
+	for _, v := range t.rosters[key] {
+		viewers = append(viewers, v)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		return viewers[i].JoinedAt.Before(viewers[j].JoinedAt)
+	})
+	return viewers
+}
+
+// publish must be called with the lock held
+func (t *Tracker) publish(key Key) {
+	roster := t.snapshot(key)
+	for ch := range t.subscribers[key] {
+		select {
+		case ch <- roster:
+		default:
+			// Slow subscribers skip intermediate rosters; the next
+			// change delivers the full state again
+		}
+	}
+}
+
+func (t *Tracker) sweepLoop() {
+	ticker := time.NewTicker(TTL / 3)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.expire()
+	}
+}
+
+// expire drops sessions that missed their heartbeats
+func (t *Tracker) expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := t.now().Add(-TTL)
+	for key, roster := range t.rosters {
+		changed := false
+		for sk, v := range roster {
+			if v.LastSeen.Before(cutoff) {
+				delete(roster, sk)
+				changed = true
+			}
+		}
+		if len(roster) == 0 {
+			delete(t.rosters, key)
+		}
+		if changed {
+			t.publish(key)
+		}
+	}
+}
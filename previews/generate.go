@@ -0,0 +1,187 @@
+package previews
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// Kinds of preview an attachment can get
+const (
+	KindNone  = ""
+	KindImage = "image"
+	KindPDF   = "pdf"
+	KindText  = "text"
+)
+
+const (
+	// maxPreviewLines and maxPreviewChars bound a text preview
+	maxPreviewLines = 20
+	maxPreviewChars = 2000
+
+	// maxImagePixels guards against decompression bombs
+	maxImagePixels = 50_000_000
+)
+
+// ThumbnailSize is one of the fixed sizes produced for images
+type ThumbnailSize struct {
+	Name string
+	Max  int // longest edge in pixels
+}
+
+// ThumbnailSizes are generated for every image attachment
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Max: 128},
+	{Name: "medium", Max: 512},
+	{Name: "large", Max: 1024},
+}
+
+// Thumbnail is an encoded, resized image
+type Thumbnail struct {
+	Size        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// KindFor returns the kind of preview that can be made for a content type
+func KindFor(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return KindImage
+	case "application/pdf":
+		return KindPDF
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return KindText
+	}
+	return KindNone
+}
+
+// Thumbnails decodes an image and resizes it to every thumbnail size. Images
+// are never scaled up. JPEG sources produce JPEG thumbnails, everything else
+// produces PNG to keep transparency.
+func Thumbnails(data []byte) ([]Thumbnail, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errors.New("image is too large to preview")
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	thumbs := make([]Thumbnail, 0, len(ThumbnailSizes))
+	for _, size := range ThumbnailSizes {
+		w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), size.Max)
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if format == "jpeg" {
+			contentType = "image/jpeg"
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, dst)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		thumbs = append(thumbs, Thumbnail{
+			Size:        size.Name,
+			Width:       w,
+			Height:      h,
+			ContentType: contentType,
+			Data:        buf.Bytes(),
+		})
+	}
+	return thumbs, nil
+}
+
+// fit scales width and height so the longest edge is at most limit
+func fit(width, height, limit int) (int, int) {
+	if width <= limit && height <= limit {
+		return width, height
+	}
+	if width >= height {
+		return limit, max(1, height*limit/width)
+	}
+	return max(1, width*limit/height), limit
+}
+
+// TextPreview returns the first lines of a plain-text file
+func TextPreview(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	var b strings.Builder
+	for lines := 0; lines < maxPreviewLines && scanner.Scan(); lines++ {
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			return "", errors.New("file is not valid UTF-8 text")
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+		if b.Len() >= maxPreviewChars {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return "", err
+	}
+	return truncate(b.String()), nil
+}
+
+// PDFPreview extracts the text of the first page of a PDF
+func PDFPreview(data []byte) (preview string, err error) {
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("malformed PDF")
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	if reader.NumPage() == 0 {
+		return "", errors.New("PDF has no pages")
+	}
+
+	text, err := reader.Page(1).GetPlainText(nil)
+	if err != nil {
+		return "", err
+	}
+	return TextPreview(strings.NewReader(text))
+}
+
+// truncate cuts text to the preview limit without splitting a character
+func truncate(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if len(text) <= maxPreviewChars {
+		return text
+	}
+	cut := maxPreviewChars
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
@@ -0,0 +1,182 @@
+package previews
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Preview statuses stored on attachments
+const (
+	StatusNone    = "none"
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+// maxSourceSize is the largest attachment the worker will load to preview
+const maxSourceSize = 25 << 20
+
+// errAttachmentDeleted is returned when an attachment is deleted while its
+// preview is being built
+var errAttachmentDeleted = errors.New("attachment was deleted")
+
+// queue holds attachment ids waiting for a preview
+var queue = make(chan uint, 256)
+
+// Start launches the preview workers and requeues attachments that were
+// still pending when the server last stopped
+func Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go work()
+	}
+
+	go func() {
+		var pending []uint
+		config.DB.Model(&models.Attachment{}).Where("preview_status = ?", StatusPending).Pluck("attachment_id", &pending)
+		for _, id := range pending {
+			queue <- id
+		}
+	}()
+}
+
+// Enqueue schedules preview generation for an attachment
+func Enqueue(attachmentID uint) {
+	go func() { queue <- attachmentID }()
+}
+
+func work() {
+	for id := range queue {
+		if err := process(id); err != nil {
+			log.Printf("previews: attachment %d: %v", id, err)
+			config.DB.Model(&models.Attachment{}).Where("attachment_id = ?", id).Updates(map[string]interface{}{
+				"preview_status": StatusFailed,
+				"preview_error":  err.Error(),
+			})
+		}
+	}
+}
+
+// process builds the preview for one attachment and marks it ready
+func process(id uint) error {
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, id).Error; err != nil {
+		// Deleted before the worker got to it
+		return nil
+	}
+	if attachment.PreviewStatus != StatusPending {
+		return nil
+	}
+
+	ctx := context.Background()
+	reader, err := config.Blobs.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSourceSize))
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"preview_status": StatusReady,
+		"preview_error":  "",
+	}
+
+	switch KindFor(attachment.ContentType) {
+	case KindImage:
+		if err := storeThumbnails(ctx, attachment, data); errors.Is(err, errAttachmentDeleted) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	case KindPDF:
+		text, err := PDFPreview(data)
+		if err != nil {
+			return err
+		}
+		updates["preview_text"] = text
+	case KindText:
+		text, err := TextPreview(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		updates["preview_text"] = text
+	default:
+		updates["preview_status"] = StatusNone
+	}
+
+	return config.DB.Model(&attachment).Updates(updates).Error
+}
+
+// storeThumbnails uploads an image's thumbnails and records them, replacing
+// those from an earlier attempt. Uploaded blobs are deleted again if they
+// cannot be recorded, including when the attachment was deleted meanwhile.
+func storeThumbnails(ctx context.Context, attachment models.Attachment, data []byte) error {
+	thumbs, err := Thumbnails(data)
+	if err != nil {
+		return err
+	}
+
+	records := make([]models.AttachmentThumbnail, 0, len(thumbs))
+	for _, thumb := range thumbs {
+		key := fmt.Sprintf("%s-%s", attachment.StorageKey, thumb.Size)
+		if err := config.Blobs.Put(ctx, key, bytes.NewReader(thumb.Data), int64(len(thumb.Data)), thumb.ContentType); err != nil {
+			deleteThumbnailBlobs(ctx, records)
+			return fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+
+		records = append(records, models.AttachmentThumbnail{
+			AttachmentID: attachment.AttachmentID,
+			Size:         thumb.Size,
+			Width:        thumb.Width,
+			Height:       thumb.Height,
+			ContentType:  thumb.ContentType,
+			StorageKey:   key,
+		})
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Hold the attachment so it cannot be deleted until the thumbnails
+		// are recorded, and deleting it removes them as well
+		var current models.Attachment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("attachment_id").First(&current, attachment.AttachmentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errAttachmentDeleted
+			}
+			return err
+		}
+
+		if err := tx.Where("attachment_id = ?", attachment.AttachmentID).Delete(&models.AttachmentThumbnail{}).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		deleteThumbnailBlobs(ctx, records)
+		return err
+	}
+	return nil
+}
+
+// deleteThumbnailBlobs removes the stored files of thumbnails that were not recorded
+func deleteThumbnailBlobs(ctx context.Context, thumbnails []models.AttachmentThumbnail) {
+	for _, thumbnail := range thumbnails {
+		if err := config.Blobs.Delete(ctx, thumbnail.StorageKey); err != nil {
+			log.Printf("previews: deleting blob %s: %v", thumbnail.StorageKey, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package previews
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupWorker points config.DB and config.Blobs at an in-memory database
+// and a temporary directory
+func setupWorker(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.Attachment{}, &models.AttachmentThumbnail{}); err != nil {
+		t.Fatal(err)
+	}
+
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousDB, previousBlobs := config.DB, config.Blobs
+	config.DB, config.Blobs = db, blobs
+	t.Cleanup(func() { config.DB, config.Blobs = previousDB, previousBlobs })
+}
+
+func testImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// storedBlobs counts the thumbnail blobs kept for an attachment
+func storedBlobs(t *testing.T, attachment models.Attachment) int {
+	t.Helper()
+	stored := 0
+	for _, size := range ThumbnailSizes {
+		reader, err := config.Blobs.Get(context.Background(), attachment.StorageKey+"-"+size.Name)
+		if err == nil {
+			reader.Close()
+			stored++
+		}
+	}
+	return stored
+}
+
+func TestStoreThumbnails(t *testing.T) {
+	setupWorker(t)
+	ctx := context.Background()
+	attachment := models.Attachment{NoteID: 1, UploaderID: 1, FileName: "photo.png", ContentType: "image/png", StorageKey: "photo"}
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A second attempt replaces the first
+	for range 2 {
+		if err := storeThumbnails(ctx, attachment, testImage(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var recorded int64
+	config.DB.Model(&models.AttachmentThumbnail{}).Where("attachment_id = ?", attachment.AttachmentID).Count(&recorded)
+	if recorded != int64(len(ThumbnailSizes)) || storedBlobs(t, attachment) != len(ThumbnailSizes) {
+		t.Fatalf("recorded %d thumbnails and stored %d blobs, want %d of each", recorded, storedBlobs(t, attachment), len(ThumbnailSizes))
+	}
+
+	// Deleted while the preview was being built
+	deleted := models.Attachment{AttachmentID: attachment.AttachmentID + 1, StorageKey: "deleted"}
+	if err := storeThumbnails(ctx, deleted, testImage(t)); err != errAttachmentDeleted {
+		t.Fatalf("err = %v, want errAttachmentDeleted", err)
+	}
+	config.DB.Model(&models.AttachmentThumbnail{}).Where("attachment_id = ?", deleted.AttachmentID).Count(&recorded)
+	if recorded != 0 || storedBlobs(t, deleted) != 0 {
+		t.Fatalf("a deleted attachment kept %d thumbnails and %d blobs", recorded, storedBlobs(t, deleted))
+	}
+}
@@ -0,0 +1,52 @@
+package reports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sections of a team report that can be written as CSV
+const (
+	SectionMembers = "members"
+	SectionStale   = "stale"
+)
+
+// WriteCSV writes one section of a team report as CSV with a header row
+func WriteCSV(w io.Writer, report TeamReport, section string) error {
+	header, rows := memberHeader, report.memberRows()
+	if section == SectionStale {
+		header, rows = staleHeader, report.staleRows()
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(csvRecord(header)); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := out.Write(csvRecord(row)); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// formulaPrefixes start text that spreadsheets would evaluate as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// csvRecord formats a table row as CSV fields. Text fields that would be read
+// as a formula, such as a username starting with "=", get a leading quote so
+// spreadsheets show them as text.
+func csvRecord(row []interface{}) []string {
+	record := make([]string, len(row))
+	for i, value := range row {
+		field := fmt.Sprint(value)
+		if _, text := value.(string); text && field != "" && strings.ContainsRune(formulaPrefixes, rune(field[0])) {
+			field = "'" + field
+		}
+		record[i] = field
+	}
+	return record
+}
@@ -0,0 +1,44 @@
+package reports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVRecordQuotesFormulas(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []interface{}
+		want []string
+	}{
+		{"plain text", []interface{}{"alice", "notes"}, []string{"alice", "notes"}},
+		{"equals", []interface{}{"=HYPERLINK(\"http://x\")"}, []string{"'=HYPERLINK(\"http://x\")"}},
+		{"plus", []interface{}{"+1"}, []string{"'+1"}},
+		{"minus", []interface{}{"-2+3"}, []string{"'-2+3"}},
+		{"at", []interface{}{"@SUM(A1)"}, []string{"'@SUM(A1)"}},
+		{"tab", []interface{}{"\t=1"}, []string{"'\t=1"}},
+		{"formula characters later on", []interface{}{"a=b", "x-y"}, []string{"a=b", "x-y"}},
+		{"empty", []interface{}{""}, []string{""}},
+		{"numbers", []interface{}{uint(7), int64(-1)}, []string{"7", "-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvRecord(tt.row); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("csvRecord() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSVQuotesMemberNames(t *testing.T) {
+	report := TeamReport{Members: []MemberStats{{UserID: 1, Username: "=cmd|' /C calc'!A0", Email: "a@example.com", Role: "member"}}}
+	var out strings.Builder
+	if err := WriteCSV(&out, report, SectionMembers); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "1,'=cmd|' /C calc'!A0,") {
+		t.Fatalf("CSV = %q", out.String())
+	}
+}
@@ -0,0 +1,74 @@
+package reports
+
+import (
+	"time"
+)
+
+// TeamReport aggregates the assets of a team's members and managers
+type TeamReport struct {
+	TeamID      uint          `json:"teamId"`
+	TeamName    string        `json:"teamName"`
+	From        *time.Time    `json:"from,omitempty"` // start of the period, inclusive
+	To          *time.Time    `json:"to,omitempty"`   // end of the period, exclusive
+	StaleDays   int           `json:"staleDays"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Members     []MemberStats `json:"members"`
+	StaleAssets []StaleAsset  `json:"staleAssets"`
+}
+
+// MemberStats are one person's figures for the report period. Counts cover
+// assets and shares created in the period.
+type MemberStats struct {
+	UserID         uint       `json:"userId"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"` // "member" or "manager"
+	FoldersOwned   int64      `json:"foldersOwned"`
+	NotesOwned     int64      `json:"notesOwned"`
+	SharesGiven    int64      `json:"sharesGiven"`
+	SharesReceived int64      `json:"sharesReceived"`
+	BodySize       int64      `json:"bodySize"` // characters across owned notes
+	LastActivity   *time.Time `json:"lastActivity,omitempty"`
+}
+
+// StaleAsset is a folder or note that has not been updated for StaleDays
+type StaleAsset struct {
+	AssetType string    `json:"assetType"`
+	AssetID   uint      `json:"assetId"`
+	Name      string    `json:"name"`
+	OwnerID   uint      `json:"ownerId"`
+	Owner     string    `json:"owner"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	DaysStale int       `json:"daysStale"`
+}
+
+// memberHeader and staleHeader head the member and stale asset tables
+var (
+	memberHeader = []interface{}{"User ID", "Username", "Email", "Role", "Folders owned", "Notes owned",
+		"Shares given", "Shares received", "Body size", "Last activity"}
+	staleHeader = []interface{}{"Type", "ID", "Name", "Owner ID", "Owner", "Updated at", "Days stale"}
+)
+
+// memberRows returns the member table, one row per person
+func (r TeamReport) memberRows() [][]interface{} {
+	rows := make([][]interface{}, 0, len(r.Members))
+	for _, m := range r.Members {
+		lastActivity := ""
+		if m.LastActivity != nil {
+			lastActivity = m.LastActivity.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []interface{}{m.UserID, m.Username, m.Email, m.Role, m.FoldersOwned, m.NotesOwned,
+			m.SharesGiven, m.SharesReceived, m.BodySize, lastActivity})
+	}
+	return rows
+}
+
+// staleRows returns the stale asset table, one row per asset
+func (r TeamReport) staleRows() [][]interface{} {
+	rows := make([][]interface{}, 0, len(r.StaleAssets))
+	for _, a := range r.StaleAssets {
+		rows = append(rows, []interface{}{a.AssetType, a.AssetID, a.Name, a.OwnerID, a.Owner,
+			a.UpdatedAt.UTC().Format(time.RFC3339), a.DaysStale})
+	}
+	return rows
+}
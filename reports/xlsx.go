@@ -0,0 +1,73 @@
+package reports
+
+import (
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet names of the XLSX report
+const (
+	summarySheet = "Summary"
+	membersSheet = "Members"
+	staleSheet   = "Stale assets"
+)
+
+// WriteXLSX writes a team report as an XLSX workbook with a summary sheet, a
+// member sheet and a stale asset sheet
+func WriteXLSX(w io.Writer, report TeamReport) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", summarySheet); err != nil {
+		return err
+	}
+	period := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	summary := [][]interface{}{
+		{"Team", report.TeamName},
+		{"Team ID", report.TeamID},
+		{"From", period(report.From)},
+		{"To", period(report.To)},
+		{"Stale after (days)", report.StaleDays},
+		{"Generated at", report.GeneratedAt.UTC().Format(time.RFC3339)},
+	}
+	if err := writeSheet(f, summarySheet, summary); err != nil {
+		return err
+	}
+
+	if _, err := f.NewSheet(membersSheet); err != nil {
+		return err
+	}
+	if err := writeSheet(f, membersSheet, append([][]interface{}{memberHeader}, report.memberRows()...)); err != nil {
+		return err
+	}
+
+	if _, err := f.NewSheet(staleSheet); err != nil {
+		return err
+	}
+	if err := writeSheet(f, staleSheet, append([][]interface{}{staleHeader}, report.staleRows()...)); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+// writeSheet writes rows to a sheet starting at A1
+func writeSheet(f *excelize.File, sheet string, rows [][]interface{}) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
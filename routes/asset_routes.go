@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 )
 
 // SetupAssetRoutes sets up all asset management routes (Manager-only APIs)
@@ -10,12 +11,12 @@ func SetupAssetRoutes(router *gin.Engine) {
 	// Team asset management
 	teamGroup := router.Group("/teams")
 	{
-		teamGroup.GET("/:teamId/assets", controller.GetTeamAssets)
+		teamGroup.GET("/:teamId/assets", middleware.RequireAuth(), controller.GetTeamAssets)
 	}
 
 	// User asset management
 	userGroup := router.Group("/users")
 	{
-		userGroup.GET("/:userId/assets", controller.GetUserAssets)
+		userGroup.GET("/:userId/assets", middleware.RequireAuth(), controller.GetUserAssets)
 	}
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupBatchRoutes sets up the multi-operation batch route
+func SetupBatchRoutes(router *gin.Engine) {
+	// Ordered operations applied in one transaction
+	router.POST("/batch", middleware.RequireAuth(), controller.RunBatch)
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupBulkRoutes sets up routes that act on many notes or folders at once.
+// Each takes "mode": "atomic" (the default) or "best-effort".
+func SetupBulkRoutes(router *gin.Engine) {
+	bulkGroup := router.Group("/bulk", middleware.RequireAuth())
+	{
+		// Notes
+		bulkGroup.POST("/notes/share", controller.BulkShareNotes)
+		bulkGroup.POST("/notes/revoke", controller.BulkRevokeNoteShares)
+		bulkGroup.POST("/notes/move", controller.BulkMoveNotes)
+		bulkGroup.POST("/notes/delete", controller.BulkDeleteNotes)
+
+		// Folders
+		bulkGroup.POST("/folders/share", controller.BulkShareFolders)
+		bulkGroup.POST("/folders/revoke", controller.BulkRevokeFolderShares)
+		bulkGroup.POST("/folders/delete", controller.BulkDeleteFolders)
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupCacheRoutes sets up routes for inspecting the cache
+func SetupCacheRoutes(router *gin.Engine) {
+	cacheGroup := router.Group("/cache", middleware.RequireAuth())
+	{
+		// Hit and miss counts (Admin-only)
+		cacheGroup.GET("/stats", controller.GetCacheStats)
+	}
+}
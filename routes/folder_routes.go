@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 )
 
 // SetupFolderRoutes sets up all folder-related routes
@@ -10,16 +11,36 @@ func SetupFolderRoutes(router *gin.Engine) {
 	folderGroup := router.Group("/folders")
 	{
 		// Folder CRUD operations
-		folderGroup.POST("", controller.CreateFolder)
-		folderGroup.GET("/:folderId", controller.GetFolder)
-		folderGroup.PUT("/:folderId", controller.UpdateFolder)
-		folderGroup.DELETE("/:folderId", controller.DeleteFolder)
+		folderGroup.POST("", middleware.RequireAuth(), controller.CreateFolder)
+		folderGroup.POST("/import", middleware.RequireAuth(), controller.ImportFolder)
+		folderGroup.GET("/:folderId", middleware.RequireAuth(), controller.GetFolder)
+		folderGroup.PUT("/:folderId", middleware.RequireAuth(), controller.UpdateFolder)
+		folderGroup.PATCH("/:folderId", middleware.RequireAuth(), controller.PatchFolder)
+		folderGroup.DELETE("/:folderId", middleware.RequireAuth(), controller.DeleteFolder)
 
 		// Folder sharing
-		folderGroup.POST("/:folderId/share", controller.ShareFolder)
-		folderGroup.DELETE("/:folderId/share/:userId", controller.RevokeFolderShare)
+		folderGroup.POST("/:folderId/share", middleware.RequireAuth(), controller.ShareFolder)
+		folderGroup.DELETE("/:folderId/share/:userId", middleware.RequireAuth(), controller.RevokeFolderShare)
+
+		// Ownership
+		folderGroup.POST("/:folderId/transfer", middleware.RequireAuth(), controller.TransferFolder)
 
 		// Notes within folders
-		folderGroup.POST("/:folderId/notes", controller.CreateNote)
+		folderGroup.POST("/:folderId/notes", middleware.RequireAuth(), controller.CreateNote)
+
+		// Stars and pins, private to the caller
+		folderGroup.PUT("/:folderId/star", middleware.RequireAuth(), controller.StarFolder)
+		folderGroup.DELETE("/:folderId/star", middleware.RequireAuth(), controller.UnstarFolder)
+		folderGroup.PUT("/:folderId/pin", middleware.RequireAuth(), controller.PinFolder)
+		folderGroup.DELETE("/:folderId/pin", middleware.RequireAuth(), controller.UnpinFolder)
+
+		// Export as a ZIP of Markdown files
+		folderGroup.GET("/:folderId/export", middleware.RequireAuth(), controller.ExportFolder)
+
+		// Presence of users who have the folder open
+		folderGroup.GET("/:folderId/presence", middleware.RequireAuth(), controller.GetFolderPresence)
+		folderGroup.GET("/:folderId/presence/stream", middleware.RequireAuth(), controller.StreamFolderPresence)
+		folderGroup.POST("/:folderId/presence", middleware.RequireAuth(), controller.FolderHeartbeat)
+		folderGroup.DELETE("/:folderId/presence/:sessionId", middleware.RequireAuth(), controller.FolderLeave)
 	}
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupJobRoutes sets up routes for following background jobs
+func SetupJobRoutes(router *gin.Engine) {
+	jobGroup := router.Group("/jobs", middleware.RequireAuth())
+	{
+		jobGroup.GET("/:jobId", controller.GetJob)
+		jobGroup.GET("/:jobId/download", controller.DownloadJobFile)
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupMeRoutes sets up routes for the caller's own bookmarks, history, notifications and invitations
+func SetupMeRoutes(router *gin.Engine) {
+	meGroup := router.Group("/me", middleware.RequireAuth())
+	{
+		// Starred and pinned notes and folders
+		meGroup.GET("/bookmarks", controller.ListBookmarks)
+
+		// Recently viewed notes and folders
+		meGroup.GET("/recent", controller.ListRecentViews)
+
+		// Notifications, such as @mentions
+		meGroup.GET("/notifications", controller.ListNotifications)
+		meGroup.POST("/notifications/read", controller.MarkAllNotificationsRead)
+		meGroup.POST("/notifications/:notificationId/read", controller.MarkNotificationRead)
+
+		// Team invitations
+		meGroup.GET("/invitations", controller.ListMyInvitations)
+		meGroup.POST("/invitations/:invitationId/accept", controller.AcceptInvitation)
+		meGroup.POST("/invitations/:invitationId/decline", controller.DeclineInvitation)
+	}
+}
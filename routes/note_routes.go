@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 )
 
 // SetupNoteRoutes sets up all note-related routes
@@ -10,12 +11,55 @@ func SetupNoteRoutes(router *gin.Engine) {
 	noteGroup := router.Group("/notes")
 	{
 		// Note CRUD operations
-		noteGroup.GET("/:noteId", controller.GetNote)
-		noteGroup.PUT("/:noteId", controller.UpdateNote)
-		noteGroup.DELETE("/:noteId", controller.DeleteNote)
+		noteGroup.GET("", middleware.RequireAuth(), controller.ListNotes)
+		noteGroup.GET("/:noteId", middleware.RequireAuth(), controller.GetNote)
+		noteGroup.PUT("/:noteId", middleware.RequireAuth(), controller.UpdateNote)
+		noteGroup.PATCH("/:noteId", middleware.RequireAuth(), controller.PatchNote)
+		noteGroup.DELETE("/:noteId", middleware.RequireAuth(), controller.DeleteNote)
+
+		// Markdown rendering
+		noteGroup.GET("/:noteId/render", middleware.RequireAuth(), controller.RenderNote)
 
 		// Note sharing
-		noteGroup.POST("/:noteId/share", controller.ShareNote)
-		noteGroup.DELETE("/:noteId/share/:userId", controller.RevokeNoteShare)
+		noteGroup.POST("/:noteId/share", middleware.RequireAuth(), controller.ShareNote)
+		noteGroup.DELETE("/:noteId/share/:userId", middleware.RequireAuth(), controller.RevokeNoteShare)
+
+		// Ownership
+		noteGroup.POST("/:noteId/transfer", middleware.RequireAuth(), controller.TransferNote)
+
+		// Note attachments
+		noteGroup.POST("/:noteId/attachments", middleware.RequireAuth(), controller.UploadAttachment)
+		noteGroup.GET("/:noteId/attachments", middleware.RequireAuth(), controller.ListAttachments)
+		noteGroup.GET("/:noteId/attachments/:attachmentId/url", middleware.RequireAuth(), controller.GetAttachmentURL)
+		noteGroup.DELETE("/:noteId/attachments/:attachmentId", middleware.RequireAuth(), controller.DeleteAttachment)
+
+		// Threaded comments
+		noteGroup.GET("/:noteId/comments", middleware.RequireAuth(), controller.ListComments)
+		noteGroup.POST("/:noteId/comments", middleware.RequireAuth(), controller.CreateComment)
+		noteGroup.PUT("/:noteId/comments/:commentId", middleware.RequireAuth(), controller.UpdateComment)
+		noteGroup.DELETE("/:noteId/comments/:commentId", middleware.RequireAuth(), controller.DeleteComment)
+		noteGroup.POST("/:noteId/comments/:commentId/resolve", middleware.RequireAuth(), controller.ResolveThread)
+		noteGroup.POST("/:noteId/comments/:commentId/reopen", middleware.RequireAuth(), controller.ReopenThread)
+
+		// Stars and pins, private to the caller
+		noteGroup.PUT("/:noteId/star", middleware.RequireAuth(), controller.StarNote)
+		noteGroup.DELETE("/:noteId/star", middleware.RequireAuth(), controller.UnstarNote)
+		noteGroup.PUT("/:noteId/pin", middleware.RequireAuth(), controller.PinNote)
+		noteGroup.DELETE("/:noteId/pin", middleware.RequireAuth(), controller.UnpinNote)
+
+		// Live collaborative editing over WebSocket
+		noteGroup.GET("/:noteId/edit", middleware.RequireAuth(), controller.EditNoteSession)
+
+		// Presence of users who have the note open
+		noteGroup.GET("/:noteId/presence", middleware.RequireAuth(), controller.GetNotePresence)
+		noteGroup.GET("/:noteId/presence/stream", middleware.RequireAuth(), controller.StreamNotePresence)
+		noteGroup.POST("/:noteId/presence", middleware.RequireAuth(), controller.NoteHeartbeat)
+		noteGroup.DELETE("/:noteId/presence/:sessionId", middleware.RequireAuth(), controller.NoteLeave)
 	}
+
+	// Attachment downloads are authorized by their signed URL
+	router.GET("/attachments/:attachmentId/download", controller.DownloadAttachment)
+
+	// Stylesheet for highlighted code blocks in rendered notes
+	router.GET("/markdown/styles.css", controller.GetMarkdownStyles)
 }
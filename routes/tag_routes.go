@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupTagRoutes sets up routes for managing the caller's note tags
+func SetupTagRoutes(router *gin.Engine) {
+	tagGroup := router.Group("/tags", middleware.RequireAuth())
+	{
+		// Tag CRUD operations
+		tagGroup.GET("", controller.ListTags)
+		tagGroup.POST("", controller.CreateTag)
+		tagGroup.PUT("/:tagId", controller.RenameTag)
+		tagGroup.DELETE("/:tagId", controller.DeleteTag)
+		tagGroup.POST("/:tagId/merge", controller.MergeTag)
+
+		// Bulk tagging of notes
+		tagGroup.POST("/assign", controller.TagNotes)
+		tagGroup.POST("/unassign", controller.UntagNotes)
+	}
+}
@@ -3,21 +3,44 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
 )
 
 // SetupTeamRoutes sets up all team-related routes
 func SetupTeamRoutes(router *gin.Engine) {
 	teamGroup := router.Group("/teams")
 	{
-		// Create a team
-		teamGroup.POST("", controller.CreateTeam)
+		// Team lifecycle
+		teamGroup.POST("", middleware.RequireAuth(), controller.CreateTeam)
+		teamGroup.GET("", middleware.RequireAuth(), controller.ListTeams)
+		teamGroup.GET("/tree", middleware.RequireAuth(), controller.GetOrgTree)
+		teamGroup.GET("/:teamId", middleware.RequireAuth(), controller.GetTeam)
+		teamGroup.PUT("/:teamId", middleware.RequireAuth(), controller.UpdateTeam)
+		teamGroup.DELETE("/:teamId", middleware.RequireAuth(), controller.DeleteTeam)
+		teamGroup.POST("/:teamId/archive", middleware.RequireAuth(), controller.ArchiveTeam)
+		teamGroup.POST("/:teamId/unarchive", middleware.RequireAuth(), controller.UnarchiveTeam)
+
+		// Partial updates with a JSON Merge Patch or JSON Patch
+		teamGroup.PATCH("/:teamId", middleware.RequireAuth(), controller.PatchTeam)
+
+		// Team reports
+		teamGroup.GET("/:teamId/report", middleware.RequireAuth(), controller.GetTeamReport)
+		teamGroup.GET("/:teamId/report/download", middleware.RequireAuth(), controller.DownloadTeamReport)
 
 		// Team member management
-		teamGroup.POST("/:teamId/members", controller.AddMemberToTeam)
-		teamGroup.DELETE("/:teamId/members/:memberId", controller.RemoveMemberFromTeam)
+		teamGroup.POST("/:teamId/members", middleware.RequireAuth(), controller.AddMemberToTeam)
+		teamGroup.PUT("/:teamId/members", middleware.RequireAuth(), controller.SyncTeamMembers)
+		teamGroup.DELETE("/:teamId/members/:memberId", middleware.RequireAuth(), controller.RemoveMemberFromTeam)
+		teamGroup.GET("/:teamId/members/:memberId/removal-preview", middleware.RequireAuth(), controller.PreviewMemberRemoval)
+
+		// Invitations to join as a member or manager
+		teamGroup.POST("/:teamId/invitations", middleware.RequireAuth(), controller.InviteToTeam)
+		teamGroup.GET("/:teamId/invitations", middleware.RequireAuth(), controller.ListTeamInvitations)
+		teamGroup.DELETE("/:teamId/invitations/:invitationId", middleware.RequireAuth(), controller.CancelInvitation)
 
 		// Team manager management
-		teamGroup.POST("/:teamId/managers", controller.AddManagerToTeam)
-		teamGroup.DELETE("/:teamId/managers/:managerId", controller.RemoveManagerFromTeam)
+		teamGroup.POST("/:teamId/managers", middleware.RequireAuth(), controller.AddManagerToTeam)
+		teamGroup.PUT("/:teamId/managers", middleware.RequireAuth(), controller.SyncTeamManagers)
+		teamGroup.DELETE("/:teamId/managers/:managerId", middleware.RequireAuth(), controller.RemoveManagerFromTeam)
 	}
 }
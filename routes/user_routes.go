@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+)
+
+// SetupUserRoutes sets up routes for managing users
+func SetupUserRoutes(router *gin.Engine) {
+	userGroup := router.Group("/users", middleware.RequireAuth())
+	{
+		// Offboarding (admins, and managers of the user's teams)
+		userGroup.POST("/:userId/offboard", controller.OffboardUser)
+	}
+}
@@ -0,0 +1,275 @@
+// Command assetbench seeds a team with a large number of folders, notes and
+// shares and measures how long GET /teams/:teamId/assets takes for it.
+//
+// It connects to the database configured in config.Connect, so point that at
+// a scratch database. Requests are made as one of the team's managers, signed
+// with JWT_SECRET. Seed once, then benchmark as often as needed:
+//
+//	go run ./tools/assetbench -seed -notes 10000 -members 50
+//	go run ./tools/assetbench -team 12 -iterations 100
+//
+// BenchmarkGetTeamAssets in the controller package measures the same listing
+// against an in-memory database with no setup:
+//
+//	go test ./controller -run '^$' -bench GetTeamAssets
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/controller"
+	"github.com/seta-namnv-6798/go-apis/middleware"
+	"github.com/seta-namnv-6798/go-apis/models"
+	"github.com/seta-namnv-6798/go-apis/routes"
+	"gorm.io/gorm"
+)
+
+// seedOptions sizes the seeded team
+type seedOptions struct {
+	Members int
+	Folders int
+	Notes   int
+	Shares  int
+}
+
+func main() {
+	seedData := flag.Bool("seed", false, "seed a new team before benchmarking")
+	teamID := flag.Uint("team", 0, "benchmark an existing team instead of seeding one")
+	members := flag.Int("members", 50, "members in the seeded team")
+	folders := flag.Int("folders", 500, "folders in the seeded team")
+	notes := flag.Int("notes", 10000, "notes in the seeded team")
+	shares := flag.Int("shares", 5000, "folder and note shares between seeded members")
+	iterations := flag.Int("iterations", 50, "requests to time")
+	query := flag.String("query", "", "query string added to each request, e.g. tags=a,b")
+	flag.Parse()
+
+	if !*seedData && *teamID == 0 {
+		log.Fatal("assetbench: pass -seed to seed a team or -team to use an existing one")
+	}
+
+	config.RequireJWTSecret()
+	config.Connect()
+
+	if *seedData {
+		started := time.Now()
+		id, err := seed(config.DB, seedOptions{Members: *members, Folders: *folders, Notes: *notes, Shares: *shares})
+		if err != nil {
+			log.Fatalf("assetbench: seeding: %v", err)
+		}
+		*teamID = id
+		fmt.Printf("seeded team %d in %s\n", id, time.Since(started).Round(time.Millisecond))
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.Authenticate())
+	routes.SetupAssetRoutes(router)
+
+	if err := bench(router, *teamID, *query, *iterations); err != nil {
+		log.Fatalf("assetbench: %v", err)
+	}
+}
+
+// seed creates a team of members who own folders and notes and share some of
+// them with each other, and returns the team's id. The rows are inserted
+// directly, so the effective access table is rebuilt afterwards for the
+// listings to see them.
+func seed(db *gorm.DB, opts seedOptions) (uint, error) {
+	if opts.Members < 1 || opts.Folders < 1 {
+		return 0, fmt.Errorf("need at least one member and one folder")
+	}
+	run := time.Now().UnixNano()
+	rng := rand.New(rand.NewSource(run))
+
+	var teamID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		team := models.Team{TeamName: fmt.Sprintf("assetbench-%d", run)}
+		if err := tx.Create(&team).Error; err != nil {
+			return err
+		}
+		teamID = team.TeamID
+
+		users := make([]models.User, opts.Members)
+		for i := range users {
+			users[i] = models.User{
+				Username:     fmt.Sprintf("assetbench-%d-%d", run, i),
+				Email:        fmt.Sprintf("assetbench-%d-%d@example.com", run, i),
+				Role:         models.RoleUser,
+				PasswordHash: "-",
+			}
+		}
+		if err := tx.CreateInBatches(&users, 500).Error; err != nil {
+			return err
+		}
+
+		// The first user manages the team; the rest are members
+		if err := tx.Create(&models.TeamManager{TeamID: team.TeamID, UserID: users[0].UserID}).Error; err != nil {
+			return err
+		}
+		teamMembers := make([]models.TeamMember, 0, len(users)-1)
+		for _, user := range users[1:] {
+			teamMembers = append(teamMembers, models.TeamMember{TeamID: team.TeamID, UserID: user.UserID})
+		}
+		if len(teamMembers) > 0 {
+			if err := tx.CreateInBatches(&teamMembers, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		folders := make([]models.Folder, opts.Folders)
+		for i := range folders {
+			folders[i] = models.Folder{
+				Name:    fmt.Sprintf("Folder %d", i),
+				OwnerID: users[rng.Intn(len(users))].UserID,
+			}
+		}
+		if err := tx.CreateInBatches(&folders, 500).Error; err != nil {
+			return err
+		}
+
+		notes := make([]models.Note, opts.Notes)
+		body := strings.Repeat("Lorem ipsum dolor sit amet. ", 20)
+		for i := range notes {
+			folder := folders[rng.Intn(len(folders))]
+			notes[i] = models.Note{
+				Title:    fmt.Sprintf("Note %d", i),
+				Body:     body,
+				FolderID: folder.FolderID,
+				OwnerID:  folder.OwnerID,
+			}
+		}
+		if len(notes) > 0 {
+			if err := tx.CreateInBatches(&notes, 500).Error; err != nil {
+				return err
+			}
+		}
+
+		// Half the shares go on folders and half on notes, never to the
+		// owner and at most once per asset and user
+		access := []string{"read", "write"}
+		seen := make(map[string]bool)
+		var folderShares []models.FolderShare
+		var noteShares []models.NoteShare
+		for attempt := 0; len(folderShares)+len(noteShares) < opts.Shares && attempt < opts.Shares*4; attempt++ {
+			user := users[rng.Intn(len(users))]
+			if attempt%2 == 0 || len(notes) == 0 {
+				folder := folders[rng.Intn(len(folders))]
+				key := fmt.Sprintf("f%d-%d", folder.FolderID, user.UserID)
+				if folder.OwnerID == user.UserID || seen[key] {
+					continue
+				}
+				seen[key] = true
+				folderShares = append(folderShares, models.FolderShare{FolderID: folder.FolderID, UserID: user.UserID, Access: access[rng.Intn(2)]})
+				continue
+			}
+			note := notes[rng.Intn(len(notes))]
+			key := fmt.Sprintf("n%d-%d", note.NoteID, user.UserID)
+			if note.OwnerID == user.UserID || seen[key] {
+				continue
+			}
+			seen[key] = true
+			noteShares = append(noteShares, models.NoteShare{NoteID: note.NoteID, UserID: user.UserID, Access: access[rng.Intn(2)]})
+		}
+		if len(folderShares) > 0 {
+			if err := tx.CreateInBatches(&folderShares, 500).Error; err != nil {
+				return err
+			}
+		}
+		if len(noteShares) > 0 {
+			if err := tx.CreateInBatches(&noteShares, 500).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := controller.RebuildEffectiveAccess(db); err != nil {
+		return 0, fmt.Errorf("rebuilding effective access: %w", err)
+	}
+	return teamID, nil
+}
+
+// managerToken signs a token for one of the team's managers, who may list
+// its assets
+func managerToken(teamID uint) (string, error) {
+	var manager models.TeamManager
+	if err := config.DB.Where("team_id = ?", teamID).First(&manager).Error; err != nil {
+		return "", fmt.Errorf("team %d has no manager to request its assets as", teamID)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": manager.UserID,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	return token.SignedString(config.JWTSecret())
+}
+
+// bench requests the team's assets repeatedly and prints latency percentiles
+func bench(router *gin.Engine, teamID uint, query string, iterations int) error {
+	if iterations < 1 {
+		return fmt.Errorf("need at least one iteration")
+	}
+	path := fmt.Sprintf("/teams/%d/assets", teamID)
+	if query != "" {
+		path += "?" + query
+	}
+	token, err := managerToken(teamID)
+	if err != nil {
+		return err
+	}
+	request := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	// One warm-up request, which also checks the team can be read
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request())
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d: %s", path, w.Code, w.Body.String())
+	}
+	size := w.Body.Len()
+
+	durations := make([]time.Duration, iterations)
+	for i := range durations {
+		w := httptest.NewRecorder()
+		started := time.Now()
+		router.ServeHTTP(w, request())
+		durations[i] = time.Since(started)
+		if w.Code != http.StatusOK {
+			return fmt.Errorf("GET %s: status %d", path, w.Code)
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	percentile := func(p float64) time.Duration {
+		return durations[int(p*float64(len(durations)-1))]
+	}
+
+	fmt.Printf("GET %s: %d requests, %d byte response\n", path, iterations, size)
+	fmt.Printf("  mean %s  min %s  p50 %s  p95 %s  max %s\n",
+		(total / time.Duration(iterations)).Round(time.Microsecond),
+		durations[0].Round(time.Microsecond),
+		percentile(0.50).Round(time.Microsecond),
+		percentile(0.95).Round(time.Microsecond),
+		durations[len(durations)-1].Round(time.Microsecond))
+	return nil
+}
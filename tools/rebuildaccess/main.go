@@ -0,0 +1,63 @@
+// Command rebuildaccess regenerates the effective access table from folder
+// and note ownership and shares, then checks the result against them. With
+// -verify it only checks the current table. It exits non-zero when the table
+// and the records it is derived from disagree.
+//
+// A rebuild drops the cached access decisions and listings it changes from a
+// redis cache. Servers using the in-memory lru cache keep theirs until the
+// entries expire or the servers restart.
+//
+//	go run ./tools/rebuildaccess
+//	go run ./tools/rebuildaccess -verify
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/seta-namnv-6798/go-apis/config"
+	"github.com/seta-namnv-6798/go-apis/controller"
+)
+
+func main() {
+	verifyOnly := flag.Bool("verify", false, "check the table without rebuilding it")
+	limit := flag.Int("limit", 50, "differences to print")
+	flag.Parse()
+
+	config.Connect()
+	config.ConnectCache()
+
+	if !*verifyOnly {
+		rows, err := controller.RebuildEffectiveAccess(config.DB)
+		if err != nil {
+			log.Fatalf("rebuildaccess: rebuilding: %v", err)
+		}
+		fmt.Printf("rebuilt effective access: %d rows\n", rows)
+	}
+
+	drift, err := controller.VerifyEffectiveAccess(config.DB)
+	if err != nil {
+		log.Fatalf("rebuildaccess: verifying: %v", err)
+	}
+	if len(drift) == 0 {
+		fmt.Println("effective access matches ownership and shares")
+		return
+	}
+
+	fmt.Printf("effective access differs in %d rows:\n", len(drift))
+	for i, d := range drift {
+		if i == *limit {
+			fmt.Printf("  ... and %d more\n", len(drift)-*limit)
+			break
+		}
+		g := d.Grant
+		line := fmt.Sprintf("  %-7s user %d %s %d via %s: %s", d.Problem, g.UserID, g.AssetType, g.AssetID, g.Source, g.Level)
+		if d.Problem == "level" {
+			line += fmt.Sprintf(" (stored %s)", d.Stored)
+		}
+		fmt.Println(line)
+	}
+	os.Exit(1)
+}